@@ -0,0 +1,47 @@
+// +build !desktop
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/loophole/cli/internal/pkg/communication"
+	"github.com/loophole/cli/internal/pkg/profile"
+	"github.com/loophole/cli/internal/pkg/token"
+	"github.com/spf13/cobra"
+)
+
+var listProfilesCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List profiles",
+	Long: `Lists profiles and whether you are logged in to each of them, the active profile is marked with an asterisk.
+
+Every profile has its own tokens, SSH identity and default options, which makes it possible to use several accounts on one machine.
+Profiles are created by logging in with --profile flag.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		names, err := profile.List()
+		if err != nil {
+			communication.Fatal(err.Error())
+		}
+		current, err := profile.Current()
+		if err != nil {
+			communication.Fatal(err.Error())
+		}
+		for _, name := range names {
+			marker := " "
+			if name == current {
+				marker = "*"
+			}
+			state := "logged out"
+			if token.IsTokenSavedFor(name) {
+				state = "logged in"
+			}
+			fmt.Printf("%s %s (%s)\n", marker, name, state)
+		}
+	},
+}
+
+func init() {
+	accountCmd.AddCommand(listProfilesCmd)
+}
@@ -4,46 +4,133 @@ package cmd
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 
 	"github.com/loophole/cli/internal/pkg/communication"
+	"github.com/loophole/cli/internal/pkg/inpututil"
 	"github.com/loophole/cli/internal/pkg/token"
+	authModels "github.com/loophole/cli/internal/pkg/token/models"
+	"github.com/skratchdot/open-golang/open"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
+var loginWithToken bool
+var loginWithBrowser bool
+
 var loginCmd = &cobra.Command{
 	Use:   "login",
 	Short: "Log in to use your account",
-	Long: `Loophole service requires authentication, this command allows you to log in or set up one
+	Long: fmt.Sprintf(`Loophole service requires authentication, this command allows you to log in or set up one
 in case you don't yet have it.
 
 Running this command as not logged in user will prompt you to open URL and use the browser to verify your identity.
+With --browser the login page is opened automatically and you are logged in as soon as you finish it there,
+the URL and code are used only when the browser can't be opened.
+
+Running this command as logged in user will fail, in cae you want to relogin then you need to log out first
 
-Running this command as logged in user will fail, in cae you want to relogin then you need to log out first`,
-	Run: func(cmd *cobra.Command, args []string) {
+Tokens are kept separately for every profile, use --profile flag to log in to another account.
+
+In CI and on headless servers use --with-token to pass the token on stdin, e.g. 'echo $TOKEN | loophole account login --with-token'.
+Alternatively the tokens can be provided with %s and %s environment variables, in which case nothing is written to disk.`, token.TokenEnvVar, token.RefreshTokenEnvVar),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if token.FromEnvironment() {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("Tokens are provided with %s or %s, unset them to log in", token.TokenEnvVar, token.RefreshTokenEnvVar)
+		}
+		if loginWithToken {
+			cmd.SilenceUsage = true
+			return loginWithProvidedToken()
+		}
 		if token.IsTokenSaved() {
 			communication.LoginFailure(fmt.Errorf("Already logged in, please use `%s account logout` first to re-login", os.Args[0]))
 		}
 
-		deviceCodeSpec, err := token.RegisterDevice()
-		if err != nil {
-			communication.LoginFailure(fmt.Errorf("Error obtaining device code: %s", err.Error()))
-		}
-		communication.LoginStart(*deviceCodeSpec)
 		quitChannel := make(chan bool)
-		tokens, err := token.PollForToken(deviceCodeSpec.DeviceCode, deviceCodeSpec.Interval, quitChannel)
-		if err != nil {
-			communication.LoginFailure(fmt.Errorf("Error obtaining token: %s", err.Error()))
+		var tokens *authModels.TokenSpec
+		if loginWithBrowser {
+			tokens = loginViaBrowser(quitChannel)
 		}
-		err = token.SaveToken(tokens)
+		if tokens == nil {
+			deviceCodeSpec, err := token.RegisterDevice()
+			if err != nil {
+				communication.LoginFailure(fmt.Errorf("Error obtaining device code: %s", err.Error()))
+			}
+			communication.LoginStart(*deviceCodeSpec)
+			tokens, err = token.PollForToken(deviceCodeSpec.DeviceCode, deviceCodeSpec.Interval, quitChannel)
+			if err != nil {
+				communication.LoginFailure(fmt.Errorf("Error obtaining token: %s", err.Error()))
+			}
+		}
+		err := token.SaveToken(tokens)
 		if err != nil {
 			communication.LoginFailure(fmt.Errorf("Error saving token: %s", err.Error()))
 
 		}
 		communication.LoginSuccess(tokens.IDToken)
+		return nil
 	},
 }
 
+// loginViaBrowser logs in with authorization code flow, returning nil when the browser can't be used
+// so that device code flow is used instead
+func loginViaBrowser(quitChannel <-chan bool) *authModels.TokenSpec {
+	login, err := token.StartBrowserLogin()
+	if err != nil {
+		communication.Warn(fmt.Sprintf("%s, falling back to device code login", err.Error()))
+		return nil
+	}
+	err = open.Run(login.AuthorizationURL)
+	if err != nil {
+		login.Close()
+		communication.Warn(fmt.Sprintf("There was a problem opening the browser, falling back to device code login: %s", err.Error()))
+		return nil
+	}
+	communication.Info("Please finish logging in using the browser window which has just opened")
+	communication.Info(fmt.Sprintf("If it didn't open, visit: %s", login.AuthorizationURL))
+
+	tokens, err := login.Wait(quitChannel)
+	if err != nil {
+		communication.LoginFailure(fmt.Errorf("Error obtaining token: %s", err.Error()))
+	}
+	return tokens
+}
+
+// loginWithProvidedToken saves the token read from stdin, failing with an error instead of exiting for invalid ones
+func loginWithProvidedToken() error {
+	if token.IsTokenSaved() {
+		return fmt.Errorf("Already logged in, please use `%s account logout` first to re-login", os.Args[0])
+	}
+
+	var content []byte
+	var err error
+	if inpututil.IsUsingPipe() {
+		content, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		fmt.Print("Paste the token: ")
+		content, err = term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Println()
+	}
+	if err != nil {
+		return fmt.Errorf("There was a problem reading the token: %v", err)
+	}
+
+	tokens, err := token.ParseTokens(string(content), "stdin")
+	if err != nil {
+		return err
+	}
+	tokens, err = token.LoginWithToken(tokens, "stdin")
+	if err != nil {
+		return err
+	}
+	communication.LoginSuccess(tokens.IDToken)
+	return nil
+}
+
 func init() {
+	loginCmd.Flags().BoolVar(&loginWithBrowser, "browser", false, "log in using the browser opened automatically instead of entering the code")
+	loginCmd.Flags().BoolVar(&loginWithToken, "with-token", false, "read the access token, refresh token or JSON with both from stdin instead of logging in with the browser")
 	accountCmd.AddCommand(loginCmd)
 }
@@ -15,7 +15,9 @@ var logoutCmd = &cobra.Command{
 	Short: "Log out from your account",
 	Long: `This command deletes all the locally stored tokens which allows you to re-login or simply stay logged out.
 
-In regular scenario you should not need to use it, as tokens are getting refreshed automatically.`,
+In regular scenario you should not need to use it, as tokens are getting refreshed automatically.
+
+Only tokens of the active profile are deleted, use --profile flag to log out from another one.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if !token.IsTokenSaved() {
 			communication.LogoutFailure(fmt.Errorf("Not logged in, nothing to do"))
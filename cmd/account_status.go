@@ -0,0 +1,79 @@
+// +build !desktop
+
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/loophole/cli/internal/pkg/communication"
+	"github.com/loophole/cli/internal/pkg/token"
+	"github.com/spf13/cobra"
+)
+
+var statusForceRefresh bool
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the logged in account and state of its tokens",
+	Long: `Shows the identity of the logged in user, expiry of the access token and the result of refreshing it.
+
+The token is refreshed only when it's about to expire, use --refresh to refresh it anyway.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !token.IsTokenSaved() {
+			communication.Fatal("Not logged in")
+		}
+
+		claims, err := token.GetIDTokenClaims()
+		if err != nil {
+			communication.Warn(err.Error())
+		} else {
+			identity := claims.Email
+			if identity == "" {
+				identity = claims.Nickname
+			}
+			if claims.Name != "" && claims.Name != identity {
+				identity = fmt.Sprintf("%s (%s)", claims.Name, identity)
+			}
+			communication.Info(fmt.Sprintf("Logged in as: %s", identity))
+			communication.Info(fmt.Sprintf("User ID: %s", claims.Subject))
+		}
+
+		expiresAt, err := token.ExpiresAt()
+		if err != nil {
+			communication.Fatal(err.Error())
+		}
+		communication.Info(fmt.Sprintf("Access token expires: %s", describeExpiry(expiresAt)))
+
+		if statusForceRefresh {
+			_, err = token.RefreshToken()
+		} else {
+			_, err = token.GetAccessToken()
+		}
+		if err != nil {
+			communication.Fatal(fmt.Sprintf("Refreshing token failed: %s", err.Error()))
+		}
+		refreshedExpiresAt, _ := token.ExpiresAt()
+		if refreshedExpiresAt.Equal(expiresAt) {
+			communication.Info("Token refresh: not needed yet")
+		} else {
+			communication.Info(fmt.Sprintf("Token refresh: succeeded, new token expires %s", describeExpiry(refreshedExpiresAt)))
+		}
+	},
+}
+
+func describeExpiry(expiresAt time.Time) string {
+	if expiresAt.IsZero() {
+		return "unknown"
+	}
+	remaining := time.Until(expiresAt).Round(time.Second)
+	if remaining <= 0 {
+		return fmt.Sprintf("%s (expired)", expiresAt.Local().Format(time.RFC1123))
+	}
+	return fmt.Sprintf("%s (in %s)", expiresAt.Local().Format(time.RFC1123), remaining)
+}
+
+func init() {
+	statusCmd.Flags().BoolVar(&statusForceRefresh, "refresh", false, "refresh the token even if it's not about to expire")
+	accountCmd.AddCommand(statusCmd)
+}
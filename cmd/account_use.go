@@ -0,0 +1,37 @@
+// +build !desktop
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/loophole/cli/internal/pkg/communication"
+	"github.com/loophole/cli/internal/pkg/profile"
+	"github.com/loophole/cli/internal/pkg/token"
+	"github.com/spf13/cobra"
+)
+
+var useProfileCmd = &cobra.Command{
+	Use:   "use <profile>",
+	Short: "Switch the active profile",
+	Long: fmt.Sprintf(`Makes the profile active for all following commands.
+
+It can be overridden for a single command with --profile flag or %s environment variable.`, profile.EnvVar),
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		err := profile.Use(name)
+		if err != nil {
+			communication.Fatal(err.Error())
+		}
+		communication.Info(fmt.Sprintf("Switched to profile '%s'", name))
+		if !token.IsTokenSavedFor(name) {
+			communication.Info(fmt.Sprintf("Not logged in yet, please use `%s account login` to log in", os.Args[0]))
+		}
+	},
+}
+
+func init() {
+	accountCmd.AddCommand(useProfileCmd)
+}
@@ -0,0 +1,80 @@
+// +build !desktop
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/loophole/cli/internal/app/loophole"
+	lm "github.com/loophole/cli/internal/app/loophole/models"
+	"github.com/loophole/cli/internal/pkg/communication"
+	"github.com/loophole/cli/internal/pkg/token"
+
+	"github.com/spf13/cobra"
+)
+
+var dropboxEndpointSpecs lm.LocalDirectorySpecs
+
+var dropboxCmd = &cobra.Command{
+	Use:   "dropbox <path>",
+	Short: "Let the public upload files into given directory",
+	Long: `Exposes an upload page via loophole tunnel, uploaded files are stored in the given local directory.
+
+Existing content of the directory is never listed nor served, files with the same name are not overwritten but stored under a new name.
+
+To collect files into local directory (e.g. /data/uploads) simply use 'loophole dropbox /data/uploads'.
+Uploads larger than 1GB are rejected unless other limit is set with '--max-upload-size'.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		loggedIn := token.IsTokenSaved()
+		idToken := token.GetIdToken()
+		communication.ApplicationStart(loggedIn, idToken)
+
+		checkVersion()
+
+		dropboxEndpointSpecs.Path = args[0]
+		quitChannel := make(chan bool)
+
+		exposeConfig := lm.ExposeDropboxConfig{
+			Local:  dropboxEndpointSpecs,
+			Remote: remoteEndpointSpecs,
+		}
+
+		authMethod, err := loophole.RegisterTunnel(&exposeConfig.Remote)
+		if err != nil {
+			communication.Fatal(err.Error())
+		}
+		startMaintenanceControl(exposeConfig.Remote.TunnelID)
+
+		loophole.ForwardDirectoryViaDropbox(exposeConfig, authMethod, quitChannel)
+	},
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return errors.New("Missing argument: path")
+		}
+		return nil
+	},
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyProfile(cmd.Flags()); err != nil {
+			return err
+		}
+		if err := parseConnectionFlags(); err != nil {
+			return err
+		}
+		maxUploadSize, err := parseByteSize(dropboxMaxUploadSize)
+		if err != nil {
+			return fmt.Errorf("Invalid maximum upload size: %v", err)
+		}
+		dropboxEndpointSpecs.MaxFileSize = maxUploadSize
+		return parseBasicAuthFlags(cmd.Flags())
+	},
+}
+
+var dropboxMaxUploadSize string
+
+func init() {
+	initServeCommand(dropboxCmd)
+	dropboxCmd.Flags().StringVar(&dropboxMaxUploadSize, "max-upload-size", "", "maximum size of files uploaded at once, e.g. 5GB (default 1GB)")
+
+	rootCmd.AddCommand(dropboxCmd)
+}
@@ -0,0 +1,76 @@
+// +build !desktop
+
+package cmd
+
+import (
+	"errors"
+
+	"github.com/loophole/cli/internal/app/loophole"
+	lm "github.com/loophole/cli/internal/app/loophole/models"
+	"github.com/loophole/cli/internal/pkg/communication"
+	"github.com/loophole/cli/internal/pkg/token"
+	"github.com/spf13/cobra"
+)
+
+var fileEndpointSpecs lm.LocalFileSpecs
+
+var fileCmd = &cobra.Command{
+	Use:   "file <path>",
+	Short: "Expose single file to the public",
+	Long: `Exposes single local file to the public via loophole tunnel.
+
+The tunnel is closed automatically once the file gets downloaded the allowed number of times or the sharing time expires.
+
+To send a file to one person (e.g. /data/report.pdf) use 'loophole file /data/report.pdf --max-downloads 1 --expire 1h'.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		loggedIn := token.IsTokenSaved()
+		idToken := token.GetIdToken()
+		communication.ApplicationStart(loggedIn, idToken)
+
+		checkVersion()
+
+		fileEndpointSpecs.Path = args[0]
+		quitChannel := make(chan bool)
+
+		exposeConfig := lm.ExposeFileConfig{
+			Local:  fileEndpointSpecs,
+			Remote: remoteEndpointSpecs,
+		}
+
+		authMethod, err := loophole.RegisterTunnel(&exposeConfig.Remote)
+		if err != nil {
+			communication.Fatal(err.Error())
+		}
+		startMaintenanceControl(exposeConfig.Remote.TunnelID)
+
+		loophole.ForwardFile(exposeConfig, authMethod, quitChannel)
+	},
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return errors.New("Missing argument: path")
+		}
+		if fileEndpointSpecs.MaxDownloads < 0 {
+			return errors.New("Maximum downloads can't be negative")
+		}
+		if cmd.Flags().Changed("expire") && fileEndpointSpecs.Expire <= 0 {
+			return errors.New("Expiry time must be positive")
+		}
+		return nil
+	},
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyProfile(cmd.Flags()); err != nil {
+			return err
+		}
+		if err := parseConnectionFlags(); err != nil {
+			return err
+		}
+		return parseBasicAuthFlags(cmd.Flags())
+	},
+}
+
+func init() {
+	initServeCommand(fileCmd)
+	fileCmd.Flags().IntVar(&fileEndpointSpecs.MaxDownloads, "max-downloads", 0, "close the tunnel after the file gets downloaded given number of times, 0 means no limit")
+	fileCmd.Flags().DurationVar(&fileEndpointSpecs.Expire, "expire", 0, "close the tunnel after given time, e.g. 30m or 1h")
+	rootCmd.AddCommand(fileCmd)
+}
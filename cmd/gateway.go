@@ -0,0 +1,172 @@
+// +build !desktop
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+
+	"github.com/loophole/cli/config"
+	"github.com/loophole/cli/internal/app/gateway"
+	"github.com/loophole/cli/internal/pkg/cache"
+	"github.com/loophole/cli/internal/pkg/communication"
+	"github.com/loophole/cli/internal/pkg/keys"
+	"github.com/loophole/cli/internal/pkg/token"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+)
+
+var gatewayConfig gateway.Config
+var gatewayHostKeyFile string
+var gatewayUsers []string
+var gatewayPublicHost string
+var gatewayPrintTokens bool
+
+var gatewayCmd = &cobra.Command{
+	Use:   "gateway",
+	Short: "Run self-hosted gateway",
+	Long: fmt.Sprintf(`Runs minimal loophole-compatible gateway, e.g. for air-gapped environments or local testing.
+
+The gateway accepts tunnels over SSH, serves the API used by clients together with SSH over WebSocket at /ssh on HTTP address
+and passes HTTPS connections to the tunnels by server name. Plain HTTP requests for sites are redirected to HTTPS.
+Sites are kept in memory and registered again by clients when they start tunnels.
+
+Every user given with --user gets an access token, kept in ~/.loophole/gateway/tokens.json together with the host key.
+Clients are pointed at the gateway with %s, %s and %s environment variables, the gateway prints them on start,
+the tokens are printed only with --print-tokens.
+Sites are served as <hostname>.<domain>, so the domain has to resolve to the gateway, e.g. with /etc/hosts entries.
+Certificates are obtained by clients with ACME, use %s for private certificate authority.`,
+		config.APIURLEnvVar, config.GatewayURLEnvVar, token.TokenEnvVar, config.ACMEDirectoryURLEnvVar),
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if gatewayHostKeyFile == "" {
+			gatewayHostKeyFile = cache.GetLocalStorageFile("ssh_host_ed25519_key", "gateway")
+		}
+		hostKey, err := loadGatewayHostKey(gatewayHostKeyFile)
+		if err != nil {
+			communication.Fatal(err.Error())
+		}
+		gatewayConfig.HostKey = hostKey
+
+		tokensFile := cache.GetLocalStorageFile("tokens.json", "gateway")
+		tokens, err := loadGatewayTokens(tokensFile, gatewayUsers)
+		if err != nil {
+			communication.Fatal(err.Error())
+		}
+		gatewayConfig.Tokens = map[string]string{}
+		for user, accessToken := range tokens {
+			gatewayConfig.Tokens[accessToken] = user
+		}
+
+		server, err := gateway.New(gatewayConfig)
+		if err != nil {
+			communication.Fatal(err.Error())
+		}
+		printGatewayUsage(server, tokensFile, tokens)
+
+		err = server.Serve()
+		if err != nil {
+			communication.Fatal(err.Error())
+		}
+	},
+}
+
+// loadGatewayHostKey reads the host key, generating it on first start
+func loadGatewayHostKey(file string) (ssh.Signer, error) {
+	privateKey, err := ioutil.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		var publicKey []byte
+		privateKey, publicKey, err = keys.GenerateKeyPair(keys.KeyTypeEd25519, 0, "loophole-gateway")
+		if err != nil {
+			return nil, err
+		}
+		err = keys.WriteKeyPair(file, privateKey, publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("There was a problem saving host key: %v", err)
+		}
+		communication.Info(fmt.Sprintf("Generated host key '%s'", file))
+	} else if err != nil {
+		return nil, fmt.Errorf("There was a problem reading host key: %v", err)
+	}
+	return ssh.ParsePrivateKey(privateKey)
+}
+
+// loadGatewayTokens returns access tokens of the users, issuing them for new users
+func loadGatewayTokens(file string, users []string) (map[string]string, error) {
+	tokens := map[string]string{}
+	content, err := ioutil.ReadFile(file)
+	if err == nil {
+		err = json.Unmarshal(content, &tokens)
+		if err != nil {
+			return nil, fmt.Errorf("There was a problem decoding access tokens: %v", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("There was a problem reading access tokens: %v", err)
+	}
+
+	changed := false
+	for _, user := range users {
+		if _, ok := tokens[user]; ok {
+			continue
+		}
+		tokens[user], err = gateway.NewAccessToken(user)
+		if err != nil {
+			return nil, err
+		}
+		changed = true
+	}
+	if changed {
+		content, err = json.MarshalIndent(tokens, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		err = ioutil.WriteFile(file, content, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("There was a problem saving access tokens: %v", err)
+		}
+	}
+	return tokens, nil
+}
+
+func printGatewayUsage(server *gateway.Gateway, tokensFile string, tokens map[string]string) {
+	port := func(addr net.Addr) int {
+		return addr.(*net.TCPAddr).Port
+	}
+	fmt.Printf("Gateway is running, sites are served as <hostname>.%s on port %d\n\n", gatewayConfig.Domain, port(server.HTTPSAddr()))
+	fmt.Println("Configure the clients with:")
+	fmt.Printf("  export %s=http://%s:%d\n", config.APIURLEnvVar, gatewayPublicHost, port(server.HTTPAddr()))
+	fmt.Printf("  export %s=ssh://%s:%d\n", config.GatewayURLEnvVar, gatewayPublicHost, port(server.SSHAddr()))
+	fmt.Printf("  export %s=ws://%s:%d/ssh\n\n", config.GatewayWebSocketURLEnvVar, gatewayPublicHost, port(server.HTTPAddr()))
+
+	if !gatewayPrintTokens {
+		fmt.Printf("Access tokens of the users, to be set as %s, are kept in '%s'\n", token.TokenEnvVar, tokensFile)
+		return
+	}
+
+	users := []string{}
+	for user := range tokens {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+	for _, user := range users {
+		fmt.Printf("Access token of '%s':\n  export %s=%s\n", user, token.TokenEnvVar, tokens[user])
+	}
+}
+
+func init() {
+	gatewayCmd.Flags().StringVar(&gatewayConfig.SSHAddress, "ssh-address", ":8022", "address tunnels are accepted on")
+	gatewayCmd.Flags().StringVar(&gatewayConfig.HTTPAddress, "http-address", ":80", "address of the API, SSH over WebSocket and redirects to HTTPS")
+	gatewayCmd.Flags().StringVar(&gatewayConfig.HTTPSAddress, "https-address", ":443", "address the sites are served on")
+	gatewayCmd.Flags().StringVar(&gatewayConfig.Domain, "domain", "loophole.localhost", "domain the sites are served under")
+	gatewayCmd.Flags().StringVar(&gatewayPublicHost, "public-host", "localhost", "host the clients reach the gateway at, used in printed configuration")
+	gatewayCmd.Flags().StringVar(&gatewayHostKeyFile, "host-key", "", "SSH host key, ~/.loophole/gateway/ssh_host_ed25519_key generated on first start by default")
+	gatewayCmd.MarkFlagFilename("host-key")
+	gatewayCmd.Flags().BoolVar(&gatewayPrintTokens, "print-tokens", false, "print access tokens of the users on start")
+	gatewayCmd.Flags().StringSliceVar(&gatewayUsers, "user", []string{"loophole"}, "users allowed to register sites, access token is issued for each")
+	rootCmd.AddCommand(gatewayCmd)
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/loophole/cli/internal/app/loophole"
 	lm "github.com/loophole/cli/internal/app/loophole/models"
 	"github.com/loophole/cli/internal/pkg/communication"
+	"github.com/loophole/cli/internal/pkg/keys"
 	"github.com/loophole/cli/internal/pkg/token"
 
 	"github.com/spf13/cobra"
@@ -48,6 +49,7 @@ To expose port running on some local host e.g. 192.168.1.20 use 'loophole http <
 		if err != nil {
 			communication.Fatal(err.Error())
 		}
+		startMaintenanceControl(exposeConfig.Remote.TunnelID)
 
 		loophole.ForwardPort(exposeConfig, authMethod, quitChannel)
 	},
@@ -59,9 +61,19 @@ To expose port running on some local host e.g. 192.168.1.20 use 'loophole http <
 		if err != nil {
 			return fmt.Errorf("Invalid argument: port: %v", err)
 		}
+		// --use-agent doesn't take a value, so the fingerprint would be taken as the host
+		if len(args) > 1 && keys.LooksLikeFingerprint(args[1]) {
+			return fmt.Errorf("Invalid argument: host: '%s' is a key fingerprint, select SSH agent key with --agent-key %s", args[1], args[1])
+		}
 		return nil
 	},
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyProfile(cmd.Flags()); err != nil {
+			return err
+		}
+		if err := parseConnectionFlags(); err != nil {
+			return err
+		}
 		return parseBasicAuthFlags(cmd.Flags())
 	},
 }
@@ -0,0 +1,46 @@
+// +build !desktop
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// parseHTTPArgs parses the command line like 'loophole http' does, returning the error of argument validation
+func parseHTTPArgs(t *testing.T, arguments ...string) ([]string, error) {
+	command := &cobra.Command{Use: httpCmd.Use, Args: httpCmd.Args}
+	initServeCommand(command)
+	err := command.ParseFlags(arguments)
+	if err != nil {
+		t.Fatalf("Expected flags to be parsed, got: %v", err)
+	}
+	args := command.Flags().Args()
+	return args, command.Args(command, args)
+}
+
+func TestAgentKeyIsGivenSeparatedBySpace(t *testing.T) {
+	args, err := parseHTTPArgs(t, "3000", "--agent-key", "SHA256:abc")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(args) != 1 || remoteEndpointSpecs.AgentKeyFingerprint != "SHA256:abc" {
+		t.Fatalf("Expected fingerprint to be used as agent key, got args %v and key '%s'", args, remoteEndpointSpecs.AgentKeyFingerprint)
+	}
+}
+
+func TestFingerprintAfterUseAgentIsNotTakenAsHost(t *testing.T) {
+	_, err := parseHTTPArgs(t, "3000", "--use-agent", "SHA256:abc")
+	if err == nil {
+		t.Fatalf("Expected fingerprint given after --use-agent to be rejected")
+	}
+	if !remoteEndpointSpecs.UseAgent || remoteEndpointSpecs.AgentKeyFingerprint != "" {
+		t.Fatalf("Expected --use-agent to be set without a key")
+	}
+
+	args, err := parseHTTPArgs(t, "3000", "192.168.1.20", "--use-agent")
+	if err != nil || len(args) != 2 {
+		t.Fatalf("Expected host to be accepted together with --use-agent, got: %v", err)
+	}
+}
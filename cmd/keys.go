@@ -0,0 +1,35 @@
+// +build !desktop
+
+package cmd
+
+import (
+	"github.com/loophole/cli/internal/pkg/keys"
+	"github.com/loophole/cli/internal/pkg/profile"
+	"github.com/spf13/cobra"
+)
+
+var keysIdentityFile string
+
+// keysCmd represents the keys command
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Group of commands managing SSH keys",
+	Long: `Parent for commands managing SSH keys used to authenticate tunnels. Always use with one of subcommands
+
+Keys are kept in SSH directory of the active profile, the first of id_ed25519, id_ecdsa and id_rsa is used by default.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+// identityFile returns the identity selected with --identity-file or the default one of the active profile
+func identityFile() string {
+	if keysIdentityFile != "" {
+		return keysIdentityFile
+	}
+	return keys.DefaultIdentityFile(profile.SSHDir())
+}
+
+func init() {
+	rootCmd.AddCommand(keysCmd)
+}
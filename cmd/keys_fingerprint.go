@@ -0,0 +1,30 @@
+// +build !desktop
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/loophole/cli/internal/pkg/communication"
+	"github.com/loophole/cli/internal/pkg/keys"
+	"github.com/spf13/cobra"
+)
+
+var fingerprintKeyCmd = &cobra.Command{
+	Use:   "fingerprint",
+	Short: "Show fingerprint of SSH key",
+	Long:  "Shows SHA256 fingerprint of the key used by default, or the one given with --identity-file.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fingerprint, err := keys.Fingerprint(identityFile())
+		if err != nil {
+			communication.Fatal(fmt.Sprintf("There was a problem reading the key: %s", err.Error()))
+		}
+		fmt.Println(fingerprint)
+	},
+}
+
+func init() {
+	fingerprintKeyCmd.Flags().StringVarP(&keysIdentityFile, "identity-file", "i", "", "private key path")
+	keysCmd.AddCommand(fingerprintKeyCmd)
+}
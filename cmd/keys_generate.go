@@ -0,0 +1,55 @@
+// +build !desktop
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/loophole/cli/internal/pkg/communication"
+	"github.com/loophole/cli/internal/pkg/keys"
+	"github.com/loophole/cli/internal/pkg/profile"
+	"github.com/spf13/cobra"
+)
+
+var generateKeyType string
+var generateKeyBits int
+var generateKeyForce bool
+
+var generateKeyCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate new SSH key",
+	Long: `Generates new key pair in OpenSSH format, saved as id_<type> in SSH directory of the active profile unless --identity-file is given.
+
+Ed25519 keys are generated by default, ECDSA and RSA keys are supported for compatibility.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		file := keysIdentityFile
+		if file == "" {
+			file = filepath.Join(profile.SSHDir(), keys.IdentityFileName(generateKeyType))
+		}
+		if _, err := os.Stat(file); err == nil && !generateKeyForce {
+			communication.Fatal(fmt.Sprintf("Identity '%s' already exists, use --force to replace it or 'keys rotate' to replace it everywhere", file))
+		}
+
+		private, public, err := keys.GenerateKeyPair(generateKeyType, generateKeyBits, keys.DefaultComment())
+		if err != nil {
+			communication.Fatal(err.Error())
+		}
+		err = keys.WriteKeyPair(file, private, public)
+		if err != nil {
+			communication.Fatal(fmt.Sprintf("There was a problem writing identity '%s': %s", file, err.Error()))
+		}
+		fingerprint, _ := keys.Fingerprint(file)
+		communication.Info(fmt.Sprintf("Generated %s key '%s' (%s)", generateKeyType, file, fingerprint))
+	},
+}
+
+func init() {
+	generateKeyCmd.Flags().StringVarP(&keysIdentityFile, "identity-file", "i", "", "path of the private key to be generated")
+	generateKeyCmd.Flags().StringVarP(&generateKeyType, "type", "t", keys.DefaultKeyType, fmt.Sprintf("type of the key, one of %s, %s and %s", keys.KeyTypeEd25519, keys.KeyTypeECDSA, keys.KeyTypeRSA))
+	generateKeyCmd.Flags().IntVarP(&generateKeyBits, "bits", "b", 0, "size of ECDSA (256, 384 or 521) or RSA key, default size is used when not set")
+	generateKeyCmd.Flags().BoolVar(&generateKeyForce, "force", false, "replace existing key")
+	keysCmd.AddCommand(generateKeyCmd)
+}
@@ -0,0 +1,48 @@
+// +build !desktop
+
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/loophole/cli/internal/pkg/communication"
+	"github.com/loophole/cli/internal/pkg/keys"
+	"github.com/loophole/cli/internal/pkg/profile"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+)
+
+var importKeyForce bool
+
+var importKeyCmd = &cobra.Command{
+	Use:   "import <private key>",
+	Short: "Import existing SSH key",
+	Long: `Copies existing private key into SSH directory of the active profile, so that it's used for tunnels.
+
+The key is saved as id_<type> unless --identity-file is given. Keys protected with passphrase need the public key next to them with .pub extension.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		source := args[0]
+		publicKey, _, err := keys.ReadPublicKey(source)
+		if err != nil {
+			communication.Fatal(fmt.Sprintf("There was a problem reading the key: %s", err.Error()))
+		}
+		destination := keysIdentityFile
+		if destination == "" {
+			destination = filepath.Join(profile.SSHDir(), keys.IdentityFileNameFor(publicKey))
+		}
+
+		_, err = keys.ImportKey(source, destination, importKeyForce)
+		if err != nil {
+			communication.Fatal(err.Error())
+		}
+		communication.Info(fmt.Sprintf("Imported key '%s' (%s)", destination, ssh.FingerprintSHA256(publicKey)))
+	},
+}
+
+func init() {
+	importKeyCmd.Flags().StringVarP(&keysIdentityFile, "identity-file", "i", "", "path the key is saved at")
+	importKeyCmd.Flags().BoolVar(&importKeyForce, "force", false, "replace existing key")
+	keysCmd.AddCommand(importKeyCmd)
+}
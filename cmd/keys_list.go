@@ -0,0 +1,41 @@
+// +build !desktop
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/loophole/cli/internal/pkg/communication"
+	"github.com/loophole/cli/internal/pkg/keys"
+	"github.com/loophole/cli/internal/pkg/profile"
+	"github.com/spf13/cobra"
+)
+
+var listKeysCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List SSH keys",
+	Long:  "Lists keys in SSH directory of the active profile, the one used by default is marked with an asterisk.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		identities, err := keys.ListIdentities(profile.SSHDir())
+		if err != nil {
+			communication.Fatal(err.Error())
+		}
+		if len(identities) == 0 {
+			communication.Info("No keys found, one will be generated when starting the first tunnel")
+			return
+		}
+		defaultIdentity := keys.DefaultIdentityFile(profile.SSHDir())
+		for _, identity := range identities {
+			marker := " "
+			if identity.Path == defaultIdentity {
+				marker = "*"
+			}
+			fmt.Printf("%s %s %s %s %s\n", marker, identity.Path, identity.Type, identity.Fingerprint, identity.Comment)
+		}
+	},
+}
+
+func init() {
+	keysCmd.AddCommand(listKeysCmd)
+}
@@ -0,0 +1,114 @@
+// +build !desktop
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/loophole/cli/internal/pkg/apiclient"
+	"github.com/loophole/cli/internal/pkg/communication"
+	"github.com/loophole/cli/internal/pkg/keys"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+)
+
+var rotateKeyType string
+var rotateHostnames []string
+
+var rotateKeyCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Replace SSH key with a new one",
+	Long: `Generates new key in place of the one used by default, or the one given with --identity-file, and registers it.
+
+The new key is registered for every hostname given with --hostname, when none is given for every hostname reserved by you.
+If the registration fails the old key is restored and registered again for the hostnames already switched to the new key,
+otherwise it's removed.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		oldFile := identityFile()
+		newFile := oldFile
+		if keysIdentityFile == "" && rotateKeyType != "" {
+			newFile = filepath.Join(filepath.Dir(oldFile), keys.IdentityFileName(rotateKeyType))
+		}
+		if _, err := os.Stat(newFile); err == nil && newFile != oldFile {
+			communication.Fatal(fmt.Sprintf("Identity '%s' already exists, remove it first", newFile))
+		}
+		keyType := rotateKeyType
+		if keyType == "" {
+			keyType = keys.DefaultKeyType
+		}
+
+		hostnames, err := rotatedHostnames()
+		if err != nil {
+			communication.Fatal(err.Error())
+		}
+		oldPublicKey, _, err := keys.ReadPublicKey(oldFile)
+		if err != nil {
+			communication.Fatal(fmt.Sprintf("There was a problem reading the key to rotate: %s", err.Error()))
+		}
+
+		publicKey, err := keys.RotateKey(oldFile, newFile, keyType, 0)
+		if err != nil {
+			communication.Fatal(err.Error())
+		}
+
+		for i, hostname := range hostnames {
+			_, err := apiclient.RegisterSite(publicKey, hostname)
+			if err != nil {
+				communication.Error(fmt.Sprintf("There was a problem registering the new key for '%s': %s", hostname, err.Error()))
+				if !revertRegistrations(oldPublicKey, hostnames[:i]) {
+					communication.Fatal(fmt.Sprintf("The new key was kept as '%s' and the old one as '%s%s', finish the rotation once the problem is fixed", newFile, oldFile, keys.BackupExtension))
+				}
+				keys.RestoreRotated(oldFile, newFile)
+				communication.Fatal("The old key was restored")
+			}
+			communication.Info(fmt.Sprintf("Registered new key for '%s'", hostname))
+		}
+		keys.RemoveRotated(oldFile)
+		communication.Info(fmt.Sprintf("Rotated key '%s' (%s)", newFile, ssh.FingerprintSHA256(publicKey)))
+	},
+}
+
+// rotatedHostnames returns the hostnames given with --hostname, or all hostnames reserved by the user
+func rotatedHostnames() ([]string, error) {
+	if len(rotateHostnames) > 0 {
+		return rotateHostnames, nil
+	}
+	sites, err := apiclient.ListSites()
+	if err != nil {
+		return nil, fmt.Errorf("There was a problem listing your hostnames: %v", err)
+	}
+	hostnames := []string{}
+	for _, site := range sites {
+		hostnames = append(hostnames, site.SiteID)
+	}
+	if len(hostnames) == 0 {
+		return nil, fmt.Errorf("You have no hostnames reserved, give the ones to register the new key for with --hostname")
+	}
+	return hostnames, nil
+}
+
+// revertRegistrations registers the old key again for hostnames already switched to the new one,
+// reporting the ones which are left with the new key
+func revertRegistrations(oldPublicKey ssh.PublicKey, hostnames []string) bool {
+	reverted := true
+	for _, hostname := range hostnames {
+		_, err := apiclient.RegisterSite(oldPublicKey, hostname)
+		if err != nil {
+			communication.Error(fmt.Sprintf("There was a problem registering the old key for '%s' again, the hostname is left with the new key: %s", hostname, err.Error()))
+			reverted = false
+			continue
+		}
+		communication.Info(fmt.Sprintf("Registered the old key for '%s' again", hostname))
+	}
+	return reverted
+}
+
+func init() {
+	rotateKeyCmd.Flags().StringVarP(&keysIdentityFile, "identity-file", "i", "", "private key path")
+	rotateKeyCmd.Flags().StringVarP(&rotateKeyType, "type", "t", "", fmt.Sprintf("type of the new key, one of %s, %s and %s, %s by default", keys.KeyTypeEd25519, keys.KeyTypeECDSA, keys.KeyTypeRSA, keys.DefaultKeyType))
+	rotateKeyCmd.Flags().StringSliceVar(&rotateHostnames, "hostname", []string{}, "hostnames to register the new key for, all hostnames reserved by you by default")
+	keysCmd.AddCommand(rotateKeyCmd)
+}
@@ -20,7 +20,8 @@ var dirCmd = &cobra.Command{
 	Short:   "Expose given directory to the public",
 	Long: `Exposes local directory to the public via loophole tunnel.
 
-To expose local directory (e.g. /data/my-data) simply use 'loophole path /data/my-data'.`,
+To expose local directory (e.g. /data/my-data) simply use 'loophole path /data/my-data'.
+To expose content of .zip, .tar or .tar.gz archive (read-only) use e.g. 'loophole path /data/logs.tar.gz'.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		loggedIn := token.IsTokenSaved()
 		idToken := token.GetIdToken()
@@ -40,6 +41,7 @@ To expose local directory (e.g. /data/my-data) simply use 'loophole path /data/m
 		if err != nil {
 			communication.Fatal(err.Error())
 		}
+		startMaintenanceControl(exposeConfig.Remote.TunnelID)
 
 		loophole.ForwardDirectory(exposeConfig, authMethod, quitChannel)
 	},
@@ -50,11 +52,20 @@ To expose local directory (e.g. /data/my-data) simply use 'loophole path /data/m
 		return nil
 	},
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyProfile(cmd.Flags()); err != nil {
+			return err
+		}
+		if err := parseConnectionFlags(); err != nil {
+			return err
+		}
 		return parseBasicAuthFlags(cmd.Flags())
 	},
 }
 
 func init() {
 	initServeCommand(dirCmd)
+	dirCmd.Flags().BoolVar(&dirEndpointSpecs.SPA, "spa", false, "serve index.html for unknown paths, use when exposing single-page application build")
+	dirCmd.Flags().BoolVar(&dirEndpointSpecs.DisableDirectoryListing, "disable-directory-listing", false, "return 404 instead of listing directories without index.html")
+	initDirectoryFilterFlags(dirCmd, &dirEndpointSpecs)
 	rootCmd.AddCommand(dirCmd)
 }
@@ -10,6 +10,7 @@ import (
 
 	"github.com/loophole/cli/config"
 	"github.com/loophole/cli/internal/pkg/cache"
+	"github.com/loophole/cli/internal/pkg/profile"
 	"github.com/mattn/go-colorable"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -17,6 +18,8 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var profileName string
+
 var rootCmd = &cobra.Command{
 	Use:   "loophole",
 	Short: "Loophole - End to end TLS encrypted TCP communication between you and your clients",
@@ -27,9 +30,29 @@ var rootCmd = &cobra.Command{
 }
 
 func init() {
-	cobra.OnInitialize(initLogger)
+	cobra.OnInitialize(initLogger, initConfig, initProfile)
 
 	rootCmd.PersistentFlags().BoolVarP(&config.Config.Display.Verbose, "verbose", "v", false, "verbose output")
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "", fmt.Sprintf("profile to use, overrides %s and the one selected with 'account use'", profile.EnvVar))
+}
+
+func initConfig() {
+	err := config.ApplyOverrides()
+	if err != nil {
+		stdlog.Fatalln(err)
+	}
+}
+
+func initProfile() {
+	var err error
+	if profileName != "" {
+		err = profile.Set(profileName)
+	} else {
+		_, err = profile.Current()
+	}
+	if err != nil {
+		stdlog.Fatalln(err)
+	}
 }
 
 func initLogger() {
@@ -0,0 +1,60 @@
+// +build !desktop
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/loophole/cli/internal/pkg/communication"
+	"github.com/loophole/cli/internal/pkg/sharelink"
+	"github.com/spf13/cobra"
+)
+
+var shareLinkTTL time.Duration
+var shareLinkMaxDownloads int
+var shareLinkSiteID string
+
+var shareLinkCmd = &cobra.Command{
+	Use:   "share-link <file>",
+	Short: "Create expiring link to a file exposed with 'loophole path'",
+	Long: `Creates signed link to a single file inside of directory exposed with 'loophole path'.
+
+The link works without basic auth credentials of the tunnel, but only until it expires or gets downloaded the allowed number of times.
+Links are signed with a secret kept per shared directory and hostname, so they keep working, together with their download limits,
+when the tunnel is restarted with the same directory and hostname. Sharing another directory with the hostname invalidates the links.
+
+To share a file for two hours use e.g. 'loophole share-link /data/my-data/report.pdf --ttl 2h'.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		tunnel, err := sharelink.Find(args[0], shareLinkSiteID)
+		if err != nil {
+			communication.Fatal(err.Error())
+		}
+		link, err := tunnel.Link(args[0], shareLinkTTL, shareLinkMaxDownloads)
+		if err != nil {
+			communication.Fatal(fmt.Sprintf("There was a problem creating share link: %s", err.Error()))
+		}
+		fmt.Println(link)
+	},
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return errors.New("Missing argument: file")
+		}
+		if shareLinkTTL <= 0 {
+			return errors.New("TTL must be positive")
+		}
+		if shareLinkMaxDownloads < 0 {
+			return errors.New("Maximum downloads can't be negative")
+		}
+		return nil
+	},
+}
+
+func init() {
+	shareLinkCmd.Flags().DurationVar(&shareLinkTTL, "ttl", time.Hour, "time after which the link expires, e.g. 30m or 2h")
+	shareLinkCmd.Flags().IntVar(&shareLinkMaxDownloads, "max-downloads", 0, "number of allowed downloads, 0 means no limit")
+	shareLinkCmd.Flags().StringVar(&shareLinkSiteID, "hostname", "", "hostname of the tunnel exposing the file, by default the most recently started one is used")
+
+	rootCmd.AddCommand(shareLinkCmd)
+}
@@ -0,0 +1,37 @@
+// +build !desktop
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/loophole/cli/internal/pkg/apiclient"
+	"github.com/loophole/cli/internal/pkg/communication"
+	"github.com/spf13/cobra"
+)
+
+// sitesCmd represents the sites command
+var sitesCmd = &cobra.Command{
+	Use:   "sites",
+	Short: "Group of commands managing reserved hostnames",
+	Long: `Parent for commands managing hostnames reserved with your account. Always use with one of subcommands
+
+Reserved hostnames can be used with --hostname flag when starting tunnels.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+// sitesRequestFailure reports the failed request with details given by API and exits
+func sitesRequestFailure(err error) {
+	if requestErr, ok := err.(apiclient.RequestError); ok {
+		communication.Error(fmt.Sprintf("Request ended with status code %d", requestErr.StatusCode))
+		communication.Error(requestErr.Message)
+		communication.Fatal(fmt.Sprintf("Details: %s", requestErr.Details))
+	}
+	communication.Fatal(fmt.Sprintf("There was a problem communicating with API: %v", err))
+}
+
+func init() {
+	rootCmd.AddCommand(sitesCmd)
+}
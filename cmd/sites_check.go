@@ -0,0 +1,36 @@
+// +build !desktop
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/loophole/cli/internal/pkg/apiclient"
+	"github.com/loophole/cli/internal/pkg/communication"
+	"github.com/spf13/cobra"
+)
+
+var checkSiteCmd = &cobra.Command{
+	Use:   "check <hostname>",
+	Short: "Check whether hostname is available",
+	Long: `Checks whether the hostname can be used with your account before starting a tunnel.
+
+Exits with status 1 when the hostname is taken by different user, which makes it usable in scripts.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		available, err := apiclient.CheckSite(args[0])
+		if err != nil {
+			sitesRequestFailure(err)
+		}
+		if !available {
+			communication.Info(fmt.Sprintf("Hostname '%s' is already taken", args[0]))
+			os.Exit(1)
+		}
+		communication.Info(fmt.Sprintf("Hostname '%s' is available", args[0]))
+	},
+}
+
+func init() {
+	sitesCmd.AddCommand(checkSiteCmd)
+}
@@ -0,0 +1,36 @@
+// +build !desktop
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/loophole/cli/internal/pkg/apiclient"
+	"github.com/loophole/cli/internal/pkg/communication"
+	"github.com/loophole/cli/internal/pkg/urlmaker"
+	"github.com/spf13/cobra"
+)
+
+var listSitesCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List reserved hostnames",
+	Long:  "Lists hostnames reserved with your account together with their URLs.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		sites, err := apiclient.ListSites()
+		if err != nil {
+			sitesRequestFailure(err)
+		}
+		if len(sites) == 0 {
+			communication.Info("There are no reserved hostnames")
+			return
+		}
+		for _, site := range sites {
+			fmt.Printf("%s\t%s\n", site.SiteID, urlmaker.GetSiteURL("https", site.SiteID, site.Domain))
+		}
+	},
+}
+
+func init() {
+	sitesCmd.AddCommand(listSitesCmd)
+}
@@ -0,0 +1,29 @@
+// +build !desktop
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/loophole/cli/internal/pkg/apiclient"
+	"github.com/loophole/cli/internal/pkg/communication"
+	"github.com/spf13/cobra"
+)
+
+var releaseSiteCmd = &cobra.Command{
+	Use:   "release <hostname>",
+	Short: "Release reserved hostname",
+	Long:  "Releases the hostname reserved with your account, after that it can be taken by anyone.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := apiclient.ReleaseSite(args[0])
+		if err != nil {
+			sitesRequestFailure(err)
+		}
+		communication.Info(fmt.Sprintf("Released hostname '%s'", args[0]))
+	},
+}
+
+func init() {
+	sitesCmd.AddCommand(releaseSiteCmd)
+}
@@ -0,0 +1,29 @@
+// +build !desktop
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/loophole/cli/internal/pkg/apiclient"
+	"github.com/loophole/cli/internal/pkg/communication"
+	"github.com/spf13/cobra"
+)
+
+var reserveSiteCmd = &cobra.Command{
+	Use:   "reserve <hostname>",
+	Short: "Reserve hostname",
+	Long:  "Reserves the hostname for your account without starting a tunnel, so that nobody else can take it.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		site, err := apiclient.ReserveSite(args[0])
+		if err != nil {
+			sitesRequestFailure(err)
+		}
+		communication.Info(fmt.Sprintf("Reserved hostname '%s'", site.SiteID))
+	},
+}
+
+func init() {
+	sitesCmd.AddCommand(reserveSiteCmd)
+}
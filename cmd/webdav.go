@@ -4,10 +4,13 @@ package cmd
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/loophole/cli/internal/app/loophole"
 	lm "github.com/loophole/cli/internal/app/loophole/models"
 	"github.com/loophole/cli/internal/pkg/communication"
+	"github.com/loophole/cli/internal/pkg/httpserver"
 	"github.com/loophole/cli/internal/pkg/token"
 
 	"github.com/spf13/cobra"
@@ -22,7 +25,8 @@ var webdavCmd = &cobra.Command{
 
 This can then be even mounted on other machines in the Windows Explorer, macOS Finder, Linux Gnome Files or Linux KDE Konqueror etc.
 
-To expose local directory via webdav (e.g. /data/my-data) simply use 'loophole webdav /data/my-data'.`,
+To expose local directory via webdav (e.g. /data/my-data) simply use 'loophole webdav /data/my-data'.
+To let one user upload and others only download use e.g. 'loophole webdav /data/my-data --user uploader:secret:write --user reader:secret:read'.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		loggedIn := token.IsTokenSaved()
 		idToken := token.GetIdToken()
@@ -42,6 +46,7 @@ To expose local directory via webdav (e.g. /data/my-data) simply use 'loophole w
 		if err != nil {
 			communication.Fatal(err.Error())
 		}
+		startMaintenanceControl(exposeConfig.Remote.TunnelID)
 
 		loophole.ForwardDirectoryViaWebdav(exposeConfig, authMethod, quitChannel)
 	},
@@ -52,12 +57,73 @@ To expose local directory via webdav (e.g. /data/my-data) simply use 'loophole w
 		return nil
 	},
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyProfile(cmd.Flags()); err != nil {
+			return err
+		}
+		if err := parseConnectionFlags(); err != nil {
+			return err
+		}
+		users, err := parseWebdavUsers(webdavUsers)
+		if err != nil {
+			return err
+		}
+		webdavEndpointSpecs.Users = users
+		webdavEndpointSpecs.Quota, err = parseByteSize(webdavQuota)
+		if err != nil {
+			return fmt.Errorf("Invalid quota: %v", err)
+		}
+		webdavEndpointSpecs.MaxFileSize, err = parseByteSize(webdavMaxFileSize)
+		if err != nil {
+			return fmt.Errorf("Invalid maximum file size: %v", err)
+		}
 		return parseBasicAuthFlags(cmd.Flags())
 	},
 }
 
+var webdavUsers []string
+var webdavQuota string
+var webdavMaxFileSize string
+
+// parseWebdavUsers parses users given in username:password[:read|write] format
+func parseWebdavUsers(users []string) ([]lm.BasicAuthUser, error) {
+	result := []lm.BasicAuthUser{}
+	seen := map[string]bool{}
+	for _, user := range users {
+		parts := strings.SplitN(user, ":", 2)
+		if len(parts) < 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Invalid user '%s', expected username:password[:read|write]", user)
+		}
+		username, password := parts[0], parts[1]
+		if seen[username] {
+			return nil, fmt.Errorf("User '%s' is given more than once", username)
+		}
+		seen[username] = true
+		permission := httpserver.PermissionRead
+		for _, candidate := range []httpserver.Permission{httpserver.PermissionRead, httpserver.PermissionWrite} {
+			if strings.HasSuffix(password, ":"+string(candidate)) {
+				permission = candidate
+				password = strings.TrimSuffix(password, ":"+string(candidate))
+			}
+		}
+		if password == "" {
+			return nil, fmt.Errorf("Missing password for user '%s'", username)
+		}
+		result = append(result, lm.BasicAuthUser{
+			Username:   username,
+			Password:   password,
+			Permission: string(permission),
+		})
+	}
+	return result, nil
+}
+
 func init() {
 	initServeCommand(webdavCmd)
+	initDirectoryFilterFlags(webdavCmd, &webdavEndpointSpecs)
+	webdavCmd.Flags().BoolVar(&webdavEndpointSpecs.ReadOnly, "read-only", false, "reject all requests modifying the exposed directory")
+	webdavCmd.Flags().StringVar(&webdavQuota, "quota", "", "maximum total size of the exposed directory, e.g. 10GB")
+	webdavCmd.Flags().StringVar(&webdavMaxFileSize, "max-file-size", "", "maximum size of a single uploaded file, e.g. 500MB")
+	webdavCmd.Flags().StringArrayVar(&webdavUsers, "user", []string{}, "user allowed to access the share in username:password[:read|write] format, can be repeated (default permission is read)")
 
 	rootCmd.AddCommand(webdavCmd)
 }
@@ -0,0 +1,35 @@
+// +build !desktop
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWebdavUsersAreParsed(t *testing.T) {
+	users, err := parseWebdavUsers([]string{"alice:secret:write", "bob:pass:with:colons"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(users) != 2 || users[0].Permission != "write" || users[1].Password != "pass:with:colons" || users[1].Permission != "read" {
+		t.Fatalf("Expected users to be parsed, got: %v", users)
+	}
+}
+
+func TestDuplicateWebdavUserIsRejected(t *testing.T) {
+	if _, err := parseWebdavUsers([]string{"alice:secret:write", "alice:other:read"}); err == nil {
+		t.Fatalf("Expected error for user given more than once")
+	}
+}
+
+func TestInvalidByteSizeIsReportedAsGiven(t *testing.T) {
+	_, err := parseByteSize("10XB")
+	if err == nil || !strings.Contains(err.Error(), "'10XB'") {
+		t.Fatalf("Expected error to quote the given size, got: %v", err)
+	}
+	size, err := parseByteSize("1.5GiB")
+	if err != nil || size != 3<<29 {
+		t.Fatalf("Expected 1.5GiB to be parsed, got %d: %v", size, err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 // OAuthConfig defined OAuth settings shape
 type OAuthConfig struct {
+	AuthorizeURL  string `json:"authorizeUrl"`
 	DeviceCodeURL string `json:"deviceCodeUrl"`
 	TokenURL      string `json:"tokenUrl"`
 	ClientID      string `json:"clientId"`
@@ -25,11 +26,13 @@ type ApplicationConfig struct {
 	CommitHash string `json:"commitHash"`
 	ClientMode string `json:"clientMode"`
 
-	FeedbackFormURL string `json:"feedbackFormUrl"`
+	FeedbackFormURL  string `json:"feedbackFormUrl"`
+	ACMEDirectoryURL string `json:"acmeDirectoryUrl"`
 
 	OAuth   OAuthConfig   `json:"oauthConfig"`
 	Display DisplayConfig `json:"displayConfig"`
 
-	APIEndpoint     models.Endpoint `json:"apiConfig"`
-	GatewayEndpoint models.Endpoint `json:"gatewayConfig"`
+	APIEndpoint              models.Endpoint `json:"apiConfig"`
+	GatewayEndpoint          models.Endpoint `json:"gatewayConfig"`
+	GatewayWebSocketEndpoint models.Endpoint `json:"gatewayWebSocketConfig"`
 }
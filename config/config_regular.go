@@ -12,6 +12,7 @@ var Config = ApplicationConfig{
 	FeedbackFormURL: "https://bit.ly/3mvmZBA",
 
 	OAuth: OAuthConfig{
+		AuthorizeURL:  "https://loophole.eu.auth0.com/authorize",
 		DeviceCodeURL: "https://loophole.eu.auth0.com/oauth/device/code",
 		TokenURL:      "https://loophole.eu.auth0.com/oauth/token",
 		ClientID:      "9ocnSAnfJSb6C52waL8xcPidCkRhUwBs",
@@ -32,4 +33,10 @@ var Config = ApplicationConfig{
 		Host:     "gateway.loophole.host",
 		Port:     8022,
 	},
+	GatewayWebSocketEndpoint: models.Endpoint{
+		Protocol: "wss",
+		Host:     "gateway.loophole.host",
+		Port:     443,
+		Path:     "/ssh",
+	},
 }
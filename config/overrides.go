@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/loophole/cli/internal/app/loophole/models"
+)
+
+// Environment variables overriding the compiled in endpoints, e.g. to use self-hosted gateway
+const (
+	APIURLEnvVar              = "LOOPHOLE_API_URL"
+	GatewayURLEnvVar          = "LOOPHOLE_GATEWAY_URL"
+	GatewayWebSocketURLEnvVar = "LOOPHOLE_GATEWAY_WEBSOCKET_URL"
+	OAuthAuthorizeURLEnvVar   = "LOOPHOLE_OAUTH_AUTHORIZE_URL"
+	OAuthDeviceCodeURLEnvVar  = "LOOPHOLE_OAUTH_DEVICE_CODE_URL"
+	OAuthTokenURLEnvVar       = "LOOPHOLE_OAUTH_TOKEN_URL"
+	OAuthClientIDEnvVar       = "LOOPHOLE_OAUTH_CLIENT_ID"
+	OAuthAudienceEnvVar       = "LOOPHOLE_OAUTH_AUDIENCE"
+	ACMEDirectoryURLEnvVar    = "LOOPHOLE_ACME_DIRECTORY_URL"
+)
+
+var defaultPorts = map[string]int32{
+	"http":  80,
+	"ws":    80,
+	"https": 443,
+	"wss":   443,
+	"ssh":   8022,
+}
+
+// ApplyOverrides replaces the endpoints with the ones given in environment variables.
+// When only the gateway is overridden, WebSocket transport is expected on port 80 of the same host
+func ApplyOverrides() error {
+	return applyOverrides(os.LookupEnv)
+}
+
+func applyOverrides(lookup func(string) (string, bool)) error {
+	endpoints := []struct {
+		envVar   string
+		endpoint *models.Endpoint
+	}{
+		{APIURLEnvVar, &Config.APIEndpoint},
+		{GatewayURLEnvVar, &Config.GatewayEndpoint},
+		{GatewayWebSocketURLEnvVar, &Config.GatewayWebSocketEndpoint},
+	}
+	for _, override := range endpoints {
+		value, ok := lookup(override.envVar)
+		if !ok || value == "" {
+			continue
+		}
+		endpoint, err := ParseEndpoint(value)
+		if err != nil {
+			return fmt.Errorf("Invalid value of %s: %v", override.envVar, err)
+		}
+		*override.endpoint = *endpoint
+		if override.envVar == GatewayURLEnvVar {
+			if _, ok := lookup(GatewayWebSocketURLEnvVar); !ok {
+				Config.GatewayWebSocketEndpoint = models.Endpoint{
+					Protocol: "ws",
+					Host:     endpoint.Host,
+					Port:     defaultPorts["ws"],
+					Path:     "/ssh",
+				}
+			}
+		}
+	}
+
+	values := []struct {
+		envVar string
+		value  *string
+	}{
+		{OAuthAuthorizeURLEnvVar, &Config.OAuth.AuthorizeURL},
+		{OAuthDeviceCodeURLEnvVar, &Config.OAuth.DeviceCodeURL},
+		{OAuthTokenURLEnvVar, &Config.OAuth.TokenURL},
+		{OAuthClientIDEnvVar, &Config.OAuth.ClientID},
+		{OAuthAudienceEnvVar, &Config.OAuth.Audience},
+		{ACMEDirectoryURLEnvVar, &Config.ACMEDirectoryURL},
+	}
+	for _, override := range values {
+		if value, ok := lookup(override.envVar); ok && value != "" {
+			*override.value = value
+		}
+	}
+	return nil
+}
+
+// ParseEndpoint converts URL like https://api.example.com or ssh://gateway.example.com:8022 to endpoint,
+// the port is derived from the protocol when missing
+func ParseEndpoint(value string) (*models.Endpoint, error) {
+	parsedURL, err := url.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	defaultPort, ok := defaultPorts[parsedURL.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("unsupported protocol '%s'", parsedURL.Scheme)
+	}
+	if parsedURL.Hostname() == "" {
+		return nil, fmt.Errorf("no host in '%s'", value)
+	}
+
+	port := defaultPort
+	if parsedURL.Port() != "" {
+		parsedPort, err := strconv.ParseUint(parsedURL.Port(), 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port '%s'", parsedURL.Port())
+		}
+		port = int32(parsedPort)
+	}
+	path := parsedURL.Path
+	if path == "/" {
+		path = ""
+	}
+	return &models.Endpoint{
+		Protocol: parsedURL.Scheme,
+		Host:     parsedURL.Hostname(),
+		Port:     port,
+		Path:     path,
+	}, nil
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/loophole/cli/internal/app/loophole/models"
+)
+
+func lookupFrom(values map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}
+}
+
+func TestEndpointsAreOverridden(t *testing.T) {
+	oldConfig := Config
+	defer func() { Config = oldConfig }()
+
+	err := applyOverrides(lookupFrom(map[string]string{
+		APIURLEnvVar:           "http://localhost:8080",
+		GatewayURLEnvVar:       "ssh://gateway.internal",
+		OAuthTokenURLEnvVar:    "https://auth.internal/token",
+		ACMEDirectoryURLEnvVar: "https://acme.internal/directory",
+	}))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if Config.APIEndpoint.URI() != "http://localhost:8080" {
+		t.Fatalf("Expected API endpoint to be overridden, got '%s'", Config.APIEndpoint.URI())
+	}
+	if Config.GatewayEndpoint.Hostname() != "gateway.internal:8022" {
+		t.Fatalf("Expected gateway with default port, got '%s'", Config.GatewayEndpoint.Hostname())
+	}
+	if Config.GatewayWebSocketEndpoint.URI() != "ws://gateway.internal:80/ssh" {
+		t.Fatalf("Expected WebSocket endpoint to follow the gateway, got '%s'", Config.GatewayWebSocketEndpoint.URI())
+	}
+	if Config.OAuth.TokenURL != "https://auth.internal/token" || Config.ACMEDirectoryURL != "https://acme.internal/directory" {
+		t.Fatalf("Expected URLs to be overridden, got '%s' and '%s'", Config.OAuth.TokenURL, Config.ACMEDirectoryURL)
+	}
+}
+
+func TestEndpointsAreKeptWithoutOverrides(t *testing.T) {
+	oldConfig := Config
+	defer func() { Config = oldConfig }()
+	expected := Config.GatewayEndpoint
+
+	err := applyOverrides(lookupFrom(map[string]string{}))
+	if err != nil || Config.GatewayEndpoint != expected {
+		t.Fatalf("Expected gateway endpoint to be kept, got '%v': %v", Config.GatewayEndpoint, err)
+	}
+}
+
+func TestInvalidEndpointIsRejected(t *testing.T) {
+	for _, value := range []string{"gateway:8022", "ftp://gateway", "https://", "ssh://gateway:port"} {
+		if _, err := ParseEndpoint(value); err == nil {
+			t.Fatalf("Expected error for '%s'", value)
+		}
+	}
+
+	endpoint, err := ParseEndpoint("wss://gateway.internal/ssh")
+	expected := models.Endpoint{Protocol: "wss", Host: "gateway.internal", Port: 443, Path: "/ssh"}
+	if err != nil || *endpoint != expected {
+		t.Fatalf("Expected endpoint %v, got %v: %v", expected, endpoint, err)
+	}
+}
@@ -0,0 +1,141 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/loophole/cli/config"
+	"github.com/loophole/cli/internal/pkg/apiclient"
+	"golang.org/x/crypto/ssh"
+)
+
+// registrationRequest defines the json format in which the key is registered for the site
+type registrationRequest struct {
+	Key string `json:"key"`
+	ID  string `json:"id"`
+}
+
+// apiHandler serves the subset of loophole API used by the client
+func (g *Gateway) apiHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/info" {
+			writeJSON(w, http.StatusOK, apiclient.InfoSuccessResponse{Version: config.Config.Version})
+			return
+		}
+
+		user, ok := g.user(r)
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "Invalid token")
+			return
+		}
+
+		path := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/site"), "/")
+		switch {
+		case r.URL.Path == "/api/site" && r.Method == "GET":
+			g.listSites(w, user)
+		case r.URL.Path == "/api/site" && r.Method == "POST":
+			g.registerSite(w, r, user)
+		case len(path) == 2 && path[0] == "" && r.Method == "PUT":
+			g.reserveSite(w, user, path[1])
+		case len(path) == 2 && path[0] == "" && r.Method == "DELETE":
+			g.releaseSite(w, user, path[1])
+		case len(path) == 3 && path[0] == "" && path[2] == "availability" && r.Method == "GET":
+			g.checkSite(w, user, path[1])
+		default:
+			writeError(w, http.StatusNotFound, "Not found")
+		}
+	})
+}
+
+// user returns the user the bearer token was issued for
+func (g *Gateway) user(r *http.Request) (string, bool) {
+	accessToken := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if accessToken == "" {
+		return "", false
+	}
+	user, ok := g.config.Tokens[accessToken]
+	return user, ok
+}
+
+func (g *Gateway) listSites(w http.ResponseWriter, user string) {
+	sites := []apiclient.Site{}
+	for _, id := range g.sites.list(user) {
+		sites = append(sites, apiclient.Site{SiteID: id, Domain: g.config.Domain})
+	}
+	writeJSON(w, http.StatusOK, sites)
+}
+
+func (g *Gateway) registerSite(w http.ResponseWriter, r *http.Request, user string) {
+	var request registrationRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(request.Key))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid key")
+		return
+	}
+	id, err := g.sites.register(user, request.ID, key)
+	if err != nil {
+		writeRegistryError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, apiclient.RegistrationSuccessResponse{SiteID: id, Domain: g.config.Domain})
+}
+
+func (g *Gateway) reserveSite(w http.ResponseWriter, user string, id string) {
+	reserved, err := g.sites.reserve(user, id)
+	if err != nil {
+		writeRegistryError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, apiclient.Site{SiteID: reserved.id, Domain: g.config.Domain})
+}
+
+func (g *Gateway) releaseSite(w http.ResponseWriter, user string, id string) {
+	err := g.sites.release(user, id)
+	if err != nil {
+		writeRegistryError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (g *Gateway) checkSite(w http.ResponseWriter, user string, id string) {
+	available, err := g.sites.available(user, id)
+	if err != nil {
+		writeRegistryError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, apiclient.AvailabilityResponse{Available: available})
+}
+
+func writeRegistryError(w http.ResponseWriter, err error) {
+	switch err {
+	case errInvalidSiteID:
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errSiteTaken:
+		writeError(w, http.StatusConflict, err.Error())
+	case errSiteNotFound:
+		writeError(w, http.StatusNotFound, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, apiclient.ErrorResponse{
+		StatusCode: int32(status),
+		Error:      http.StatusText(status),
+		Message:    message,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,128 @@
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/loophole/cli/internal/pkg/communication"
+	"golang.org/x/crypto/ssh"
+)
+
+// Config defines the addresses the gateway listens on and the way sites are served
+type Config struct {
+	// SSHAddress is where tunnels are opened by clients
+	SSHAddress string
+	// HTTPAddress serves the API, SSH over WebSocket at /ssh and redirects sites to HTTPS
+	HTTPAddress string
+	// HTTPSAddress is where the sites are served, TLS is terminated by the clients
+	HTTPSAddress string
+	// Domain the sites are served under, e.g. site 'mysite' is served as mysite.loophole.localhost for loophole.localhost
+	Domain  string
+	HostKey ssh.Signer
+	// Tokens maps access tokens accepted by the API to the users
+	Tokens map[string]string
+}
+
+// Gateway is a minimal self-hosted counterpart of loophole service, keeping the sites in memory
+type Gateway struct {
+	config        Config
+	sites         *registry
+	sshConfig     *ssh.ServerConfig
+	sshListener   net.Listener
+	httpListener  net.Listener
+	httpsListener net.Listener
+	httpServer    *http.Server
+}
+
+// New creates gateway listening on the addresses from config
+func New(config Config) (*Gateway, error) {
+	if config.Domain == "" {
+		return nil, errors.New("Domain of the sites is required")
+	}
+	if config.HostKey == nil {
+		return nil, errors.New("Host key is required")
+	}
+
+	gateway := &Gateway{
+		config: config,
+		sites:  newRegistry(),
+	}
+	gateway.sshConfig = &ssh.ServerConfig{
+		PublicKeyCallback: gateway.authenticate,
+	}
+	gateway.sshConfig.AddHostKey(config.HostKey)
+	gateway.httpServer = &http.Server{
+		Handler:           gateway.httpHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	var err error
+	gateway.sshListener, err = net.Listen("tcp", config.SSHAddress)
+	if err != nil {
+		return nil, fmt.Errorf("There was a problem listening for SSH connections: %v", err)
+	}
+	gateway.httpListener, err = net.Listen("tcp", config.HTTPAddress)
+	if err != nil {
+		gateway.sshListener.Close()
+		return nil, fmt.Errorf("There was a problem listening for HTTP connections: %v", err)
+	}
+	gateway.httpsListener, err = net.Listen("tcp", config.HTTPSAddress)
+	if err != nil {
+		gateway.sshListener.Close()
+		gateway.httpListener.Close()
+		return nil, fmt.Errorf("There was a problem listening for HTTPS connections: %v", err)
+	}
+	return gateway, nil
+}
+
+// Serve handles connections until the gateway is closed
+func (g *Gateway) Serve() error {
+	go accept(g.sshListener, g.handleSSH)
+	go accept(g.httpsListener, g.routeTLS)
+
+	err := g.httpServer.Serve(g.httpListener)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// Close stops listening, tunnels already established are closed by clients
+func (g *Gateway) Close() error {
+	g.sshListener.Close()
+	g.httpsListener.Close()
+	return g.httpServer.Close()
+}
+
+// SSHAddr returns the address SSH connections are accepted on
+func (g *Gateway) SSHAddr() net.Addr {
+	return g.sshListener.Addr()
+}
+
+// HTTPAddr returns the address of the API
+func (g *Gateway) HTTPAddr() net.Addr {
+	return g.httpListener.Addr()
+}
+
+// HTTPSAddr returns the address the sites are served on
+func (g *Gateway) HTTPSAddr() net.Addr {
+	return g.httpsListener.Addr()
+}
+
+func accept(listener net.Listener, handler func(conn net.Conn)) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			communication.Debug(fmt.Sprintf("Stopped accepting connections on %s: %s", listener.Addr(), err.Error()))
+			return
+		}
+		go handler(conn)
+	}
+}
@@ -0,0 +1,280 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/loophole/cli/internal/pkg/apiclient"
+	"github.com/loophole/cli/internal/pkg/token"
+	"github.com/loophole/cli/internal/pkg/wsconn"
+	"golang.org/x/crypto/ssh"
+)
+
+const testDomain = "loophole.test"
+
+func startGateway(t *testing.T) *Gateway {
+	_, hostKey, _ := ed25519.GenerateKey(rand.Reader)
+	hostSigner, _ := ssh.NewSignerFromKey(hostKey)
+	gateway, err := New(Config{
+		SSHAddress:   "127.0.0.1:0",
+		HTTPAddress:  "127.0.0.1:0",
+		HTTPSAddress: "127.0.0.1:0",
+		Domain:       testDomain,
+		HostKey:      hostSigner,
+		Tokens:       map[string]string{"alice-token": "alice", "bob-token": "bob"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	go gateway.Serve()
+	t.Cleanup(func() { gateway.Close() })
+	return gateway
+}
+
+func generateSigner(t *testing.T) ssh.Signer {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// apiRequest sends request to the gateway API as the user with the given token
+func apiRequest(t *testing.T, gateway *Gateway, method string, path string, accessToken string, body interface{}) *http.Response {
+	content, _ := json.Marshal(body)
+	req, _ := http.NewRequest(method, fmt.Sprintf("http://%s%s", gateway.HTTPAddr(), path), bytes.NewReader(content))
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func registerKey(t *testing.T, gateway *Gateway, accessToken string, siteID string, signer ssh.Signer) *http.Response {
+	return apiRequest(t, gateway, "POST", "/api/site", accessToken, map[string]string{
+		"key": string(ssh.MarshalAuthorizedKey(signer.PublicKey())),
+		"id":  siteID,
+	})
+}
+
+// serveSite opens tunnel for the site and serves HTTPS through it, like the client does
+func serveSite(t *testing.T, conn net.Conn, siteID string, signer ssh.Signer) {
+	sshConn, channels, requests, err := ssh.NewClientConn(conn, "gateway", &ssh.ClientConfig{
+		User:            siteID,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatalf("Expected SSH connection to be accepted, got: %v", err)
+	}
+	client := ssh.NewClient(sshConn, channels, requests)
+	t.Cleanup(func() { client.Close() })
+
+	listener, err := client.Listen("tcp", "127.0.0.1:80")
+	if err != nil {
+		t.Fatalf("Expected remote forwarding to be accepted, got: %v", err)
+	}
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "hello from %s", r.Host)
+		}),
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{selfSignedCertificate(t, siteID+"."+testDomain)}},
+	}
+	go server.ServeTLS(listener, "", "")
+}
+
+func selfSignedCertificate(t *testing.T, host string) tls.Certificate {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{certificate}, PrivateKey: key}
+}
+
+// getSite requests the site from the gateway HTTPS address as if the hostname resolved to it
+func getSite(gateway *Gateway, hostname string) (string, error) {
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network string, address string) (net.Conn, error) {
+				return net.Dial("tcp", gateway.HTTPSAddr().String())
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	resp, err := client.Get("https://" + hostname + "/")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestSiteIsServedThroughTunnel(t *testing.T) {
+	gateway := startGateway(t)
+	signer := generateSigner(t)
+
+	if resp := registerKey(t, gateway, "alice-token", "mysite", signer); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected site to be registered, got status %d", resp.StatusCode)
+	}
+	conn, err := net.Dial("tcp", gateway.SSHAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	serveSite(t, conn, "mysite", signer)
+
+	body, err := getSite(gateway, "mysite."+testDomain)
+	if err != nil || body != "hello from mysite."+testDomain {
+		t.Fatalf("Expected site to be served through tunnel, got '%s': %v", body, err)
+	}
+	if _, err := getSite(gateway, "othersite."+testDomain); err == nil {
+		t.Fatalf("Expected connection for unknown site to be closed")
+	}
+}
+
+func TestSiteIsServedThroughWebSocketTunnel(t *testing.T) {
+	gateway := startGateway(t)
+	signer := generateSigner(t)
+	registerKey(t, gateway, "alice-token", "mysite", signer)
+
+	conn, err := wsconn.Dial(fmt.Sprintf("ws://%s/ssh", gateway.HTTPAddr()), nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	serveSite(t, conn, "mysite", signer)
+
+	body, err := getSite(gateway, "mysite."+testDomain)
+	if err != nil || body != "hello from mysite."+testDomain {
+		t.Fatalf("Expected site to be served through WebSocket tunnel, got '%s': %v", body, err)
+	}
+}
+
+func TestUnregisteredKeyIsRejected(t *testing.T) {
+	gateway := startGateway(t)
+	registerKey(t, gateway, "alice-token", "mysite", generateSigner(t))
+
+	_, err := ssh.Dial("tcp", gateway.SSHAddr().String(), &ssh.ClientConfig{
+		User:            "mysite",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(generateSigner(t))},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err == nil {
+		t.Fatalf("Expected key not registered for the site to be rejected")
+	}
+}
+
+func TestKeyRegisteredBeforeIsRejected(t *testing.T) {
+	gateway := startGateway(t)
+	oldSigner := generateSigner(t)
+	registerKey(t, gateway, "alice-token", "mysite", oldSigner)
+	registerKey(t, gateway, "alice-token", "mysite", generateSigner(t))
+
+	_, err := ssh.Dial("tcp", gateway.SSHAddr().String(), &ssh.ClientConfig{
+		User:            "mysite",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(oldSigner)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err == nil {
+		t.Fatalf("Expected key replaced by newer registration to be rejected")
+	}
+}
+
+func TestSitesAreOwnedByUsers(t *testing.T) {
+	gateway := startGateway(t)
+
+	if resp := apiRequest(t, gateway, "PUT", "/api/site/mysite", "alice-token", nil); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected site to be reserved, got status %d", resp.StatusCode)
+	}
+	if resp := registerKey(t, gateway, "bob-token", "mysite", generateSigner(t)); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("Expected conflict for site of another user, got status %d", resp.StatusCode)
+	}
+	var availability apiclient.AvailabilityResponse
+	json.NewDecoder(apiRequest(t, gateway, "GET", "/api/site/mysite/availability", "bob-token", nil).Body).Decode(&availability)
+	if availability.Available {
+		t.Fatalf("Expected site of another user to be unavailable")
+	}
+	if resp := apiRequest(t, gateway, "DELETE", "/api/site/mysite", "bob-token", nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected site of another user not to be released, got status %d", resp.StatusCode)
+	}
+	if resp := registerKey(t, gateway, "alice-token", "Invalid_Name", generateSigner(t)); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected invalid hostname to be rejected, got status %d", resp.StatusCode)
+	}
+	if resp := apiRequest(t, gateway, "GET", "/api/site", "unknown-token", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected unknown token to be rejected, got status %d", resp.StatusCode)
+	}
+
+	var sites []apiclient.Site
+	json.NewDecoder(apiRequest(t, gateway, "GET", "/api/site", "alice-token", nil).Body).Decode(&sites)
+	if len(sites) != 1 || sites[0].SiteID != "mysite" || sites[0].Domain != testDomain {
+		t.Fatalf("Expected reserved site to be listed, got %v", sites)
+	}
+
+	var registration apiclient.RegistrationSuccessResponse
+	json.NewDecoder(registerKey(t, gateway, "bob-token", "", generateSigner(t)).Body).Decode(&registration)
+	if !siteIDRegexp.MatchString(registration.SiteID) {
+		t.Fatalf("Expected random site ID to be assigned, got '%s'", registration.SiteID)
+	}
+}
+
+func TestPlainHTTPIsRedirectedForSites(t *testing.T) {
+	gateway := startGateway(t)
+	signer := generateSigner(t)
+	registerKey(t, gateway, "alice-token", "mysite", signer)
+	conn, _ := net.Dial("tcp", gateway.SSHAddr().String())
+	serveSite(t, conn, "mysite", signer)
+
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse }}
+	for hostname, expectedStatus := range map[string]int{"mysite." + testDomain: http.StatusPermanentRedirect, "othersite." + testDomain: http.StatusNotFound} {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("http://%s/path?query", gateway.HTTPAddr()), nil)
+		req.Host = hostname
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != expectedStatus {
+			t.Fatalf("Expected status %d for '%s', got %d", expectedStatus, hostname, resp.StatusCode)
+		}
+		if expectedStatus == http.StatusPermanentRedirect && resp.Header.Get("Location") != fmt.Sprintf("https://%s:%d/path?query", hostname, gateway.HTTPSAddr().(*net.TCPAddr).Port) {
+			t.Fatalf("Expected redirect to HTTPS, got '%s'", resp.Header.Get("Location"))
+		}
+	}
+}
+
+func TestAccessTokenIsUsableByClient(t *testing.T) {
+	accessToken, err := NewAccessToken("alice")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	tokens, err := token.ParseTokens(accessToken, "test")
+	if err != nil || tokens.AccessToken != accessToken {
+		t.Fatalf("Expected token to be accepted as access token, got: %v", err)
+	}
+}
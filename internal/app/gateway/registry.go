@@ -0,0 +1,189 @@
+package gateway
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var siteIDRegexp = regexp.MustCompile(`^[a-z][a-z0-9]*(-[a-z0-9]+)*$`)
+
+var errInvalidSiteID = errors.New("Invalid hostname")
+var errSiteTaken = errors.New("Hostname is already taken")
+var errSiteNotFound = errors.New("Hostname is not reserved")
+
+// site is hostname reserved by the user together with the key allowed to serve it
+type site struct {
+	id      string
+	owner   string
+	key     string
+	forward *forward
+}
+
+// registry keeps the sites in memory, they are registered again by clients starting tunnels after restart
+type registry struct {
+	mutex sync.Mutex
+	sites map[string]*site
+}
+
+func newRegistry() *registry {
+	return &registry{sites: map[string]*site{}}
+}
+
+// reserve returns the site owned by the user, reserving it first if it's free
+func (r *registry) reserve(owner string, id string) (*site, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.reserveLocked(owner, id)
+}
+
+// reserveLocked is reserve which must be called with the mutex held
+func (r *registry) reserveLocked(owner string, id string) (*site, error) {
+	if id == "" {
+		id = r.randomID()
+	}
+	if !siteIDRegexp.MatchString(id) || len(id) > 63 {
+		return nil, errInvalidSiteID
+	}
+	existing, ok := r.sites[id]
+	if ok && existing.owner != owner {
+		return nil, errSiteTaken
+	}
+	if !ok {
+		existing = &site{id: id, owner: owner}
+		r.sites[id] = existing
+	}
+	return existing, nil
+}
+
+// register reserves the site and allows the key to serve it, the key registered before is no longer accepted
+func (r *registry) register(owner string, id string, key ssh.PublicKey) (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	reserved, err := r.reserveLocked(owner, id)
+	if err != nil {
+		return "", err
+	}
+	reserved.key = string(key.Marshal())
+	return reserved.id, nil
+}
+
+// release removes the site owned by the user, closing the tunnel serving it
+func (r *registry) release(owner string, id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	existing, ok := r.sites[id]
+	if !ok || existing.owner != owner {
+		return errSiteNotFound
+	}
+	if existing.forward != nil {
+		existing.forward.conn.Close()
+	}
+	delete(r.sites, id)
+	return nil
+}
+
+// list returns IDs of the sites owned by the user
+func (r *registry) list(owner string) []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	ids := []string{}
+	for id, existing := range r.sites {
+		if existing.owner == owner {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// available tells whether the user can use the site
+func (r *registry) available(owner string, id string) (bool, error) {
+	if !siteIDRegexp.MatchString(id) || len(id) > 63 {
+		return false, errInvalidSiteID
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	existing, ok := r.sites[id]
+	return !ok || existing.owner == owner, nil
+}
+
+// authorized tells whether the key was the last one registered for the site
+func (r *registry) authorized(id string, key ssh.PublicKey) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	existing, ok := r.sites[id]
+	return ok && existing.key != "" && existing.key == string(key.Marshal())
+}
+
+// setForward makes the site served by the tunnel, replacing the previous one
+func (r *registry) setForward(id string, f *forward) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if existing, ok := r.sites[id]; ok {
+		existing.forward = f
+	}
+}
+
+// removeForward stops serving the site with the tunnel, unless it was already replaced
+func (r *registry) removeForward(id string, f *forward) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if existing, ok := r.sites[id]; ok && existing.forward == f {
+		existing.forward = nil
+	}
+}
+
+// forwardFor returns the tunnel serving the hostname within the domain
+func (r *registry) forwardFor(hostname string, domain string) (*forward, bool) {
+	hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
+	id := strings.TrimSuffix(hostname, "."+domain)
+	if id == hostname || strings.Contains(id, ".") {
+		return nil, false
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	existing, ok := r.sites[id]
+	if !ok || existing.forward == nil {
+		return nil, false
+	}
+	return existing.forward, true
+}
+
+// randomID returns free site ID, it must be called with the mutex held
+func (r *registry) randomID() string {
+	const letters = "abcdefghijklmnopqrstuvwxyz"
+	const characters = letters + "0123456789"
+	for {
+		id := []byte{letters[randomIndex(len(letters))]}
+		for len(id) < 12 {
+			id = append(id, characters[randomIndex(len(characters))])
+		}
+		if _, ok := r.sites[string(id)]; !ok {
+			return string(id)
+		}
+	}
+}
+
+func randomIndex(max int) int {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		panic(err)
+	}
+	return int(n.Int64())
+}
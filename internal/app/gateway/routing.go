@@ -0,0 +1,128 @@
+package gateway
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/loophole/cli/internal/pkg/communication"
+	"github.com/loophole/cli/internal/pkg/wsconn"
+)
+
+var errServerNameRead = errors.New("server name read")
+
+var upgrader = websocket.Upgrader{}
+
+// routeTLS passes the connection to the tunnel selected by server name, TLS is terminated by the client
+// so the traffic is never decrypted by the gateway
+func (g *Gateway) routeTLS(conn net.Conn) {
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	serverName, clientHello, err := readServerName(conn)
+	if err != nil {
+		communication.Debug(fmt.Sprintf("Reading server name from %s failed: %s", conn.RemoteAddr(), err.Error()))
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	f, ok := g.sites.forwardFor(serverName, g.config.Domain)
+	if !ok {
+		communication.Debug(fmt.Sprintf("No tunnel for '%s'", serverName))
+		return
+	}
+	channel, err := f.open(conn.RemoteAddr())
+	if err != nil {
+		communication.Debug(fmt.Sprintf("Opening channel for '%s' failed: %s", serverName, err.Error()))
+		return
+	}
+	defer channel.Close()
+
+	done := make(chan bool, 2)
+	go func() {
+		io.Copy(channel, io.MultiReader(bytes.NewReader(clientHello), conn))
+		channel.CloseWrite()
+		done <- true
+	}()
+	go func() {
+		io.Copy(conn, channel)
+		done <- true
+	}()
+	<-done
+}
+
+// readServerName reads server name from TLS ClientHello, returning the bytes read so that they can be passed on
+func readServerName(conn net.Conn) (string, []byte, error) {
+	var clientHello bytes.Buffer
+	var serverName string
+	err := tls.Server(readOnlyConn{reader: io.TeeReader(conn, &clientHello)}, &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverName = hello.ServerName
+			return nil, errServerNameRead
+		},
+	}).Handshake()
+	if serverName == "" {
+		if err == nil || errors.Is(err, errServerNameRead) {
+			err = errors.New("no server name given")
+		}
+		return "", nil, err
+	}
+	return serverName, clientHello.Bytes(), nil
+}
+
+// readOnlyConn lets TLS server read ClientHello without responding to it
+type readOnlyConn struct {
+	reader io.Reader
+}
+
+func (c readOnlyConn) Read(b []byte) (int, error)         { return c.reader.Read(b) }
+func (c readOnlyConn) Write(b []byte) (int, error)        { return 0, io.ErrClosedPipe }
+func (c readOnlyConn) Close() error                       { return nil }
+func (c readOnlyConn) LocalAddr() net.Addr                { return nil }
+func (c readOnlyConn) RemoteAddr() net.Addr               { return nil }
+func (c readOnlyConn) SetDeadline(t time.Time) error      { return nil }
+func (c readOnlyConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c readOnlyConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// httpHandler redirects requests for sites to HTTPS, other requests are handled by the API or WebSocket transport
+func (g *Gateway) httpHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/api/", g.apiHandler())
+	mux.HandleFunc("/ssh", g.handleWebSocket)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+		if strings.HasSuffix(strings.ToLower(host), "."+g.config.Domain) {
+			if _, ok := g.sites.forwardFor(host, g.config.Domain); !ok {
+				http.Error(w, "Site not found", http.StatusNotFound)
+				return
+			}
+			if port := g.httpsListener.Addr().(*net.TCPAddr).Port; port != 443 {
+				host = net.JoinHostPort(host, strconv.Itoa(port))
+			}
+			http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// handleWebSocket serves SSH carried over WebSocket, used by clients in networks allowing HTTP(S) only
+func (g *Gateway) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	g.handleSSH(wsconn.New(ws))
+}
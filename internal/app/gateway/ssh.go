@@ -0,0 +1,125 @@
+package gateway
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/loophole/cli/internal/pkg/communication"
+	"golang.org/x/crypto/ssh"
+)
+
+// defaultForwardPort is used when client asks the gateway to choose the port
+const defaultForwardPort = 80
+
+var handshakeTimeout = 30 * time.Second
+
+// forward is the tunnel opened by client with tcpip-forward request
+type forward struct {
+	conn     *ssh.ServerConn
+	bindAddr string
+	bindPort uint32
+}
+
+// RFC 4254 7.1
+type forwardRequest struct {
+	BindAddr string
+	BindPort uint32
+}
+
+type forwardReply struct {
+	Port uint32
+}
+
+// RFC 4254 7.2
+type forwardedTCPPayload struct {
+	Addr       string
+	Port       uint32
+	OriginAddr string
+	OriginPort uint32
+}
+
+// authenticate accepts the keys registered for the site, which is given as SSH user
+func (g *Gateway) authenticate(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	if !g.sites.authorized(conn.User(), key) {
+		return nil, fmt.Errorf("Key %s is not registered for site '%s'", ssh.FingerprintSHA256(key), conn.User())
+	}
+	return nil, nil
+}
+
+// handleSSH serves tunnel of single client, the only thing allowed is remote forwarding
+func (g *Gateway) handleSSH(netConn net.Conn) {
+	netConn.SetDeadline(time.Now().Add(handshakeTimeout))
+	conn, channels, requests, err := ssh.NewServerConn(netConn, g.sshConfig)
+	if err != nil {
+		communication.Debug(fmt.Sprintf("SSH handshake with %s failed: %s", netConn.RemoteAddr(), err.Error()))
+		netConn.Close()
+		return
+	}
+	netConn.SetDeadline(time.Time{})
+	defer conn.Close()
+
+	siteID := conn.User()
+	communication.Info(fmt.Sprintf("Tunnel for '%s' connected from %s", siteID, conn.RemoteAddr()))
+
+	go func() {
+		for channel := range channels {
+			channel.Reject(ssh.Prohibited, "only remote forwarding is supported")
+		}
+	}()
+
+	var current *forward
+	for request := range requests {
+		switch request.Type {
+		case "tcpip-forward":
+			var payload forwardRequest
+			if err := ssh.Unmarshal(request.Payload, &payload); err != nil {
+				request.Reply(false, nil)
+				continue
+			}
+			if payload.BindPort == 0 {
+				payload.BindPort = defaultForwardPort
+			}
+			if current != nil {
+				g.sites.removeForward(siteID, current)
+			}
+			current = &forward{conn: conn, bindAddr: payload.BindAddr, bindPort: payload.BindPort}
+			g.sites.setForward(siteID, current)
+			request.Reply(true, ssh.Marshal(forwardReply{Port: payload.BindPort}))
+		case "cancel-tcpip-forward":
+			if current != nil {
+				g.sites.removeForward(siteID, current)
+				current = nil
+			}
+			request.Reply(true, nil)
+		default:
+			if request.WantReply {
+				request.Reply(false, nil)
+			}
+		}
+	}
+
+	if current != nil {
+		g.sites.removeForward(siteID, current)
+	}
+	communication.Info(fmt.Sprintf("Tunnel for '%s' disconnected", siteID))
+}
+
+// open opens channel through the tunnel on behalf of the connection coming from origin
+func (f *forward) open(origin net.Addr) (ssh.Channel, error) {
+	originHost, originPortString, _ := net.SplitHostPort(origin.String())
+	originPort, _ := strconv.Atoi(originPortString)
+
+	channel, requests, err := f.conn.OpenChannel("forwarded-tcpip", ssh.Marshal(forwardedTCPPayload{
+		Addr:       f.bindAddr,
+		Port:       f.bindPort,
+		OriginAddr: originHost,
+		OriginPort: uint32(originPort),
+	}))
+	if err != nil {
+		return nil, err
+	}
+	go ssh.DiscardRequests(requests)
+	return channel, nil
+}
@@ -1,19 +1,27 @@
 package loophole
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/loophole/cli/config"
 	lm "github.com/loophole/cli/internal/app/loophole/models"
 	"github.com/loophole/cli/internal/pkg/apiclient"
+	"github.com/loophole/cli/internal/pkg/archivefs"
 	"github.com/loophole/cli/internal/pkg/communication"
 	"github.com/loophole/cli/internal/pkg/httpserver"
 	"github.com/loophole/cli/internal/pkg/keys"
+	"github.com/loophole/cli/internal/pkg/maintenance"
+	"github.com/loophole/cli/internal/pkg/netproxy"
+	"github.com/loophole/cli/internal/pkg/sharelink"
 	"github.com/loophole/cli/internal/pkg/urlmaker"
+	"github.com/loophole/cli/internal/pkg/wsconn"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -27,8 +35,40 @@ const (
 	Directory TunnelType = "Tunnel_Directory"
 	// WebDav specifies local directory tunnel type (download+upload via WebDav)
 	WebDav TunnelType = "Tunnel_WebDav"
+	// Dropbox specifies local directory tunnel type (upload only)
+	Dropbox TunnelType = "Tunnel_Dropbox"
+	// File specifies single local file tunnel type (download only, limited)
+	File TunnelType = "Tunnel_File"
 )
 
+const (
+	// TransportAuto connects to the gateway directly, falling back to WebSocket when it fails
+	TransportAuto = "auto"
+	// TransportTCP connects to the gateway directly only
+	TransportTCP = "tcp"
+	// TransportWebSocket connects to the gateway with SSH carried over WebSocket only
+	TransportWebSocket = "websocket"
+)
+
+// workingTransport is the transport last used to reach the gateway, tried first on reconnection
+// so that networks blocking direct connections don't wait for them to fail every time
+var workingTransport string
+var workingTransportMutex sync.Mutex
+
+// agentConnections holds connections to SSH agent signing handshakes of the tunnels, by tunnel ID
+var agentConnections = map[string]io.Closer{}
+var agentConnectionsMutex sync.Mutex
+
+// closeAgentConnection closes the connection to SSH agent once the tunnel using it ends
+func closeAgentConnection(tunnelID string) {
+	agentConnectionsMutex.Lock()
+	defer agentConnectionsMutex.Unlock()
+	if conn, ok := agentConnections[tunnelID]; ok {
+		conn.Close()
+		delete(agentConnections, tunnelID)
+	}
+}
+
 // remote forwarding port (on remote SSH server network)
 var remoteEndpoint = lm.Endpoint{
 	Host: "127.0.0.1",
@@ -89,10 +129,11 @@ func registerDomain(publicKey *ssh.PublicKey, requestedSiteID string, tunnelID s
 	return registrationResult, nil
 }
 
-func connectViaSSH(siteID string, tunnelID string, authMethod ssh.AuthMethod) (*ssh.Client, error) {
+func connectViaSSH(remoteConfig lm.RemoteEndpointSpecs, authMethod ssh.AuthMethod) (*ssh.Client, error) {
+	tunnelID := remoteConfig.TunnelID
 	var serverSSHConnHTTPS *ssh.Client
 	sshConfigHTTPS := &ssh.ClientConfig{
-		User: siteID,
+		User: remoteConfig.SiteID,
 		Auth: []ssh.AuthMethod{
 			authMethod,
 		},
@@ -100,10 +141,20 @@ func connectViaSSH(siteID string, tunnelID string, authMethod ssh.AuthMethod) (*
 	}
 	var sshSuccess bool = false
 	var sshRetries int = 5
+	var transport string
 	var err error
 	for i := 0; i < sshRetries && !sshSuccess; i++ { // Connection retries in case of reconnect during gateway shutdown
 		communication.LoadingStart(tunnelID, "Initializing secure tunnel... ")
-		serverSSHConnHTTPS, err = ssh.Dial("tcp", config.Config.GatewayEndpoint.Hostname(), sshConfigHTTPS)
+		if !remoteConfig.UseAgent {
+			// expired certificate won't be accepted no matter how many times it's retried
+			err = keys.CheckCertificate(remoteConfig.IdentityFile)
+			if err != nil {
+				communication.LoadingFailure(tunnelID, err)
+				communication.TunnelError(tunnelID, err.Error())
+				return nil, err
+			}
+		}
+		serverSSHConnHTTPS, transport, err = dialSSH(remoteConfig, sshConfigHTTPS)
 		if err != nil {
 			communication.LoadingFailure(tunnelID, err)
 			communication.TunnelInfo(tunnelID, fmt.Sprintf("SSH Connection failed, retrying in 10 seconds... (Attempt %d/%d)", i+1, sshRetries))
@@ -119,16 +170,107 @@ func connectViaSSH(siteID string, tunnelID string, authMethod ssh.AuthMethod) (*
 		return nil, err
 	}
 	communication.TunnelDebug(tunnelID, "Dialing SSH Gateway for HTTPS succeeded")
+	if transport == TransportWebSocket {
+		communication.TunnelInfo(tunnelID, fmt.Sprintf("Connected to the gateway over WebSocket (%s)", config.Config.GatewayWebSocketEndpoint.URI()))
+	} else {
+		communication.TunnelDebug(tunnelID, fmt.Sprintf("Connected to the gateway over TCP (%s)", config.Config.GatewayEndpoint.Hostname()))
+	}
 	communication.LoadingSuccess(tunnelID)
 	return serverSSHConnHTTPS, nil
 }
 
+// dialGateway connects to SSH gateway with the transport selected in config, returning the one actually used.
+// In automatic mode WebSocket is used only when direct connection fails, e.g. in networks allowing HTTPS traffic only,
+// after that it's tried first for the rest of the process
+func dialGateway(remoteConfig lm.RemoteEndpointSpecs) (net.Conn, string, error) {
+	dialer, err := netproxy.NewDialer(remoteConfig.Proxy)
+	if err != nil {
+		return nil, "", err
+	}
+
+	transports := []string{TransportTCP, TransportWebSocket}
+	switch remoteConfig.Transport {
+	case TransportTCP:
+		transports = []string{TransportTCP}
+	case TransportWebSocket:
+		transports = []string{TransportWebSocket}
+	default:
+		workingTransportMutex.Lock()
+		if workingTransport == TransportWebSocket {
+			transports = []string{TransportWebSocket, TransportTCP}
+		}
+		workingTransportMutex.Unlock()
+	}
+
+	var conn net.Conn
+	var transport string
+	for i := range transports {
+		transport = transports[i]
+		if transport == TransportTCP {
+			conn, err = dialer.Dial("tcp", config.Config.GatewayEndpoint.Hostname())
+		} else {
+			var netDial func(network string, address string) (net.Conn, error)
+			if remoteConfig.Proxy != "" {
+				netDial = dialer.Dial
+			}
+			conn, err = wsconn.Dial(config.Config.GatewayWebSocketEndpoint.URI(), netDial)
+		}
+		if err == nil {
+			workingTransportMutex.Lock()
+			workingTransport = transport
+			workingTransportMutex.Unlock()
+			return conn, transport, nil
+		}
+		if i < len(transports)-1 {
+			communication.TunnelDebug(remoteConfig.TunnelID, fmt.Sprintf("Connection to gateway over %s failed, trying %s: %s", transport, transports[i+1], err.Error()))
+		}
+	}
+	return nil, transport, err
+}
+
+// dialSSH establishes SSH connection with the gateway, returning the transport used
+func dialSSH(remoteConfig lm.RemoteEndpointSpecs, sshConfig *ssh.ClientConfig) (*ssh.Client, string, error) {
+	conn, transport, err := dialGateway(remoteConfig)
+	if err != nil {
+		return nil, transport, err
+	}
+	address := config.Config.GatewayEndpoint.Hostname()
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, address, sshConfig)
+	if err != nil {
+		conn.Close()
+		return nil, transport, err
+	}
+	return ssh.NewClient(sshConn, chans, reqs), transport, nil
+}
+
+func getMaintenanceSwitch(remoteConfig lm.RemoteEndpointSpecs) (*maintenance.Switch, error) {
+	maintenanceSwitch := maintenance.ForTunnel(remoteConfig.TunnelID)
+	if remoteConfig.MaintenancePage != "" {
+		err := maintenanceSwitch.LoadPage(remoteConfig.MaintenancePage)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if remoteConfig.MaintenanceRetryAfter > 0 {
+		maintenanceSwitch.SetRetryAfter(remoteConfig.MaintenanceRetryAfter)
+	}
+	maintenanceSwitch.Set(remoteConfig.Maintenance)
+	return maintenanceSwitch, nil
+}
+
 func createTLSReverseProxy(localEndpoint lm.Endpoint, remoteConfig lm.RemoteEndpointSpecs) (*http.Server, error) {
 	communication.LoadingStart(remoteConfig.TunnelID, "Starting local TLS proxy server")
+	maintenanceSwitch, err := getMaintenanceSwitch(remoteConfig)
+	if err != nil {
+		communication.LoadingFailure(remoteConfig.TunnelID, err)
+		communication.TunnelStartFailure(remoteConfig.TunnelID, err)
+		return nil, err
+	}
 	serverBuilder := httpserver.New().
 		WithSiteID(remoteConfig.SiteID).
 		WithDomain(remoteConfig.Domain).
 		DisableOldCiphers(remoteConfig.DisableOldCiphers).
+		WithMaintenance(maintenanceSwitch).
 		Proxy().
 		ToEndpoint(localEndpoint)
 
@@ -194,8 +336,22 @@ func startRemoteForwardServer(tunnelID string, serverSSHConnHTTPS *ssh.Client) (
 	return listenerHTTPSOverSSH, nil
 }
 
-func parsePublicKey(tunnelID string, identityFile string) (ssh.AuthMethod, ssh.PublicKey, error) {
-	publicKeyAuthMethod, publicKey, err := keys.ParsePublicKey(identityFile)
+func parsePublicKey(tunnelID string, remoteConfig lm.RemoteEndpointSpecs) (ssh.AuthMethod, ssh.PublicKey, error) {
+	var publicKeyAuthMethod ssh.AuthMethod
+	var publicKey ssh.PublicKey
+	var err error
+	if remoteConfig.UseAgent {
+		var agentConn io.Closer
+		publicKeyAuthMethod, publicKey, agentConn, err = keys.AgentSigner(remoteConfig.AgentKeyFingerprint)
+		if err == nil {
+			communication.TunnelDebug(tunnelID, fmt.Sprintf("Using key %s from SSH agent", ssh.FingerprintSHA256(publicKey)))
+			agentConnectionsMutex.Lock()
+			agentConnections[tunnelID] = agentConn
+			agentConnectionsMutex.Unlock()
+		}
+	} else {
+		publicKeyAuthMethod, publicKey, err = keys.ParsePublicKey(remoteConfig.IdentityFile, remoteConfig.KeyPassphraseFile)
+	}
 	if err != nil {
 		communication.LoadingFailure(tunnelID, err)
 		communication.TunnelError(tunnelID, "No public key available")
@@ -207,17 +363,53 @@ func parsePublicKey(tunnelID string, identityFile string) (ssh.AuthMethod, ssh.P
 
 func getStaticFileServer(exposeDirectoryConfig lm.ExposeDirectoryConfig) (*http.Server, error) {
 	communication.LoadingStart(exposeDirectoryConfig.Remote.TunnelID, "Starting local file server")
+	maintenanceSwitch, err := getMaintenanceSwitch(exposeDirectoryConfig.Remote)
+	if err != nil {
+		communication.LoadingFailure(exposeDirectoryConfig.Remote.TunnelID, err)
+		return nil, err
+	}
 	serverBuilder := httpserver.New().
 		WithSiteID(exposeDirectoryConfig.Remote.SiteID).
 		WithDomain(exposeDirectoryConfig.Remote.Domain).
 		DisableOldCiphers(exposeDirectoryConfig.Remote.DisableOldCiphers).
+		WithMaintenance(maintenanceSwitch).
 		ServeStatic().
-		FromDirectory(exposeDirectoryConfig.Local.Path)
+		Excluding(exposeDirectoryConfig.Local.Exclude)
+
+	exposeArchive := isArchive(exposeDirectoryConfig.Local.Path)
+	if exposeArchive {
+		serverBuilder = serverBuilder.
+			FromArchive(exposeDirectoryConfig.Local.Path)
+	} else {
+		serverBuilder = serverBuilder.
+			FromDirectory(exposeDirectoryConfig.Local.Path)
+	}
 
 	if exposeDirectoryConfig.Remote.BasicAuthUsername != "" && exposeDirectoryConfig.Remote.BasicAuthPassword != "" {
 		serverBuilder = serverBuilder.
 			WithBasicAuth(exposeDirectoryConfig.Remote.BasicAuthUsername, exposeDirectoryConfig.Remote.BasicAuthPassword)
 	}
+	if exposeDirectoryConfig.Local.ShowDotfiles {
+		serverBuilder = serverBuilder.
+			ShowDotfiles()
+	}
+	if exposeDirectoryConfig.Local.SPA {
+		serverBuilder = serverBuilder.
+			EnableSPAMode()
+	}
+	if exposeDirectoryConfig.Local.DisableDirectoryListing {
+		serverBuilder = serverBuilder.
+			DisableDirectoryListing()
+	}
+	if !exposeArchive {
+		shareLinkTunnel, err := sharelink.Register(exposeDirectoryConfig.Remote.SiteID, exposeDirectoryConfig.Remote.Domain, exposeDirectoryConfig.Local.Path)
+		if err != nil {
+			communication.TunnelWarn(exposeDirectoryConfig.Remote.TunnelID, fmt.Sprintf("Share links are disabled: %s", err.Error()))
+		} else {
+			serverBuilder = serverBuilder.
+				WithShareLinks(shareLinkTunnel)
+		}
+	}
 
 	communication.LoadingSuccess(exposeDirectoryConfig.Remote.TunnelID)
 	server, err := serverBuilder.Build()
@@ -229,19 +421,52 @@ func getStaticFileServer(exposeDirectoryConfig lm.ExposeDirectoryConfig) (*http.
 	return server, nil
 }
 
+// isArchive returns whether the path points to an archive file, which is exposed with its content
+func isArchive(path string) bool {
+	stat, err := os.Stat(path)
+	return err == nil && stat.Mode().IsRegular() && archivefs.IsArchive(path)
+}
+
 func getWebdavServer(exposeWebDavConfig lm.ExposeWebdavConfig) (*http.Server, error) {
 	communication.LoadingStart(exposeWebDavConfig.Remote.TunnelID, "Starting WebDav server")
+	maintenanceSwitch, err := getMaintenanceSwitch(exposeWebDavConfig.Remote)
+	if err != nil {
+		communication.LoadingFailure(exposeWebDavConfig.Remote.TunnelID, err)
+		return nil, err
+	}
 	serverBuilder := httpserver.New().
 		WithSiteID(exposeWebDavConfig.Remote.SiteID).
 		WithDomain(exposeWebDavConfig.Remote.Domain).
 		DisableOldCiphers(exposeWebDavConfig.Remote.DisableOldCiphers).
+		WithMaintenance(maintenanceSwitch).
 		ServeWebdav().
-		FromDirectory(exposeWebDavConfig.Local.Path)
+		FromDirectory(exposeWebDavConfig.Local.Path).
+		Excluding(exposeWebDavConfig.Local.Exclude)
 
 	if exposeWebDavConfig.Remote.BasicAuthUsername != "" && exposeWebDavConfig.Remote.BasicAuthPassword != "" {
 		serverBuilder = serverBuilder.
 			WithBasicAuth(exposeWebDavConfig.Remote.BasicAuthUsername, exposeWebDavConfig.Remote.BasicAuthPassword)
 	}
+	if exposeWebDavConfig.Local.ShowDotfiles {
+		serverBuilder = serverBuilder.
+			ShowDotfiles()
+	}
+	if exposeWebDavConfig.Local.ReadOnly {
+		serverBuilder = serverBuilder.
+			ReadOnly()
+	}
+	for _, user := range exposeWebDavConfig.Local.Users {
+		serverBuilder = serverBuilder.
+			WithUser(user.Username, user.Password, httpserver.Permission(user.Permission))
+	}
+	if exposeWebDavConfig.Local.Quota > 0 {
+		serverBuilder = serverBuilder.
+			WithQuota(exposeWebDavConfig.Local.Quota)
+	}
+	if exposeWebDavConfig.Local.MaxFileSize > 0 {
+		serverBuilder = serverBuilder.
+			WithMaxFileSize(exposeWebDavConfig.Local.MaxFileSize)
+	}
 
 	communication.LoadingSuccess(exposeWebDavConfig.Remote.TunnelID)
 	server, err := serverBuilder.Build()
@@ -253,6 +478,86 @@ func getWebdavServer(exposeWebDavConfig lm.ExposeWebdavConfig) (*http.Server, er
 	return server, nil
 }
 
+func getDropboxServer(exposeDropboxConfig lm.ExposeDropboxConfig) (*http.Server, error) {
+	communication.LoadingStart(exposeDropboxConfig.Remote.TunnelID, "Starting upload server")
+	maintenanceSwitch, err := getMaintenanceSwitch(exposeDropboxConfig.Remote)
+	if err != nil {
+		communication.LoadingFailure(exposeDropboxConfig.Remote.TunnelID, err)
+		return nil, err
+	}
+	serverBuilder := httpserver.New().
+		WithSiteID(exposeDropboxConfig.Remote.SiteID).
+		WithDomain(exposeDropboxConfig.Remote.Domain).
+		DisableOldCiphers(exposeDropboxConfig.Remote.DisableOldCiphers).
+		WithMaintenance(maintenanceSwitch).
+		ServeDropbox().
+		FromDirectory(exposeDropboxConfig.Local.Path).
+		WithMaxUploadSize(exposeDropboxConfig.Local.MaxFileSize)
+
+	if exposeDropboxConfig.Remote.BasicAuthUsername != "" && exposeDropboxConfig.Remote.BasicAuthPassword != "" {
+		serverBuilder = serverBuilder.
+			WithBasicAuth(exposeDropboxConfig.Remote.BasicAuthUsername, exposeDropboxConfig.Remote.BasicAuthPassword)
+	}
+
+	communication.LoadingSuccess(exposeDropboxConfig.Remote.TunnelID)
+	server, err := serverBuilder.Build()
+	if err != nil {
+		communication.LoadingFailure(exposeDropboxConfig.Remote.TunnelID, err)
+		communication.TunnelError(exposeDropboxConfig.Remote.TunnelID, "Something went wrong while creating server")
+		return nil, err
+	}
+	return server, nil
+}
+
+func getSingleFileServer(exposeFileConfig lm.ExposeFileConfig, expiry *httpserver.Deadline, finished chan<- string) (*http.Server, error) {
+	tunnelID := exposeFileConfig.Remote.TunnelID
+	communication.LoadingStart(tunnelID, "Starting local file server")
+	maintenanceSwitch, err := getMaintenanceSwitch(exposeFileConfig.Remote)
+	if err != nil {
+		communication.LoadingFailure(tunnelID, err)
+		return nil, err
+	}
+	serverBuilder := httpserver.New().
+		WithSiteID(exposeFileConfig.Remote.SiteID).
+		WithDomain(exposeFileConfig.Remote.Domain).
+		DisableOldCiphers(exposeFileConfig.Remote.DisableOldCiphers).
+		WithMaintenance(maintenanceSwitch).
+		ServeFile().
+		FromFile(exposeFileConfig.Local.Path).
+		OnProgress(func(progress httpserver.DownloadProgress) {
+			if progress.Completed {
+				communication.TunnelInfo(tunnelID, fmt.Sprintf("Download by %s completed", progress.Client))
+				return
+			}
+			communication.TunnelInfo(tunnelID, fmt.Sprintf("Download by %s: %d%%", progress.Client, progress.Sent*100/progress.Total))
+		})
+
+	if exposeFileConfig.Remote.BasicAuthUsername != "" && exposeFileConfig.Remote.BasicAuthPassword != "" {
+		serverBuilder = serverBuilder.
+			WithBasicAuth(exposeFileConfig.Remote.BasicAuthUsername, exposeFileConfig.Remote.BasicAuthPassword)
+	}
+	if exposeFileConfig.Local.MaxDownloads > 0 {
+		serverBuilder = serverBuilder.
+			WithMaxDownloads(exposeFileConfig.Local.MaxDownloads).
+			OnLimitReached(func() {
+				finished <- "Download limit reached"
+			})
+	}
+	if exposeFileConfig.Local.Expire > 0 {
+		serverBuilder = serverBuilder.
+			WithExpiry(expiry)
+	}
+
+	communication.LoadingSuccess(tunnelID)
+	server, err := serverBuilder.Build()
+	if err != nil {
+		communication.LoadingFailure(tunnelID, err)
+		communication.TunnelError(tunnelID, "Something went wrong while creating server")
+		return nil, err
+	}
+	return server, nil
+}
+
 func listenOnRemoteEndpoint(tunnelID string, serverSSHConnHTTPS *ssh.Client) (*net.Listener, error) {
 	listenerHTTPSOverSSH, err := serverSSHConnHTTPS.Listen("tcp", remoteEndpoint.URI())
 	if err != nil {
@@ -265,12 +570,13 @@ func listenOnRemoteEndpoint(tunnelID string, serverSSHConnHTTPS *ssh.Client) (*n
 
 // RegisterTunnel is used to register tunnel in loophole API and grant user access to connect to it
 func RegisterTunnel(remoteConfig *lm.RemoteEndpointSpecs) (ssh.AuthMethod, error) {
-	publicKeyAuthMethod, publicKey, err := parsePublicKey(remoteConfig.TunnelID, remoteConfig.IdentityFile)
+	publicKeyAuthMethod, publicKey, err := parsePublicKey(remoteConfig.TunnelID, *remoteConfig)
 	if err != nil {
 		return nil, err
 	}
 	registrationResult, err := registerDomain(&publicKey, remoteConfig.SiteID, remoteConfig.TunnelID)
 	if err != nil {
+		closeAgentConnection(remoteConfig.TunnelID)
 		return nil, err
 	}
 	remoteConfig.SiteID = registrationResult.SiteID
@@ -282,6 +588,7 @@ func RegisterTunnel(remoteConfig *lm.RemoteEndpointSpecs) (ssh.AuthMethod, error
 
 // ForwardPort is used to forward external URL to locally available port
 func ForwardPort(exposeHTTPConfig lm.ExposeHTTPConfig, publicKeyAuthMethod ssh.AuthMethod, quitChannel <-chan bool) error {
+	defer closeAgentConnection(exposeHTTPConfig.Remote.TunnelID)
 	protocol := "http"
 	if exposeHTTPConfig.Local.HTTPS {
 		protocol = "https"
@@ -297,38 +604,111 @@ func ForwardPort(exposeHTTPConfig lm.ExposeHTTPConfig, publicKeyAuthMethod ssh.A
 	if err != nil {
 		return err
 	}
-	return forward(exposeHTTPConfig.Remote, publicKeyAuthMethod, server, localEndpoint.URI(), []string{"https"}, quitChannel)
+	return forward(exposeHTTPConfig.Remote, publicKeyAuthMethod, server, localEndpoint.URI(), []string{"https"}, quitChannel, nil)
 }
 
 // ForwardDirectory is used to expose local directory via HTTP (download only)
 func ForwardDirectory(exposeDirectoryConfig lm.ExposeDirectoryConfig, publicKeyAuthMethod ssh.AuthMethod, quitChannel <-chan bool) error {
+	defer closeAgentConnection(exposeDirectoryConfig.Remote.TunnelID)
+	defer sharelink.Stop(exposeDirectoryConfig.Remote.SiteID)
 	server, err := getStaticFileServer(exposeDirectoryConfig)
 	if err != nil {
 		return err
 	}
-	return forward(exposeDirectoryConfig.Remote, publicKeyAuthMethod, server, exposeDirectoryConfig.Local.Path, []string{"https"}, quitChannel)
+	defer shutdownServer(server)
+	return forward(exposeDirectoryConfig.Remote, publicKeyAuthMethod, server, exposeDirectoryConfig.Local.Path, []string{"https"}, quitChannel, nil)
+}
+
+// shutdownServer stops the local server giving the running requests a moment to finish, which also closes
+// the exposed archive
+func shutdownServer(server *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		server.Close()
+	}
 }
 
 // ForwardDirectoryViaWebdav is used to expose local directory via Webdav (upload and download)
 func ForwardDirectoryViaWebdav(exposeWebdavConfig lm.ExposeWebdavConfig, publicKeyAuthMethod ssh.AuthMethod, quitChannel <-chan bool) error {
+	defer closeAgentConnection(exposeWebdavConfig.Remote.TunnelID)
 	server, err := getWebdavServer(exposeWebdavConfig)
 	if err != nil {
 		return err
 	}
 
-	return forward(exposeWebdavConfig.Remote, publicKeyAuthMethod, server, exposeWebdavConfig.Local.Path, []string{"https", "davs", "webdav"}, quitChannel)
+	return forward(exposeWebdavConfig.Remote, publicKeyAuthMethod, server, exposeWebdavConfig.Local.Path, []string{"https", "davs", "webdav"}, quitChannel, nil)
+}
+
+// ForwardDirectoryViaDropbox is used to let the public upload files into local directory (upload only)
+func ForwardDirectoryViaDropbox(exposeDropboxConfig lm.ExposeDropboxConfig, publicKeyAuthMethod ssh.AuthMethod, quitChannel <-chan bool) error {
+	defer closeAgentConnection(exposeDropboxConfig.Remote.TunnelID)
+	server, err := getDropboxServer(exposeDropboxConfig)
+	if err != nil {
+		return err
+	}
+
+	return forward(exposeDropboxConfig.Remote, publicKeyAuthMethod, server, exposeDropboxConfig.Local.Path, []string{"https"}, quitChannel, nil)
 }
 
+// ForwardFile is used to expose single local file, the tunnel is closed once download limit or expiry is reached
+func ForwardFile(exposeFileConfig lm.ExposeFileConfig, publicKeyAuthMethod ssh.AuthMethod, quitChannel <-chan bool) error {
+	defer closeAgentConnection(exposeFileConfig.Remote.TunnelID)
+	finished := make(chan string, 2)
+	expiry := &httpserver.Deadline{}
+	server, err := getSingleFileServer(exposeFileConfig, expiry, finished)
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+
+	done := make(chan bool)
+	defer close(done)
+	tunnelQuitChannel := make(chan bool, 1)
+	go func() {
+		select {
+		case <-done:
+			return
+		case <-quitChannel:
+		case reason := <-finished:
+			communication.TunnelInfo(exposeFileConfig.Remote.TunnelID, fmt.Sprintf("%s, closing the tunnel", reason))
+		}
+		tunnelQuitChannel <- true
+	}()
+
+	// sharing time is counted from the moment the file becomes reachable
+	var expiryTimer *time.Timer
+	onListening := func() {
+		if exposeFileConfig.Local.Expire <= 0 {
+			return
+		}
+		duration := exposeFileConfig.Local.Expire
+		expiry.Set(time.Now().Add(duration))
+		expiryTimer = time.AfterFunc(duration, func() {
+			finished <- "Sharing time expired"
+		})
+	}
+	defer func() {
+		if expiryTimer != nil {
+			expiryTimer.Stop()
+		}
+	}()
+
+	return forward(exposeFileConfig.Remote, publicKeyAuthMethod, server, exposeFileConfig.Local.Path, []string{"https"}, tunnelQuitChannel, onListening)
+}
+
+// forward connects the server with the tunnel until quit is requested, onListening is called (if given)
+// once the tunnel is able to accept connections
 func forward(remoteEndpointSpecs lm.RemoteEndpointSpecs,
 	authMethod ssh.AuthMethod, server *http.Server, localEndpoint string,
-	protocols []string, quitChannel <-chan bool) error {
+	protocols []string, quitChannel <-chan bool, onListening func()) error {
 
 	localListenerEndpoint, err := startLocalHTTPServer(remoteEndpointSpecs.TunnelID, server)
 	if err != nil {
 		communication.TunnelStartFailure(remoteEndpointSpecs.TunnelID, err)
 		return err
 	}
-	serverSSHConnHTTPS, err := connectViaSSH(remoteEndpointSpecs.SiteID, remoteEndpointSpecs.TunnelID, authMethod)
+	serverSSHConnHTTPS, err := connectViaSSH(remoteEndpointSpecs, authMethod)
 	if err != nil {
 		communication.TunnelStartFailure(remoteEndpointSpecs.TunnelID, err)
 		return err
@@ -346,6 +726,7 @@ func forward(remoteEndpointSpecs lm.RemoteEndpointSpecs,
 	go func() {
 		communication.TunnelDebug(remoteEndpointSpecs.TunnelID, "Issuing request to provision certificate")
 		var netTransport = &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
 			Dial: (&net.Dialer{
 				Timeout: 30 * time.Second,
 			}).Dial,
@@ -365,6 +746,9 @@ func forward(remoteEndpointSpecs lm.RemoteEndpointSpecs,
 	}()
 
 	communication.TunnelStartSuccess(remoteEndpointSpecs, localEndpoint)
+	if onListening != nil {
+		onListening()
+	}
 
 	acceptedClients := make(chan net.Conn)
 	tunnelTerminatedOnPurpose := false
@@ -378,7 +762,7 @@ func forward(remoteEndpointSpecs lm.RemoteEndpointSpecs,
 				if !(*tunnelTerminatedOnPurpose) {
 					communication.TunnelInfo(remoteEndpointSpecs.TunnelID, err.Error()+" Connection dropped, reconnecting...")
 					(*l).Close()
-					serverSSHConnHTTPS, err = connectViaSSH(remoteEndpointSpecs.SiteID, remoteEndpointSpecs.TunnelID, authMethod)
+					serverSSHConnHTTPS, err = connectViaSSH(remoteEndpointSpecs, authMethod)
 					if err != nil {
 						communication.TunnelStartFailure(remoteEndpointSpecs.TunnelID, err)
 						return
@@ -410,6 +794,7 @@ func forward(remoteEndpointSpecs lm.RemoteEndpointSpecs,
 		select {
 		case <-quitChannel:
 			tunnelTerminatedOnPurpose = true
+			maintenance.Forget(remoteEndpointSpecs.TunnelID)
 			communication.TunnelStopSuccess(remoteEndpointSpecs.TunnelID)
 			return nil
 		case client := <-acceptedClients:
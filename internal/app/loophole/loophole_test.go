@@ -0,0 +1,118 @@
+package loophole
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/loophole/cli/config"
+	lm "github.com/loophole/cli/internal/app/loophole/models"
+)
+
+// startGatewayEndpoints starts local gateway endpoints, the direct one refuses connections unless reachable
+func startGatewayEndpoints(t *testing.T, reachable bool) (lm.Endpoint, lm.Endpoint, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reachable {
+		listener.Close()
+	}
+	port, _ := strconv.Atoi(strings.Split(listener.Addr().String(), ":")[1])
+
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ssh" {
+			http.NotFound(w, r)
+			return
+		}
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err == nil {
+			ws.Close()
+		}
+	}))
+	wsPort, _ := strconv.Atoi(strings.Split(srv.Listener.Addr().String(), ":")[1])
+
+	closeEndpoints := func() {
+		listener.Close()
+		srv.Close()
+	}
+	return lm.Endpoint{Host: "127.0.0.1", Port: int32(port)},
+		lm.Endpoint{Protocol: "ws", Host: "127.0.0.1", Port: int32(wsPort), Path: "/ssh"},
+		closeEndpoints
+}
+
+func TestGatewayIsReachedDirectlyWhenPossible(t *testing.T) {
+	direct, webSocket, closeEndpoints := startGatewayEndpoints(t, true)
+	defer closeEndpoints()
+	oldGateway := config.Config.GatewayEndpoint
+	oldWebSocketGateway := config.Config.GatewayWebSocketEndpoint
+	defer func() {
+		config.Config.GatewayEndpoint = oldGateway
+		config.Config.GatewayWebSocketEndpoint = oldWebSocketGateway
+	}()
+	config.Config.GatewayEndpoint, config.Config.GatewayWebSocketEndpoint = direct, webSocket
+	defer func() { workingTransport = "" }()
+
+	conn, transport, err := dialGateway(lm.RemoteEndpointSpecs{Transport: TransportAuto})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	conn.Close()
+	if transport != TransportTCP {
+		t.Fatalf("Expected direct connection to be used, got '%s'", transport)
+	}
+}
+
+func TestWebSocketIsUsedWhenDirectConnectionFails(t *testing.T) {
+	direct, webSocket, closeEndpoints := startGatewayEndpoints(t, false)
+	defer closeEndpoints()
+	oldGateway := config.Config.GatewayEndpoint
+	oldWebSocketGateway := config.Config.GatewayWebSocketEndpoint
+	defer func() {
+		config.Config.GatewayEndpoint = oldGateway
+		config.Config.GatewayWebSocketEndpoint = oldWebSocketGateway
+	}()
+	config.Config.GatewayEndpoint, config.Config.GatewayWebSocketEndpoint = direct, webSocket
+	defer func() { workingTransport = "" }()
+
+	conn, transport, err := dialGateway(lm.RemoteEndpointSpecs{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	conn.Close()
+	if transport != TransportWebSocket {
+		t.Fatalf("Expected WebSocket to be used, got '%s'", transport)
+	}
+
+	if _, _, err := dialGateway(lm.RemoteEndpointSpecs{Transport: TransportTCP}); err == nil {
+		t.Fatalf("Expected no fallback when TCP transport is required")
+	}
+}
+
+func TestWorkingTransportIsTriedFirst(t *testing.T) {
+	direct, webSocket, closeEndpoints := startGatewayEndpoints(t, true)
+	defer closeEndpoints()
+	oldGateway := config.Config.GatewayEndpoint
+	oldWebSocketGateway := config.Config.GatewayWebSocketEndpoint
+	defer func() {
+		config.Config.GatewayEndpoint = oldGateway
+		config.Config.GatewayWebSocketEndpoint = oldWebSocketGateway
+	}()
+	config.Config.GatewayEndpoint, config.Config.GatewayWebSocketEndpoint = direct, webSocket
+	workingTransport = TransportWebSocket
+	defer func() { workingTransport = "" }()
+
+	conn, transport, err := dialGateway(lm.RemoteEndpointSpecs{Transport: TransportAuto})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	conn.Close()
+	if transport != TransportWebSocket {
+		t.Fatalf("Expected transport which worked before to be used, got '%s'", transport)
+	}
+}
@@ -0,0 +1,8 @@
+package models
+
+// BasicAuthUser is a set of credentials together with access level granted to the user
+type BasicAuthUser struct {
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	Permission string `json:"permission"`
+}
@@ -0,0 +1,7 @@
+package models
+
+// ExposeDropboxConfig represents loophole configuration when directory is exposed for uploads only
+type ExposeDropboxConfig struct {
+	Local  LocalDirectorySpecs `json:"local"`
+	Remote RemoteEndpointSpecs `json:"remote"`
+}
@@ -0,0 +1,7 @@
+package models
+
+// ExposeFileConfig represents loophole configuration when single file is exposed
+type ExposeFileConfig struct {
+	Local  LocalFileSpecs      `json:"local"`
+	Remote RemoteEndpointSpecs `json:"remote"`
+}
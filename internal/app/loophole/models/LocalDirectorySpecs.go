@@ -3,5 +3,13 @@ package models
 // LocalDirectorySpecs is collection of parameters used to describe
 // configuration for local directory to be exposed
 type LocalDirectorySpecs struct {
-	Path string `json:"path"`
+	Path                    string          `json:"path"`
+	SPA                     bool            `json:"spa"`
+	DisableDirectoryListing bool            `json:"disableDirectoryListing"`
+	Exclude                 []string        `json:"exclude"`
+	ShowDotfiles            bool            `json:"showDotfiles"`
+	ReadOnly                bool            `json:"readOnly"`
+	Users                   []BasicAuthUser `json:"users"`
+	Quota                   int64           `json:"quota"`
+	MaxFileSize             int64           `json:"maxFileSize"`
 }
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// LocalFileSpecs is collection of parameters used to describe
+// configuration for single local file to be exposed
+type LocalFileSpecs struct {
+	Path         string        `json:"path"`
+	MaxDownloads int           `json:"maxDownloads"`
+	Expire       time.Duration `json:"expire"`
+}
@@ -6,6 +6,11 @@ type RemoteEndpointSpecs struct {
 	GatewayEndpoint       Endpoint `json:"gatewayEndpoint"`
 	APIEndpoint           Endpoint `json:"apiEndpoint"`
 	IdentityFile          string   `json:"identityFile"`
+	KeyPassphraseFile     string   `json:"keyPassphraseFile"`
+	UseAgent              bool     `json:"useAgent"`
+	AgentKeyFingerprint   string   `json:"agentKeyFingerprint"`
+	Proxy                 string   `json:"proxy"`
+	Transport             string   `json:"transport"`
 	SiteID                string   `json:"siteId"`
 	Domain                string   `json:"domain"`
 	TunnelID              string   `json:"tunnelId"`
@@ -13,4 +18,7 @@ type RemoteEndpointSpecs struct {
 	BasicAuthPassword     string   `json:"basicAuthPassword"`
 	DisableProxyErrorPage bool     `json:"disableProxyErrorPage"`
 	DisableOldCiphers     bool     `json:"disableOldCiphers"`
+	Maintenance           bool     `json:"maintenance"`
+	MaintenancePage       string   `json:"maintenancePage"`
+	MaintenanceRetryAfter int      `json:"maintenanceRetryAfter"`
 }
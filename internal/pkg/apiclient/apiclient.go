@@ -47,13 +47,56 @@ func (err RequestError) Error() string {
 
 var isTokenSaved = token.IsTokenSaved
 var getAccessToken = token.GetAccessToken
-var tokenWasRefreshed = false
-var apiURL = config.Config.APIEndpoint.URI()
+var refreshAccessToken = token.RefreshAccessToken
+var apiURL = ""
+
+// baseURL returns the API endpoint, it's read on every request as it can be overridden at runtime
+func baseURL() string {
+	if apiURL != "" {
+		return apiURL
+	}
+	return config.Config.APIEndpoint.URI()
+}
 
 // RegisterSite is a funtion used to obtain site id and register keys in the gateway
 func RegisterSite(publicKey ssh.PublicKey, requestedSiteID string) (*RegistrationSuccessResponse, error) {
 	publicKeyString := publicKey.Type() + " " + base64.StdEncoding.EncodeToString(publicKey.Marshal())
 
+	data := map[string]string{
+		"key": publicKeyString,
+	}
+	if requestedSiteID != "" {
+		data["id"] = requestedSiteID
+	}
+
+	resp, err := authorizedRequest("POST", "/api/site", data)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, requestError(resp)
+	}
+
+	result := RegistrationSuccessResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+
+	communication.Debug(fmt.Sprintf("Site registration response: %v", result))
+
+	return &result, nil
+}
+
+// authorizedRequest sends the request with access token, retrying once with refreshed token if the current one gets rejected.
+// The response is returned for every status other than 401, closing its body is up to the caller
+func authorizedRequest(method string, path string, data interface{}) (*http.Response, error) {
+	return sendAuthorizedRequest(method, path, data, false)
+}
+
+func sendAuthorizedRequest(method string, path string, data interface{}, afterRefresh bool) (*http.Response, error) {
 	if !isTokenSaved() {
 		return nil, RequestError{
 			Message:    "You're not logged in",
@@ -71,19 +114,15 @@ func RegisterSite(publicKey ssh.PublicKey, requestedSiteID string) (*Registratio
 		}
 	}
 
-	data := map[string]string{
-		"key": publicKeyString,
-	}
-	if requestedSiteID != "" {
-		data["id"] = requestedSiteID
-	}
-
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return nil, err
+	body := []byte{}
+	if data != nil {
+		body, err = json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/api/site", apiURL), bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest(method, fmt.Sprintf("%s%s", baseURL(), path), bytes.NewBuffer(body))
 	if err != nil {
 		return nil, err
 	}
@@ -92,103 +131,110 @@ func RegisterSite(publicKey ssh.PublicKey, requestedSiteID string) (*Registratio
 	req.Header.Set("User-Agent", userAgent())
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
 
-	var netTransport = &http.Transport{
-		Dial: (&net.Dialer{
-			Timeout: 10 * time.Second,
-		}).Dial,
-		TLSHandshakeTimeout: 10 * time.Second,
+	resp, err := apiHTTPClient().Do(req)
+	if err != nil {
+		return nil, err
 	}
-	var netClient = &http.Client{
-		Timeout:   time.Second * 30,
-		Transport: netTransport,
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
 	}
+	defer resp.Body.Close()
 
-	resp, err := netClient.Do(req)
+	errorResponse := ErrorResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&errorResponse)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		errorResponse := ErrorResponse{}
-		err := json.NewDecoder(resp.Body).Decode(&errorResponse)
+	if !afterRefresh {
+		_, err := refreshAccessToken(accessToken)
 		if err != nil {
-			return nil, err
-		}
-
-		switch resp.StatusCode {
-		case http.StatusBadRequest:
+			communication.Debug(fmt.Sprintf("Refreshing token failed: %s", err.Error()))
 			return nil, RequestError{
-				Message: errorResponse.Message,
-				Details: `The given hostname didn't match the requirements:
-- Starts with a letter
-- Contains only small letters, numbers and single dashes (-) between them
-- Ends with a small letter or number`,
-				StatusCode: resp.StatusCode,
-			}
-		case http.StatusUnauthorized:
-			if !tokenWasRefreshed {
-				err := token.RefreshToken()
-				if err != nil {
-					return nil, RequestError{
-						Message:    "Authentication failed, then refreshing token failed",
-						Details:    errorResponse.Message,
-						StatusCode: resp.StatusCode,
-					}
-				}
-				tokenWasRefreshed = true
-				return RegisterSite(publicKey, requestedSiteID)
-			}
-			return nil, RequestError{
-				Message:    "Authentication failed, try logging out and logging in again",
+				Message:    "Authentication failed, then refreshing token failed",
 				Details:    errorResponse.Message,
 				StatusCode: resp.StatusCode,
 			}
+		}
+		return sendAuthorizedRequest(method, path, data, true)
+	}
+	return nil, RequestError{
+		Message:    "Authentication failed, try logging out and logging in again",
+		Details:    errorResponse.Message,
+		StatusCode: resp.StatusCode,
+	}
+}
 
-		case http.StatusForbidden:
-			return nil, RequestError{
-				Message:    "You don't have required permissions to establish tunnel with given parameters",
-				Details:    errorResponse.Message,
-				StatusCode: resp.StatusCode,
-			}
-		case http.StatusConflict:
-			return nil, RequestError{
-				Message:    "The given hostname is already taken by different user",
-				Details:    errorResponse.Message,
-				StatusCode: resp.StatusCode,
-			}
-		case http.StatusUnprocessableEntity:
-			return nil, RequestError{
-				Message: errorResponse.Message,
-				Details: `The given hostname didn't match the requirements:
+// requestError maps the failed response to RequestError describing the problem
+func requestError(resp *http.Response) error {
+	errorResponse := ErrorResponse{}
+	err := json.NewDecoder(resp.Body).Decode(&errorResponse)
+	if err != nil {
+		return err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusBadRequest:
+		return RequestError{
+			Message: errorResponse.Message,
+			Details: `The given hostname didn't match the requirements:
+- Starts with a letter
+- Contains only small letters, numbers and single dashes (-) between them
+- Ends with a small letter or number`,
+			StatusCode: resp.StatusCode,
+		}
+	case http.StatusForbidden:
+		return RequestError{
+			Message:    "You don't have required permissions to establish tunnel with given parameters",
+			Details:    errorResponse.Message,
+			StatusCode: resp.StatusCode,
+		}
+	case http.StatusNotFound:
+		return RequestError{
+			Message:    "The requested resource wasn't found, check the API URL",
+			Details:    errorResponse.Message,
+			StatusCode: resp.StatusCode,
+		}
+	case http.StatusConflict:
+		return RequestError{
+			Message:    "The given hostname is already taken by different user",
+			Details:    errorResponse.Message,
+			StatusCode: resp.StatusCode,
+		}
+	case http.StatusUnprocessableEntity:
+		return RequestError{
+			Message: errorResponse.Message,
+			Details: `The given hostname didn't match the requirements:
 - Starts with a letter
 - Contains only small letters, numbers and single dashes (-) between them
 - Ends with a small letter or number
 - Minimum 6 characters (not applicable for premium users`,
-				StatusCode: resp.StatusCode,
-			}
-		default:
-			return nil, RequestError{
-				Message:    errorResponse.Message,
-				Details:    "Something unexpected happened, please let developers know",
-				StatusCode: resp.StatusCode,
-			}
+			StatusCode: resp.StatusCode,
+		}
+	default:
+		return RequestError{
+			Message:    errorResponse.Message,
+			Details:    "Something unexpected happened, please let developers know",
+			StatusCode: resp.StatusCode,
 		}
 	}
+}
 
-	result := RegistrationSuccessResponse{}
-	err = json.NewDecoder(resp.Body).Decode(&result)
-	if err != nil {
-		return nil, err
+func apiHTTPClient() *http.Client {
+	var netTransport = &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		Dial: (&net.Dialer{
+			Timeout: 10 * time.Second,
+		}).Dial,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	return &http.Client{
+		Timeout:   time.Second * 30,
+		Transport: netTransport,
 	}
-
-	communication.Debug(fmt.Sprintf("Site registration response: %v", result))
-
-	return &result, nil
 }
 
 func GetLatestAvailableVersion() (*InfoSuccessResponse, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/info", apiURL), bytes.NewBuffer([]byte{}))
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/info", baseURL()), bytes.NewBuffer([]byte{}))
 	if err != nil {
 		return nil, err
 	}
@@ -197,6 +243,7 @@ func GetLatestAvailableVersion() (*InfoSuccessResponse, error) {
 	req.Header.Set("User-Agent", userAgent())
 
 	var netTransport = &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
 		Dial: (&net.Dialer{
 			Timeout: 5 * time.Second,
 		}).Dial,
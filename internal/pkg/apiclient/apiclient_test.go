@@ -364,3 +364,56 @@ func getPublicSSHKey() (ssh.PublicKey, error) {
 	}
 	return publicKey, nil
 }
+
+func TestRegisterSiteRetriesOnceWithRefreshedToken(t *testing.T) {
+	oldIsTokenSaved := isTokenSaved
+	defer func() { isTokenSaved = oldIsTokenSaved }()
+	isTokenSaved = func() bool { return true }
+
+	currentToken := "expired-token"
+	oldGetAccessToken := getAccessToken
+	defer func() { getAccessToken = oldGetAccessToken }()
+	getAccessToken = func() (string, error) { return currentToken, nil }
+
+	refreshCalls := 0
+	oldRefreshAccessToken := refreshAccessToken
+	defer func() { refreshAccessToken = oldRefreshAccessToken }()
+	refreshAccessToken = func(rejected string) (string, error) {
+		refreshCalls++
+		if rejected != "expired-token" {
+			t.Fatalf("Expected rejected token to be passed, got '%s'", rejected)
+		}
+		currentToken = "fresh-token"
+		return currentToken, nil
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fresh-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"statusCode": 401, "message": "Token expired"}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"siteId": "refreshedsite"}`))
+	}))
+	defer srv.Close()
+
+	apiURL = srv.URL
+	publicKey, err := getPublicSSHKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		currentToken = "expired-token"
+		result, err := RegisterSite(publicKey, "")
+		if err != nil {
+			t.Fatalf("Unexpected error returned: %v", err)
+		}
+		if result.SiteID != "refreshedsite" {
+			t.Fatalf("Expected site 'refreshedsite', got '%s'", result.SiteID)
+		}
+	}
+	if refreshCalls != 2 {
+		t.Fatalf("Expected token to be refreshed for every rejected registration, got %d refreshes", refreshCalls)
+	}
+}
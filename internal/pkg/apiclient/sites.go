@@ -0,0 +1,115 @@
+package apiclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/loophole/cli/internal/pkg/communication"
+)
+
+// Site defines the json format in which reserved site is returned
+type Site struct {
+	SiteID string `json:"siteId"`
+	Domain string `json:"domain"`
+}
+
+// AvailabilityResponse defines the json format in which the hostname availability is returned
+type AvailabilityResponse struct {
+	Available bool `json:"available"`
+}
+
+// ListSites returns the hostnames reserved by the user
+func ListSites() ([]Site, error) {
+	resp, err := authorizedRequest("GET", "/api/site", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, requestError(resp)
+	}
+
+	result := []Site{}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+
+	communication.Debug(fmt.Sprintf("Site list response: %v", result))
+
+	return result, nil
+}
+
+// ReserveSite reserves the hostname for the user without starting a tunnel
+func ReserveSite(siteID string) (*Site, error) {
+	resp, err := authorizedRequest("PUT", sitePath(siteID), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, siteRequestError(resp)
+	}
+
+	result := Site{}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+
+	communication.Debug(fmt.Sprintf("Site reservation response: %v", result))
+
+	return &result, nil
+}
+
+// ReleaseSite releases the hostname reserved by the user, so that it can be taken by anyone
+func ReleaseSite(siteID string) error {
+	resp, err := authorizedRequest("DELETE", sitePath(siteID), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return siteRequestError(resp)
+	}
+	return nil
+}
+
+// CheckSite tells whether the hostname can be used by the user, invalid hostnames are reported with RequestError
+func CheckSite(siteID string) (bool, error) {
+	resp, err := authorizedRequest("GET", sitePath(siteID)+"/availability", nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, requestError(resp)
+	}
+
+	result := AvailabilityResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return false, err
+	}
+	return result.Available, nil
+}
+
+// siteRequestError maps the failed response of request about single hostname, where not found means it isn't reserved by the user
+func siteRequestError(resp *http.Response) error {
+	err := requestError(resp)
+	if requestErr, ok := err.(RequestError); ok && requestErr.StatusCode == http.StatusNotFound {
+		requestErr.Message = "The given hostname is not reserved by you"
+		return requestErr
+	}
+	return err
+}
+
+func sitePath(siteID string) string {
+	return fmt.Sprintf("/api/site/%s", url.PathEscape(siteID))
+}
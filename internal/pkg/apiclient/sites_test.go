@@ -0,0 +1,214 @@
+package apiclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// sitesServer returns server handling site requests with the handler, checking they are authorized
+func sitesServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer some-token" {
+			t.Errorf("Expected request to be authorized, got '%s'", r.Header.Get("Authorization"))
+		}
+		handler(w, r)
+	}))
+}
+
+func TestListSitesReturnsReservedSites(t *testing.T) {
+	oldIsTokenSaved := isTokenSaved
+	defer func() { isTokenSaved = oldIsTokenSaved }()
+	isTokenSaved = func() bool { return true }
+
+	oldGetAccessToken := getAccessToken
+	defer func() { getAccessToken = oldGetAccessToken }()
+	getAccessToken = func() (string, error) { return "some-token", nil }
+
+	srv := sitesServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/api/site" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`[{"siteId": "first", "domain": "loophole.site"}, {"siteId": "second", "domain": "loophole.site"}]`))
+	})
+	defer srv.Close()
+
+	oldAPIURL := apiURL
+	defer func() { apiURL = oldAPIURL }()
+	apiURL = srv.URL
+
+	sites, err := ListSites()
+	if err != nil {
+		t.Fatalf("Unexpected error returned: %v", err)
+	}
+	if len(sites) != 2 || sites[0].SiteID != "first" || sites[1].Domain != "loophole.site" {
+		t.Fatalf("Expected two sites to be returned, got %v", sites)
+	}
+}
+
+func TestReserveSiteConflictShouldPropagateError(t *testing.T) {
+	oldIsTokenSaved := isTokenSaved
+	defer func() { isTokenSaved = oldIsTokenSaved }()
+	isTokenSaved = func() bool { return true }
+
+	oldGetAccessToken := getAccessToken
+	defer func() { getAccessToken = oldGetAccessToken }()
+	getAccessToken = func() (string, error) { return "some-token", nil }
+
+	srv := sitesServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/api/site/taken" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"statusCode": 409, "error": "Conflict", "message": "Site taken"}`))
+	})
+	defer srv.Close()
+
+	oldAPIURL := apiURL
+	defer func() { apiURL = oldAPIURL }()
+	apiURL = srv.URL
+
+	result, err := ReserveSite("taken")
+	requestErr, ok := err.(RequestError)
+	if !ok {
+		t.Fatalf("Expected RequestError to be returned, got: %v", err)
+	}
+	if result != nil {
+		t.Fatalf("Expected result to be nil, got %v", result)
+	}
+	if requestErr.StatusCode != http.StatusConflict || requestErr.Details != "Site taken" {
+		t.Fatalf("Expected conflict error, got %v", requestErr)
+	}
+}
+
+func TestReserveSiteReturnsReservedSite(t *testing.T) {
+	oldIsTokenSaved := isTokenSaved
+	defer func() { isTokenSaved = oldIsTokenSaved }()
+	isTokenSaved = func() bool { return true }
+
+	oldGetAccessToken := getAccessToken
+	defer func() { getAccessToken = oldGetAccessToken }()
+	getAccessToken = func() (string, error) { return "some-token", nil }
+
+	srv := sitesServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"siteId": "mysite", "domain": "loophole.site"}`))
+	})
+	defer srv.Close()
+
+	oldAPIURL := apiURL
+	defer func() { apiURL = oldAPIURL }()
+	apiURL = srv.URL
+
+	result, err := ReserveSite("mysite")
+	if err != nil {
+		t.Fatalf("Unexpected error returned: %v", err)
+	}
+	if result.SiteID != "mysite" {
+		t.Fatalf("Expected site 'mysite', got '%s'", result.SiteID)
+	}
+}
+
+func TestReleaseSiteNotReservedShouldPropagateError(t *testing.T) {
+	released := ""
+	oldIsTokenSaved := isTokenSaved
+	defer func() { isTokenSaved = oldIsTokenSaved }()
+	isTokenSaved = func() bool { return true }
+
+	oldGetAccessToken := getAccessToken
+	defer func() { getAccessToken = oldGetAccessToken }()
+	getAccessToken = func() (string, error) { return "some-token", nil }
+
+	srv := sitesServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if r.URL.Path == "/api/site/mysite" {
+			released = "mysite"
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"statusCode": 404, "error": "Not Found", "message": "No such site"}`))
+	})
+	defer srv.Close()
+
+	oldAPIURL := apiURL
+	defer func() { apiURL = oldAPIURL }()
+	apiURL = srv.URL
+
+	if err := ReleaseSite("mysite"); err != nil || released != "mysite" {
+		t.Fatalf("Expected site to be released, got: %v", err)
+	}
+	err := ReleaseSite("othersite")
+	requestErr, ok := err.(RequestError)
+	if !ok || requestErr.StatusCode != http.StatusNotFound || requestErr.Message != "The given hostname is not reserved by you" {
+		t.Fatalf("Expected not reserved RequestError to be returned, got: %v", err)
+	}
+}
+
+func TestListSitesNotFoundIsNotReportedAsUnreservedHostname(t *testing.T) {
+	oldIsTokenSaved := isTokenSaved
+	defer func() { isTokenSaved = oldIsTokenSaved }()
+	isTokenSaved = func() bool { return true }
+
+	oldGetAccessToken := getAccessToken
+	defer func() { getAccessToken = oldGetAccessToken }()
+	getAccessToken = func() (string, error) { return "some-token", nil }
+
+	srv := sitesServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"statusCode": 404, "error": "Not Found", "message": "Cannot GET /api/site"}`))
+	})
+	defer srv.Close()
+
+	oldAPIURL := apiURL
+	defer func() { apiURL = oldAPIURL }()
+	apiURL = srv.URL
+
+	_, err := ListSites()
+	requestErr, ok := err.(RequestError)
+	if !ok || requestErr.StatusCode != http.StatusNotFound || strings.Contains(requestErr.Message, "hostname") {
+		t.Fatalf("Expected not found RequestError without hostname message, got: %v", err)
+	}
+}
+
+func TestCheckSiteReportsAvailability(t *testing.T) {
+	oldIsTokenSaved := isTokenSaved
+	defer func() { isTokenSaved = oldIsTokenSaved }()
+	isTokenSaved = func() bool { return true }
+
+	oldGetAccessToken := getAccessToken
+	defer func() { getAccessToken = oldGetAccessToken }()
+	getAccessToken = func() (string, error) { return "some-token", nil }
+
+	srv := sitesServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/site/free/availability":
+			w.Write([]byte(`{"available": true}`))
+		case "/api/site/taken/availability":
+			w.Write([]byte(`{"available": false}`))
+		default:
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"statusCode": 422, "error": "Unprocessable Entity", "message": "Invalid hostname"}`))
+		}
+	})
+	defer srv.Close()
+
+	oldAPIURL := apiURL
+	defer func() { apiURL = oldAPIURL }()
+	apiURL = srv.URL
+
+	if available, err := CheckSite("free"); err != nil || !available {
+		t.Fatalf("Expected hostname to be available, got %v: %v", available, err)
+	}
+	if available, err := CheckSite("taken"); err != nil || available {
+		t.Fatalf("Expected hostname to be unavailable, got %v: %v", available, err)
+	}
+	_, err := CheckSite("x")
+	requestErr, ok := err.(RequestError)
+	if !ok || requestErr.StatusCode != http.StatusUnprocessableEntity || requestErr.Message != "Invalid hostname" {
+		t.Fatalf("Expected RequestError for invalid hostname, got: %v", err)
+	}
+}
@@ -0,0 +1,409 @@
+package archivefs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// IsArchive returns whether the file name has extension of supported archive format
+func IsArchive(name string) bool {
+	name = strings.ToLower(name)
+	for _, extension := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(name, extension) {
+			return true
+		}
+	}
+	return false
+}
+
+// FS is a read-only filesystem with the content of an archive
+type FS struct {
+	file    *os.File
+	entries map[string]*entry
+}
+
+type entry struct {
+	name     string
+	dir      bool
+	size     int64
+	mode     fs.FileMode
+	modTime  time.Time
+	children []*entry
+
+	// section gives random access to entries stored without compression
+	section *io.SectionReader
+	// open returns sequential reader of the content
+	open func() (io.ReadCloser, error)
+}
+
+// Open reads the index of the archive, content of the entries is read only when requested
+func Open(name string) (*FS, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	afs := &FS{
+		file:    file,
+		entries: map[string]*entry{".": {name: ".", dir: true, mode: fs.ModeDir | 0555, modTime: stat.ModTime()}},
+	}
+	lowerName := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lowerName, ".zip"):
+		err = afs.indexZip(stat.Size())
+	case strings.HasSuffix(lowerName, ".tar"):
+		err = afs.indexTar(false)
+	case strings.HasSuffix(lowerName, ".tar.gz"), strings.HasSuffix(lowerName, ".tgz"):
+		err = afs.indexTar(true)
+	default:
+		err = fmt.Errorf("Unsupported archive format of '%s'", name)
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("There was a problem reading archive '%s': %v", name, err)
+	}
+
+	for _, e := range afs.entries {
+		sort.Slice(e.children, func(i, j int) bool { return e.children[i].name < e.children[j].name })
+	}
+	return afs, nil
+}
+
+// Close closes the archive file
+func (afs *FS) Close() error {
+	return afs.file.Close()
+}
+
+func (afs *FS) indexZip(size int64) error {
+	reader, err := zip.NewReader(afs.file, size)
+	if err != nil {
+		return err
+	}
+	for _, zipFile := range reader.File {
+		zipFile := zipFile
+		if strings.HasSuffix(zipFile.Name, "/") {
+			afs.add(zipFile.Name, &entry{dir: true, mode: fs.ModeDir | 0555, modTime: zipFile.Modified})
+			continue
+		}
+		e := &entry{
+			size:    int64(zipFile.UncompressedSize64),
+			mode:    0444,
+			modTime: zipFile.Modified,
+			open:    zipFile.Open,
+		}
+		if zipFile.Method == zip.Store {
+			if offset, err := zipFile.DataOffset(); err == nil {
+				e.section = io.NewSectionReader(afs.file, offset, e.size)
+			}
+		}
+		afs.add(zipFile.Name, e)
+	}
+	return nil
+}
+
+func (afs *FS) indexTar(compressed bool) error {
+	source, err := afs.tarStream(compressed)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	counter := &countingReader{reader: source}
+	reader := tar.NewReader(counter)
+
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			afs.add(header.Name, &entry{dir: true, mode: fs.ModeDir | 0555, modTime: header.ModTime})
+		case tar.TypeReg:
+			// tar reader doesn't read ahead, so the data starts right after the consumed header
+			offset := counter.count
+			e := &entry{
+				size:    header.Size,
+				mode:    0444,
+				modTime: header.ModTime,
+			}
+			if compressed {
+				e.open = afs.openCompressedTarEntry(offset, header.Size)
+			} else {
+				e.section = io.NewSectionReader(afs.file, offset, header.Size)
+			}
+			afs.add(header.Name, e)
+		}
+	}
+}
+
+func (afs *FS) tarStream(compressed bool) (io.ReadCloser, error) {
+	reader := io.NewSectionReader(afs.file, 0, 1<<62)
+	if !compressed {
+		return io.NopCloser(reader), nil
+	}
+	return gzip.NewReader(reader)
+}
+
+// openCompressedTarEntry decompresses the archive up to the entry, as gzip streams can't be read from the middle
+func (afs *FS) openCompressedTarEntry(offset int64, size int64) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		stream, err := afs.tarStream(true)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.CopyN(io.Discard, stream, offset); err != nil {
+			stream.Close()
+			return nil, err
+		}
+		return &limitedReadCloser{Reader: io.LimitReader(stream, size), Closer: stream}, nil
+	}
+}
+
+// add registers the entry together with all its parent directories, entries with unsafe names are skipped
+func (afs *FS) add(name string, e *entry) {
+	name = path.Clean(strings.TrimPrefix(strings.ReplaceAll(name, "\\", "/"), "/"))
+	if name == "." || !fs.ValidPath(name) {
+		return
+	}
+	if existing, ok := afs.entries[name]; ok {
+		if existing.dir && e.dir {
+			existing.modTime = e.modTime
+			return
+		}
+		afs.removeChild(path.Dir(name), existing)
+	}
+	e.name = name
+	afs.entries[name] = e
+	parent := afs.parent(name)
+	parent.children = append(parent.children, e)
+}
+
+func (afs *FS) parent(name string) *entry {
+	dir := path.Dir(name)
+	if parent, ok := afs.entries[dir]; ok && parent.dir {
+		return parent
+	}
+	parent := &entry{dir: true, mode: fs.ModeDir | 0555}
+	afs.add(dir, parent)
+	return parent
+}
+
+func (afs *FS) removeChild(dir string, child *entry) {
+	parent := afs.entries[dir]
+	for i, candidate := range parent.children {
+		if candidate == child {
+			parent.children = append(parent.children[:i], parent.children[i+1:]...)
+			return
+		}
+	}
+}
+
+// Open implements fs.FS
+func (afs *FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	e, ok := afs.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if e.dir {
+		return &dirFile{entry: e}, nil
+	}
+	return &file{entry: e}, nil
+}
+
+// Stat implements fs.StatFS
+func (afs *FS) Stat(name string) (fs.FileInfo, error) {
+	e, ok := afs.entries[name]
+	if !ok || !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return fileInfo{e}, nil
+}
+
+type fileInfo struct {
+	entry *entry
+}
+
+func (fi fileInfo) Name() string       { return path.Base(fi.entry.name) }
+func (fi fileInfo) Size() int64        { return fi.entry.size }
+func (fi fileInfo) Mode() fs.FileMode  { return fi.entry.mode }
+func (fi fileInfo) ModTime() time.Time { return fi.entry.modTime }
+func (fi fileInfo) IsDir() bool        { return fi.entry.dir }
+func (fi fileInfo) Sys() interface{}   { return nil }
+
+type dirFile struct {
+	entry    *entry
+	position int
+}
+
+func (df *dirFile) Stat() (fs.FileInfo, error) { return fileInfo{df.entry}, nil }
+func (df *dirFile) Close() error               { return nil }
+
+func (df *dirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: df.entry.name, Err: fs.ErrInvalid}
+}
+
+// ReadDir implements fs.ReadDirFile
+func (df *dirFile) ReadDir(count int) ([]fs.DirEntry, error) {
+	remaining := df.entry.children[df.position:]
+	if count > 0 && len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if count > 0 && count < len(remaining) {
+		remaining = remaining[:count]
+	}
+	df.position += len(remaining)
+
+	result := make([]fs.DirEntry, len(remaining))
+	for i, child := range remaining {
+		result[i] = fs.FileInfoToDirEntry(fileInfo{child})
+	}
+	return result, nil
+}
+
+// headCacheSize is how much of the beginning of compressed entry is kept in memory, so that seeking back to the
+// start, e.g. after the content type was sniffed, doesn't decompress the archive again
+const headCacheSize = 1 << 20
+
+// file gives seekable access to the entry, compressed entries are read with a single decompressor which is
+// started again only when seeking back behind the cached beginning of the content
+type file struct {
+	entry    *entry
+	reader   io.ReadCloser
+	offset   int64
+	head     []byte
+	position int64
+}
+
+func (f *file) Stat() (fs.FileInfo, error) { return fileInfo{f.entry}, nil }
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.position >= f.entry.size {
+		return 0, io.EOF
+	}
+	if f.entry.section != nil {
+		n, err := f.entry.section.ReadAt(p, f.position)
+		f.position += int64(n)
+		if err == io.EOF && n > 0 {
+			err = nil
+		}
+		return n, err
+	}
+
+	if f.position < int64(len(f.head)) {
+		n := copy(p, f.head[f.position:])
+		f.position += int64(n)
+		return n, nil
+	}
+	if f.reader == nil || f.position < f.offset {
+		if f.reader != nil {
+			f.reader.Close()
+		}
+		reader, err := f.entry.open()
+		if err != nil {
+			return 0, err
+		}
+		f.reader = reader
+		f.offset = 0
+	}
+	if err := f.skip(); err != nil {
+		return 0, err
+	}
+	n, err := f.reader.Read(p)
+	f.cache(p[:n])
+	f.offset += int64(n)
+	f.position = f.offset
+	return n, err
+}
+
+// skip reads the decompressed content up to the position
+func (f *file) skip() error {
+	buffer := make([]byte, 32*1024)
+	for f.offset < f.position {
+		chunk := buffer
+		if remaining := f.position - f.offset; remaining < int64(len(chunk)) {
+			chunk = chunk[:remaining]
+		}
+		n, err := f.reader.Read(chunk)
+		f.cache(chunk[:n])
+		f.offset += int64(n)
+		if err == io.EOF && f.offset < f.position {
+			return io.ErrUnexpectedEOF
+		} else if err != nil && err != io.EOF {
+			return err
+		}
+	}
+	return nil
+}
+
+// cache keeps the content read at the current offset if it belongs to the beginning of the entry
+func (f *file) cache(content []byte) {
+	if f.offset != int64(len(f.head)) || len(f.head) >= headCacheSize {
+		return
+	}
+	if free := headCacheSize - len(f.head); len(content) > free {
+		content = content[:free]
+	}
+	f.head = append(f.head, content...)
+}
+
+// Seek implements io.Seeker, required for ranged downloads
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.position
+	case io.SeekEnd:
+		offset += f.entry.size
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.entry.name, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.entry.name, Err: fs.ErrInvalid}
+	}
+	f.position = offset
+	return offset, nil
+}
+
+func (f *file) Close() error {
+	if f.reader != nil {
+		return f.reader.Close()
+	}
+	return nil
+}
+
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.reader.Read(p)
+	cr.count += int64(n)
+	return n, err
+}
+
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,177 @@
+package archivefs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+var testContent = map[string]string{
+	"readme.txt":          "hello from the archive",
+	"logs/app.log":        "0123456789abcdefghij",
+	"logs/nested/old.log": "old entries",
+}
+
+func createZip(t *testing.T, dir string) string {
+	name := filepath.Join(dir, "bundle.zip")
+	file, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	writer := zip.NewWriter(file)
+	for entryName, content := range testContent {
+		method := zip.Deflate
+		if entryName == "logs/app.log" {
+			method = zip.Store
+		}
+		entryWriter, err := writer.CreateHeader(&zip.FileHeader{Name: entryName, Method: method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		entryWriter.Write([]byte(content))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func createTar(t *testing.T, dir string, compressed bool) string {
+	name := filepath.Join(dir, "bundle.tar")
+	if compressed {
+		name += ".gz"
+	}
+	file, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var output io.Writer = file
+	if compressed {
+		gzipWriter := gzip.NewWriter(file)
+		defer gzipWriter.Close()
+		output = gzipWriter
+	}
+	writer := tar.NewWriter(output)
+	writer.WriteHeader(&tar.Header{Name: "logs/", Typeflag: tar.TypeDir, Mode: 0755})
+	for entryName, content := range testContent {
+		writer.WriteHeader(&tar.Header{Name: entryName, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))})
+		writer.Write([]byte(content))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestArchivesAreValidFileSystems(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loophole-archivefs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	archives := []string{createZip(t, dir), createTar(t, dir, false), createTar(t, dir, true)}
+	for _, archive := range archives {
+		afs, err := Open(archive)
+		if err != nil {
+			t.Fatalf("Expected '%s' to be opened, got: %v", archive, err)
+		}
+		if err := fstest.TestFS(afs, "readme.txt", "logs/app.log", "logs/nested/old.log"); err != nil {
+			t.Fatalf("Expected '%s' to be valid filesystem, got: %v", archive, err)
+		}
+		for entryName, expected := range testContent {
+			content, err := readAll(afs, entryName)
+			if err != nil || content != expected {
+				t.Fatalf("Expected '%s' in '%s' to contain '%s', got '%s' (%v)", entryName, archive, expected, content, err)
+			}
+		}
+		afs.Close()
+	}
+}
+
+func TestArchiveEntriesAreSeekable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loophole-archivefs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, archive := range []string{createZip(t, dir), createTar(t, dir, true)} {
+		afs, err := Open(archive)
+		if err != nil {
+			t.Fatalf("Expected '%s' to be opened, got: %v", archive, err)
+		}
+		for _, entryName := range []string{"readme.txt", "logs/app.log"} {
+			f, _ := afs.Open(entryName)
+			seeker := f.(io.ReadSeeker)
+			seeker.Seek(6, io.SeekStart)
+			part := make([]byte, 4)
+			io.ReadFull(seeker, part)
+			expected := testContent[entryName][6:10]
+			if string(part) != expected {
+				t.Fatalf("Expected '%s' at offset 6 of '%s', got '%s'", expected, entryName, part)
+			}
+			f.Close()
+		}
+		afs.Close()
+	}
+}
+
+func TestSeekingBackDoesNotDecompressAgain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loophole-archivefs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	afs, err := Open(createTar(t, dir, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer afs.Close()
+	f, err := afs.Open("readme.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	seeker := f.(*file)
+	sniffed := make([]byte, 5)
+	io.ReadFull(seeker, sniffed)
+	reader := seeker.reader
+	seeker.Seek(0, io.SeekStart)
+	content, _ := ioutil.ReadAll(seeker)
+	if string(content) != testContent["readme.txt"] {
+		t.Fatalf("Expected '%s' after seeking back, got '%s'", testContent["readme.txt"], content)
+	}
+	if seeker.reader != reader {
+		t.Fatalf("Expected the decompressor of the file to be reused")
+	}
+}
+
+func TestIsArchive(t *testing.T) {
+	for name, expected := range map[string]bool{"a.zip": true, "a.TAR.GZ": true, "a.tgz": true, "a.tar": true, "a.gz": false, "dir": false} {
+		if IsArchive(name) != expected {
+			t.Fatalf("Expected IsArchive('%s') to be %v", name, expected)
+		}
+	}
+}
+
+func readAll(afs *FS, name string) (string, error) {
+	f, err := afs.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	content, err := ioutil.ReadAll(f)
+	return string(content), err
+}
@@ -16,7 +16,7 @@ var terminalState *term.State = &term.State{}
 // SetupCloseHandler ensures that CTRL+C inputs are properly processed, restoring the terminal state from not displaying entered characters where necessary
 func SetupCloseHandler(feedbackFormURL string) {
 	var terminalState *term.State
-	c := make(chan os.Signal)
+	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
 	if !inpututil.IsUsingPipe() { //don't try to get terminal state if using a pipe
@@ -24,6 +24,10 @@ type Mechanism interface {
 	ApplicationStart(loggedIn bool, idToken string)
 	ApplicationStop()
 
+	ProfileSwitch(current string, profiles []string)
+
+	PassphraseRequest(keyFile string) (string, error)
+
 	TunnelStart(tunnelID string)
 
 	TunnelStartSuccess(remoteConfig coreModels.RemoteEndpointSpecs, localEndpoint string)
@@ -105,6 +109,16 @@ func ApplicationStop() {
 	communicationMechanism.ApplicationStop()
 }
 
+// ProfileSwitch is the communicate about active profile and the ones available
+func ProfileSwitch(current string, profiles []string) {
+	communicationMechanism.ProfileSwitch(current, profiles)
+}
+
+// PassphraseRequest asks the user for the passphrase of encrypted key
+func PassphraseRequest(keyFile string) (string, error) {
+	return communicationMechanism.PassphraseRequest(keyFile)
+}
+
 // LoginStart is the communicate to notify about login process being started
 func LoginStart(deviceCodeSpec authModels.DeviceCodeSpec) {
 	communicationMechanism.LoginStart(deviceCodeSpec)
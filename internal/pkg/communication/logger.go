@@ -3,6 +3,7 @@ package communication
 import (
 	"fmt"
 	"io"
+	"os"
 	"sync"
 	"time"
 
@@ -15,12 +16,15 @@ import (
 	"github.com/mattn/go-colorable"
 	"github.com/mdp/qrterminal"
 	"github.com/rs/zerolog/log"
+	"golang.org/x/term"
 )
 
 type stdoutLogger struct {
 	colorableOutput io.Writer
 	loader          *spinner.Spinner
 	messageMutex    sync.Mutex
+	// passphraseMutex keeps prompts from interleaving, messages are still written while the passphrase is typed
+	passphraseMutex sync.Mutex
 }
 
 // NewStdOutLogger is stdout mechanism constructor
@@ -104,6 +108,32 @@ func (l *stdoutLogger) ApplicationStop() {
 	fmt.Fprintln(l.colorableOutput, aurora.Cyan(fmt.Sprintf("Thank you for using Loophole. Please give us your feedback via %s and help us improve our services.", config.Config.FeedbackFormURL)))
 }
 
+func (l *stdoutLogger) ProfileSwitch(current string, profiles []string) {
+	l.messageMutex.Lock()
+	defer l.messageMutex.Unlock()
+	log.Info().Msg(fmt.Sprintf("Using profile '%s'", current))
+}
+
+func (l *stdoutLogger) PassphraseRequest(keyFile string) (string, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("Cannot ask for passphrase of '%s' without terminal", keyFile)
+	}
+	l.passphraseMutex.Lock()
+	defer l.passphraseMutex.Unlock()
+
+	l.messageMutex.Lock()
+	fmt.Fprintf(l.colorableOutput, "Enter passphrase for key '%s': ", keyFile)
+	l.messageMutex.Unlock()
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	l.messageMutex.Lock()
+	fmt.Fprintln(l.colorableOutput)
+	l.messageMutex.Unlock()
+	if err != nil {
+		return "", fmt.Errorf("There was a problem reading passphrase: %v", err)
+	}
+	return string(passphrase), nil
+}
+
 func (l *stdoutLogger) TunnelStart(tunnelID string) {
 	l.messageMutex.Lock()
 	defer l.messageMutex.Unlock()
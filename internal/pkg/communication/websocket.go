@@ -3,8 +3,11 @@
 package communication
 
 import (
+	"fmt"
 	"sync"
+	"time"
 
+	"github.com/beevik/guid"
 	"github.com/gorilla/websocket"
 	"github.com/loophole/cli/config"
 	coreModels "github.com/loophole/cli/internal/app/loophole/models"
@@ -27,6 +30,10 @@ const (
 	MessageTypeAppStop             MessageType = "MT_ApplicationStop"
 	MessageTypeNewVersionAvailable MessageType = "MT_ApplicationNewVersionAvailable"
 
+	MessageTypeProfileSwitch MessageType = "MT_ProfileSwitch"
+
+	MessageTypePassphraseRequest MessageType = "MT_PassphraseRequest"
+
 	MessageTypeLogin        MessageType = "MT_Login"
 	MessageTypeLoginSuccess MessageType = "MT_LoginSuccess"
 	MessageTypeLoginFailure MessageType = "MT_LoginFailure"
@@ -92,6 +99,18 @@ type appNewVersionMessage struct {
 	Version string      `json:"version"`
 }
 
+type profileSwitchMessage struct {
+	Type     MessageType `json:"type"`
+	Profile  string      `json:"profile"`
+	Profiles []string    `json:"profiles"`
+}
+
+type passphraseRequestMessage struct {
+	Type      MessageType `json:"type"`
+	RequestID string      `json:"requestId"`
+	KeyFile   string      `json:"keyFile"`
+}
+
 type loginMessage struct {
 	Type                    MessageType `json:"type"`
 	DeviceCode              string      `json:"deviceCode"`
@@ -270,6 +289,69 @@ func (l *websocketLogger) ApplicationStop() {
 	})
 }
 
+func (l *websocketLogger) ProfileSwitch(current string, profiles []string) {
+	l.write(profileSwitchMessage{
+		Type:     MessageTypeProfileSwitch,
+		Profile:  current,
+		Profiles: profiles,
+	})
+}
+
+type passphraseResponse struct {
+	passphrase string
+	cancelled  bool
+}
+
+// passphraseRequestTimeout is how long the UI has to answer the passphrase request
+var passphraseRequestTimeout = 5 * time.Minute
+
+var passphraseRequests = make(map[string]chan passphraseResponse)
+var passphraseRequestsMutex sync.Mutex
+
+func (l *websocketLogger) PassphraseRequest(keyFile string) (string, error) {
+	requestID := guid.NewString()
+	response := make(chan passphraseResponse, 1)
+	passphraseRequestsMutex.Lock()
+	passphraseRequests[requestID] = response
+	passphraseRequestsMutex.Unlock()
+	defer func() {
+		passphraseRequestsMutex.Lock()
+		delete(passphraseRequests, requestID)
+		passphraseRequestsMutex.Unlock()
+	}()
+
+	l.write(passphraseRequestMessage{
+		Type:      MessageTypePassphraseRequest,
+		RequestID: requestID,
+		KeyFile:   keyFile,
+	})
+	select {
+	case result := <-response:
+		if result.cancelled {
+			return "", fmt.Errorf("Entering passphrase of '%s' was cancelled", keyFile)
+		}
+		return result.passphrase, nil
+	case <-time.After(passphraseRequestTimeout):
+		return "", fmt.Errorf("Passphrase of '%s' wasn't entered in time", keyFile)
+	}
+}
+
+// ResolvePassphraseRequest passes the passphrase entered in the UI to the waiting request,
+// responses to requests already resolved or timed out are dropped
+func ResolvePassphraseRequest(requestID string, passphrase string, cancelled bool) {
+	passphraseRequestsMutex.Lock()
+	response, ok := passphraseRequests[requestID]
+	delete(passphraseRequests, requestID)
+	passphraseRequestsMutex.Unlock()
+	if !ok {
+		return
+	}
+	select {
+	case response <- passphraseResponse{passphrase: passphrase, cancelled: cancelled}:
+	default:
+	}
+}
+
 func (l *websocketLogger) TunnelStart(tunnelID string) {
 	l.write(tunnelStartMessage{
 		Type:     MessageTypeTunnelStart,
@@ -0,0 +1,48 @@
+package filelock
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+const retryInterval = 25 * time.Millisecond
+
+// ErrTimeout is returned when the lock is still held by other process after the timeout
+var ErrTimeout = errors.New("timed out waiting for the lock")
+
+// Lock is an advisory lock of a file shared between loophole processes
+type Lock struct {
+	file *os.File
+}
+
+// Acquire locks the file, creating it if needed, shared for reading and exclusive for updating
+func Acquire(path string, exclusive bool, timeout time.Duration) (*Lock, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err = lockFile(file, exclusive)
+		if err == nil {
+			return &Lock{file: file}, nil
+		}
+		if err != errLocked {
+			file.Close()
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, ErrTimeout
+		}
+		time.Sleep(retryInterval)
+	}
+}
+
+// Release unlocks and closes the file
+func (l *Lock) Release() error {
+	unlockFile(l.file)
+	return l.file.Close()
+}
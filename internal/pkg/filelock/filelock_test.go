@@ -0,0 +1,33 @@
+package filelock
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExclusiveLockWaitsForRelease(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loophole-filelock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "file.lock")
+
+	lock, err := Acquire(path, true, time.Second)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, err := Acquire(path, false, 50*time.Millisecond); err != ErrTimeout {
+		t.Fatalf("Expected '%v' while exclusive lock is held, got: %v", ErrTimeout, err)
+	}
+	lock.Release()
+
+	lock, err = Acquire(path, false, time.Second)
+	if err != nil {
+		t.Fatalf("Expected lock to be acquired after release, got: %v", err)
+	}
+	lock.Release()
+}
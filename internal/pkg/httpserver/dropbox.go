@@ -0,0 +1,202 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/loophole/cli/internal/pkg/communication"
+)
+
+const maxCollisionSuffix = 10000
+
+// defaultMaxUploadSize limits the size of upload request when no other limit is set
+const defaultMaxUploadSize = 1 << 30
+
+// errUploadTooLarge is returned when reading upload request over the size limit
+var errUploadTooLarge = errors.New("Upload is too large")
+
+// windowsReservedNames can't be used as file names on Windows, not even with extension
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// dropboxHandler serves the upload page and stores uploaded files, never exposing the directory content
+type dropboxHandler struct {
+	directory     string
+	maxUploadSize int64
+}
+
+type uploadedFile struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+type uploadResponse struct {
+	Files []uploadedFile `json:"files"`
+}
+
+func (dh *dropboxHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/" && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write([]byte(fmt.Sprintf(dropboxPageTemplate, logoURL)))
+	case r.URL.Path == "/upload" && r.Method == http.MethodPost:
+		dh.upload(w, r)
+	case r.URL.Path == "/upload" || r.URL.Path == "/":
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (dh *dropboxHandler) upload(w http.ResponseWriter, r *http.Request) {
+	maxUploadSize := dh.maxUploadSize
+	if maxUploadSize <= 0 {
+		maxUploadSize = defaultMaxUploadSize
+	}
+	body := &limitedBody{ReadCloser: r.Body, remaining: maxUploadSize}
+	r.Body = body
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "Expected multipart/form-data upload", http.StatusBadRequest)
+		return
+	}
+
+	response := uploadResponse{Files: []uploadedFile{}}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil && body.exceeded {
+			rejectTooLarge(w, maxUploadSize)
+			return
+		} else if err != nil {
+			http.Error(w, "Error reading upload", http.StatusBadRequest)
+			return
+		}
+		if part.FileName() == "" {
+			part.Close()
+			continue
+		}
+
+		saved, err := dh.store(part.FileName(), part)
+		part.Close()
+		if err != nil && body.exceeded {
+			rejectTooLarge(w, maxUploadSize)
+			return
+		} else if err != nil {
+			communication.Warn(fmt.Sprintf("Failed to store uploaded file: %s", err.Error()))
+			http.Error(w, "Error storing uploaded file", http.StatusInternalServerError)
+			return
+		}
+		communication.Info(fmt.Sprintf("Received file '%s' (%s)", saved.Name, humanSize(saved.Size)))
+		response.Files = append(response.Files, *saved)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// rejectTooLarge responds to upload over the limit, closing the connection as the rest of the body isn't read
+func rejectTooLarge(w http.ResponseWriter, maxUploadSize int64) {
+	w.Header().Set("Connection", "close")
+	http.Error(w, fmt.Sprintf("Upload is larger than %s", humanSize(maxUploadSize)), http.StatusRequestEntityTooLarge)
+}
+
+// limitedBody fails reading once more than the remaining bytes are read, the exceeded flag is checked
+// because older Go versions don't wrap the read error in the multipart reader
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	exceeded  bool
+}
+
+func (lb *limitedBody) Read(p []byte) (int, error) {
+	if lb.exceeded {
+		return 0, errUploadTooLarge
+	}
+	if int64(len(p)) > lb.remaining+1 {
+		p = p[:lb.remaining+1]
+	}
+	n, err := lb.ReadCloser.Read(p)
+	if int64(n) <= lb.remaining {
+		lb.remaining -= int64(n)
+		return n, err
+	}
+	n = int(lb.remaining)
+	lb.remaining = 0
+	lb.exceeded = true
+	return n, errUploadTooLarge
+}
+
+// store writes the content under a sanitized name, adding a numeric suffix instead of overwriting existing files
+func (dh *dropboxHandler) store(fileName string, content io.Reader) (*uploadedFile, error) {
+	name := sanitizeFileName(fileName)
+	file, name, err := createUniqueFile(dh.directory, name)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := io.Copy(file, content)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(filepath.Join(dh.directory, name))
+		return nil, err
+	}
+	return &uploadedFile{Name: name, Size: size}, nil
+}
+
+func sanitizeFileName(fileName string) string {
+	// browsers may send full paths, especially older ones on Windows
+	fileName = fileName[strings.LastIndexAny(fileName, `/\`)+1:]
+	fileName = strings.Map(func(r rune) rune {
+		if r < 32 || strings.ContainsRune(`<>:"|?*`, r) {
+			return '_'
+		}
+		return r
+	}, fileName)
+	// Windows drops trailing dots and spaces, so such names could end up overwriting other files
+	fileName = strings.TrimRight(strings.TrimLeft(strings.TrimSpace(fileName), "."), ". ")
+	if fileName == "" {
+		fileName = "upload"
+	}
+	if windowsReservedNames[strings.ToUpper(strings.TrimSpace(strings.SplitN(fileName, ".", 2)[0]))] {
+		fileName = "_" + fileName
+	}
+	return fileName
+}
+
+func createUniqueFile(directory string, name string) (*os.File, string, error) {
+	extension := filepath.Ext(name)
+	base := strings.TrimSuffix(name, extension)
+
+	for i := 0; i < maxCollisionSuffix; i++ {
+		candidate := name
+		if i > 0 {
+			candidate = fmt.Sprintf("%s (%d)%s", base, i, extension)
+		}
+		file, err := os.OpenFile(filepath.Join(directory, candidate), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		return file, candidate, nil
+	}
+	return nil, "", fmt.Errorf("Too many files named '%s'", name)
+}
@@ -0,0 +1,105 @@
+package httpserver
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func uploadRequest(t *testing.T, files map[string]string) *http.Request {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for name, content := range files {
+		part, err := writer.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		part.Write([]byte(content))
+	}
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestDropboxStoresUploadedFilesWithoutOverwriting(t *testing.T) {
+	dir := t.TempDir()
+	handler := &dropboxHandler{directory: dir}
+
+	for i, content := range []string{"first", "second"} {
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, uploadRequest(t, map[string]string{"report.txt": content}))
+		if resp.Code != http.StatusCreated {
+			t.Fatalf("Expected '%d' status for upload %d, got '%d'", http.StatusCreated, i, resp.Code)
+		}
+	}
+
+	for name, expected := range map[string]string{"report.txt": "first", "report (1).txt": "second"} {
+		content, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Expected '%s' to be stored, got: %v", name, err)
+		}
+		if string(content) != expected {
+			t.Fatalf("Expected '%s' to contain '%s', got '%s'", name, expected, content)
+		}
+	}
+}
+
+func TestDropboxNeverServesExistingContent(t *testing.T) {
+	dir := createTestDirectory(t)
+	handler := &dropboxHandler{directory: dir}
+
+	resp := doRequest(t, handler, "/first.txt")
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("Expected '%d' status, got '%d'", http.StatusNotFound, resp.Code)
+	}
+
+	resp = doRequest(t, handler, "/")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected '%d' status, got '%d'", http.StatusOK, resp.Code)
+	}
+	if strings.Contains(resp.Body.String(), "first.txt") {
+		t.Fatalf("Expected upload page not to list directory content")
+	}
+}
+
+func TestDropboxRejectsTooLargeUploads(t *testing.T) {
+	dir := t.TempDir()
+	handler := &dropboxHandler{directory: dir, maxUploadSize: 100}
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, uploadRequest(t, map[string]string{"big.txt": strings.Repeat("a", 200)}))
+	if resp.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected '%d' status, got '%d'", http.StatusRequestEntityTooLarge, resp.Code)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("Expected partially uploaded file to be removed, got %d files", len(files))
+	}
+}
+
+func TestSanitizeFileName(t *testing.T) {
+	cases := map[string]string{
+		"report.txt":             "report.txt",
+		`C:\Users\me\report.txt`: "report.txt",
+		"../../etc/passwd":       "passwd",
+		"..":                     "upload",
+		".bashrc":                "bashrc",
+		"what?.txt":              "what_.txt",
+		"report.txt. . ":         "report.txt",
+		"CON":                    "_CON",
+		"nul.txt":                "_nul.txt",
+		"com1 .log":              "_com1 .log",
+		"console.txt":            "console.txt",
+	}
+	for input, expected := range cases {
+		if got := sanitizeFileName(input); got != expected {
+			t.Fatalf("Expected '%s' to be sanitized to '%s', got '%s'", input, expected, got)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package httpserver
+
+const (
+	// %s is logoUrl
+	dropboxPageTemplate = `<!DOCTYPE html>
+<html lang="en">
+	<head>
+	<meta charset="utf-8" />
+	<meta name="viewport" content="width=device-width, initial-scale=1" />
+	<title>Upload files</title>
+	<style>
+		body {
+			font-family: system-ui, -apple-system, "Segoe UI", Roboto, Ubuntu,
+				Cantarell, "Noto Sans", sans-serif, BlinkMacSystemFont, "Segoe UI",
+				Helvetica, Arial, sans-serif, "Apple Color Emoji", "Segoe UI Emoji",
+				"Segoe UI Symbol";
+			margin: 0 auto;
+			max-width: 800px;
+			padding: 16px;
+			text-align: center;
+		}
+		#dropzone {
+			border: 3px dashed #dadde1;
+			border-radius: 8px;
+			cursor: pointer;
+			margin: 24px 0;
+			padding: 48px 16px;
+		}
+		#dropzone.active {
+			border-color: #2e8555;
+			background: #f1faf5;
+		}
+		ul {
+			list-style: none;
+			padding: 0;
+			text-align: left;
+		}
+		li {
+			border-bottom: 1px solid #dadde1;
+			padding: 8px 0;
+		}
+		progress {
+			width: 100%%;
+		}
+		.error {
+			color: #fa383e;
+		}
+		.done {
+			color: #2e8555;
+		}
+	</style>
+	</head>
+	<body>
+	<img src="%s" width="300px" alt="Loophole" />
+	<h1>Upload files</h1>
+	<div id="dropzone">
+		Drag and drop files here or click to choose them
+		<input id="files" type="file" multiple hidden />
+	</div>
+	<ul id="uploads"></ul>
+	<script>
+		var dropzone = document.getElementById("dropzone");
+		var input = document.getElementById("files");
+		var uploads = document.getElementById("uploads");
+
+		function upload(file) {
+			var item = document.createElement("li");
+			var label = document.createElement("div");
+			var progress = document.createElement("progress");
+			label.textContent = file.name;
+			progress.max = 100;
+			progress.value = 0;
+			item.appendChild(label);
+			item.appendChild(progress);
+			uploads.appendChild(item);
+
+			var data = new FormData();
+			data.append("file", file, file.name);
+			var request = new XMLHttpRequest();
+			request.open("POST", "upload");
+			request.upload.onprogress = function (event) {
+				if (event.lengthComputable) {
+					progress.value = (event.loaded / event.total) * 100;
+				}
+			};
+			request.onload = function () {
+				progress.remove();
+				if (request.status === 201) {
+					label.className = "done";
+					label.textContent = file.name + " - uploaded";
+				} else {
+					label.className = "error";
+					label.textContent = file.name + " - failed (" + request.status + ")";
+				}
+			};
+			request.onerror = function () {
+				progress.remove();
+				label.className = "error";
+				label.textContent = file.name + " - failed";
+			};
+			request.send(data);
+		}
+
+		function uploadAll(files) {
+			for (var i = 0; i < files.length; i++) {
+				upload(files[i]);
+			}
+		}
+
+		dropzone.addEventListener("click", function () {
+			input.click();
+		});
+		input.addEventListener("change", function () {
+			uploadAll(input.files);
+			input.value = "";
+		});
+		dropzone.addEventListener("dragover", function (event) {
+			event.preventDefault();
+			dropzone.className = "active";
+		});
+		dropzone.addEventListener("dragleave", function () {
+			dropzone.className = "";
+		});
+		dropzone.addEventListener("drop", function (event) {
+			event.preventDefault();
+			dropzone.className = "";
+			uploadAll(event.dataTransfer.files);
+		});
+	</script>
+	</body>
+</html>
+`
+)
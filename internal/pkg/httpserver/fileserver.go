@@ -0,0 +1,344 @@
+package httpserver
+
+import (
+	"archive/zip"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/loophole/cli/internal/pkg/communication"
+)
+
+// fileServer serves files the same way http.FileServer does, but renders its own
+// directory listing and allows to download whole directories as zip archives
+type fileServer struct {
+	root           http.FileSystem
+	files          http.Handler
+	spa            bool
+	disableListing bool
+}
+
+// hashedAssetRegexp matches bundler output names like main.3f2a1b9c.chunk.js or index-BX3k9aQz.css,
+// the first group is the hash
+var hashedAssetRegexp = regexp.MustCompile(`[.-]([A-Za-z0-9_]{8,})(\.chunk)?\.[A-Za-z0-9]+$`)
+
+func newFileServer(root http.FileSystem) *fileServer {
+	return &fileServer{
+		root:  root,
+		files: http.FileServer(root),
+	}
+}
+
+type listingEntry struct {
+	Name     string
+	Href     string
+	IsDir    bool
+	Size     int64
+	Modified time.Time
+}
+
+type breadcrumb struct {
+	Name string
+	Href string
+}
+
+type listingPage struct {
+	Path        string
+	Breadcrumbs []breadcrumb
+	Entries     []listingEntry
+	Query       string
+	Sort        string
+	Order       string
+}
+
+var listingTemplate = template.Must(template.New("listing").Funcs(template.FuncMap{
+	"humanSize": humanSize,
+	"sortLink":  sortLink,
+}).Parse(directoryListingTemplate))
+
+func (fsrv *fileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upath := path.Clean("/" + r.URL.Path)
+
+	f, err := fsrv.root.Open(upath)
+	if err != nil {
+		if fsrv.spa && os.IsNotExist(err) && acceptsHTML(r) {
+			fsrv.serveSPAIndex(w, r)
+			return
+		}
+		fsrv.files.ServeHTTP(w, r)
+		return
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil || !stat.IsDir() || !strings.HasSuffix(r.URL.Path, "/") {
+		if err == nil && !stat.IsDir() {
+			fsrv.setCacheHeaders(w, upath)
+		}
+		fsrv.files.ServeHTTP(w, r)
+		return
+	}
+
+	if _, ok := r.URL.Query()["zip"]; ok && !fsrv.spa && !fsrv.disableListing {
+		fsrv.serveZip(w, upath, stat.Name())
+		return
+	}
+
+	if index, err := fsrv.root.Open(path.Join(upath, "index.html")); err == nil {
+		index.Close()
+		fsrv.setCacheHeaders(w, "index.html")
+		fsrv.files.ServeHTTP(w, r)
+		return
+	}
+
+	if fsrv.spa {
+		fsrv.serveSPAIndex(w, r)
+		return
+	}
+	if fsrv.disableListing {
+		http.NotFound(w, r)
+		return
+	}
+
+	fsrv.serveListing(w, r, upath, f)
+}
+
+// serveSPAIndex serves the root index.html, letting the application router handle the path
+func (fsrv *fileServer) serveSPAIndex(w http.ResponseWriter, r *http.Request) {
+	index, err := fsrv.root.Open("/index.html")
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer index.Close()
+
+	stat, err := index.Stat()
+	if err != nil {
+		http.Error(w, "Error reading index.html", http.StatusInternalServerError)
+		return
+	}
+	fsrv.setCacheHeaders(w, "index.html")
+	http.ServeContent(w, r, "index.html", stat.ModTime(), index)
+}
+
+// setCacheHeaders sets long-lived caching for hashed assets and forces revalidation of html in SPA mode
+func (fsrv *fileServer) setCacheHeaders(w http.ResponseWriter, name string) {
+	if !fsrv.spa {
+		return
+	}
+	base := path.Base(name)
+	if strings.HasSuffix(base, ".html") {
+		w.Header().Set("Cache-Control", "no-cache")
+	} else if isHashedAsset(base) {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	}
+}
+
+// isHashedAsset checks whether the name contains hash with a digit which isn't just a number at the end
+// of a word, so that names like screenshot-homepage1.png or jquery-3.6.0.validate.js are not cached forever
+func isHashedAsset(name string) bool {
+	match := hashedAssetRegexp.FindStringSubmatch(name)
+	if match == nil {
+		return false
+	}
+	return strings.ContainsAny(strings.TrimRight(match[1], "0123456789"), "0123456789")
+}
+
+// acceptsHTML checks whether the request comes from browser navigation rather than loading an asset
+func acceptsHTML(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+func (fsrv *fileServer) serveListing(w http.ResponseWriter, r *http.Request, upath string, dir http.File) {
+	infos, err := dir.Readdir(-1)
+	if err != nil {
+		http.Error(w, "Error reading directory", http.StatusInternalServerError)
+		return
+	}
+
+	query := r.URL.Query()
+	page := listingPage{
+		Path:        upath,
+		Breadcrumbs: breadcrumbs(upath),
+		Query:       query.Get("q"),
+		Sort:        query.Get("sort"),
+		Order:       query.Get("order"),
+	}
+	if page.Sort == "" {
+		page.Sort = "name"
+	}
+	if page.Order != "desc" {
+		page.Order = "asc"
+	}
+
+	needle := strings.ToLower(page.Query)
+	for _, info := range infos {
+		if needle != "" && !strings.Contains(strings.ToLower(info.Name()), needle) {
+			continue
+		}
+		entry := listingEntry{
+			Name:     info.Name(),
+			Href:     escapeSegment(info.Name()),
+			IsDir:    info.IsDir(),
+			Size:     info.Size(),
+			Modified: info.ModTime(),
+		}
+		if entry.IsDir {
+			entry.Href += "/"
+		}
+		page.Entries = append(page.Entries, entry)
+	}
+	sortEntries(page.Entries, page.Sort, page.Order == "desc")
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = listingTemplate.Execute(w, page)
+	if err != nil {
+		http.Error(w, "Error rendering directory listing", http.StatusInternalServerError)
+	}
+}
+
+func (fsrv *fileServer) serveZip(w http.ResponseWriter, upath string, name string) {
+	if upath == "/" {
+		name = "archive"
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".zip"))
+
+	archive := zip.NewWriter(w)
+	if err := fsrv.addToZip(archive, upath, ""); err != nil {
+		// Headers are already sent at this point, so the transfer is broken off instead of finishing
+		// the archive, otherwise the client would get valid zip missing some files
+		communication.Warn(fmt.Sprintf("There was a problem creating zip of '%s': %v", upath, err))
+		panic(http.ErrAbortHandler)
+	}
+	archive.Close()
+}
+
+func (fsrv *fileServer) addToZip(archive *zip.Writer, dirPath string, prefix string) error {
+	dir, err := fsrv.root.Open(dirPath)
+	if err != nil {
+		return err
+	}
+	infos, err := dir.Readdir(-1)
+	dir.Close()
+	if err != nil {
+		return err
+	}
+
+	for _, info := range infos {
+		entryPath := path.Join(dirPath, info.Name())
+		entryName := prefix + info.Name()
+		if info.IsDir() {
+			_, err = archive.Create(entryName + "/")
+			if err != nil {
+				return err
+			}
+			err = fsrv.addToZip(archive, entryPath, entryName+"/")
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = entryName
+		header.Method = zip.Deflate
+		writer, err := archive.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		file, err := fsrv.root.Open(entryPath)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(writer, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortEntries(entries []listingEntry, sortBy string, descending bool) {
+	less := func(a, b listingEntry) bool {
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	}
+	switch sortBy {
+	case "size":
+		less = func(a, b listingEntry) bool { return a.Size < b.Size }
+	case "modified":
+		less = func(a, b listingEntry) bool { return a.Modified.Before(b.Modified) }
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		// directories always go first
+		if entries[i].IsDir != entries[j].IsDir {
+			return entries[i].IsDir
+		}
+		if descending {
+			return less(entries[j], entries[i])
+		}
+		return less(entries[i], entries[j])
+	})
+}
+
+func breadcrumbs(upath string) []breadcrumb {
+	result := []breadcrumb{{Name: "Home", Href: "/"}}
+	href := "/"
+	for _, segment := range strings.Split(strings.Trim(upath, "/"), "/") {
+		if segment == "" {
+			continue
+		}
+		href += url.PathEscape(segment) + "/"
+		result = append(result, breadcrumb{Name: segment, Href: href})
+	}
+	return result
+}
+
+func sortLink(page listingPage, sortBy string) string {
+	order := "asc"
+	if page.Sort == sortBy && page.Order == "asc" {
+		order = "desc"
+	}
+	values := url.Values{}
+	values.Set("sort", sortBy)
+	values.Set("order", order)
+	if page.Query != "" {
+		values.Set("q", page.Query)
+	}
+	return "?" + values.Encode()
+}
+
+func humanSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// escapeSegment escapes a single path segment so it can be safely used as a link
+func escapeSegment(segment string) string {
+	u := url.URL{Path: segment}
+	return u.String()
+}
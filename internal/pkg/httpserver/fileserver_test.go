@@ -0,0 +1,270 @@
+package httpserver
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileServerListsDirectoryWithSizes(t *testing.T) {
+	dir := createTestDirectory(t)
+
+	resp := doRequest(t, newFileServer(http.Dir(dir)), "/")
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected '%d' status, got '%d'", http.StatusOK, resp.Code)
+	}
+	body := resp.Body.String()
+	for _, expected := range []string{"first.txt", "second.log", "nested/", "5 B"} {
+		if !strings.Contains(body, expected) {
+			t.Fatalf("Expected listing to contain '%s', got: %s", expected, body)
+		}
+	}
+}
+
+func TestFileServerFiltersListingByName(t *testing.T) {
+	dir := createTestDirectory(t)
+
+	resp := doRequest(t, newFileServer(http.Dir(dir)), "/?q=FIRST")
+
+	body := resp.Body.String()
+	if !strings.Contains(body, "first.txt") {
+		t.Fatalf("Expected listing to contain 'first.txt', got: %s", body)
+	}
+	if strings.Contains(body, "second.log") {
+		t.Fatalf("Expected listing not to contain 'second.log', got: %s", body)
+	}
+}
+
+func TestFileServerSortsListingDescending(t *testing.T) {
+	dir := createTestDirectory(t)
+
+	resp := doRequest(t, newFileServer(http.Dir(dir)), "/?sort=name&order=desc")
+
+	body := resp.Body.String()
+	if strings.Index(body, "second.log") > strings.Index(body, "first.txt") {
+		t.Fatalf("Expected 'second.log' to be listed before 'first.txt'")
+	}
+}
+
+func TestFileServerServesFiles(t *testing.T) {
+	dir := createTestDirectory(t)
+
+	resp := doRequest(t, newFileServer(http.Dir(dir)), "/first.txt")
+
+	if resp.Body.String() != "hello" {
+		t.Fatalf("Expected file content 'hello', got '%s'", resp.Body.String())
+	}
+}
+
+func TestFileServerStreamsDirectoryAsZip(t *testing.T) {
+	dir := createTestDirectory(t)
+
+	resp := doRequest(t, newFileServer(http.Dir(dir)), "/?zip")
+
+	if resp.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("Expected zip content type, got '%s'", resp.Header().Get("Content-Type"))
+	}
+	archive, err := zip.NewReader(bytes.NewReader(resp.Body.Bytes()), int64(resp.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{}
+	for _, file := range archive.File {
+		names[file.Name] = true
+	}
+	for _, expected := range []string{"first.txt", "second.log", "nested/", "nested/third.txt"} {
+		if !names[expected] {
+			t.Fatalf("Expected archive to contain '%s', got %v", expected, names)
+		}
+	}
+}
+
+// vanishingFileSystem lists the file but fails to open it, as if it was removed in the meantime
+type vanishingFileSystem struct {
+	http.FileSystem
+	vanished string
+}
+
+func (vfs vanishingFileSystem) Open(name string) (http.File, error) {
+	if name == vfs.vanished {
+		return nil, os.ErrNotExist
+	}
+	return vfs.FileSystem.Open(name)
+}
+
+func TestFileServerAbortsZipWhenFileDisappears(t *testing.T) {
+	dir := createTestDirectory(t)
+	srv := httptest.NewServer(newFileServer(vanishingFileSystem{http.Dir(dir), "/second.log"}))
+	defer srv.Close()
+
+	// small archive may still be buffered, then even the headers are not sent
+	resp, err := http.Get(srv.URL + "/?zip")
+	if err == nil {
+		defer resp.Body.Close()
+		_, err = ioutil.ReadAll(resp.Body)
+	}
+	if err == nil {
+		t.Fatalf("Expected incomplete zip download to be broken off")
+	}
+}
+
+func TestFileServerStreamsDirectoryWithIndexAsZip(t *testing.T) {
+	dir := createTestDirectory(t)
+	writeTestFile(t, dir, "nested/index.html", "<html></html>")
+
+	resp := doRequest(t, newFileServer(http.Dir(dir)), "/nested/?zip")
+
+	if resp.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("Expected zip content type, got '%s'", resp.Header().Get("Content-Type"))
+	}
+	if resp := doRequest(t, newFileServer(http.Dir(dir)), "/nested/"); resp.Body.String() != "<html></html>" {
+		t.Fatalf("Expected index.html to be served without zip query, got '%s'", resp.Body.String())
+	}
+}
+
+func TestFileServerSPAModeFallsBackToIndex(t *testing.T) {
+	dir := createTestDirectory(t)
+	writeTestFile(t, dir, "index.html", "<div id=root></div>")
+	fs := newFileServer(http.Dir(dir))
+	fs.spa = true
+
+	req := httptest.NewRequest(http.MethodGet, "/users/john.doe", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+	resp := httptest.NewRecorder()
+	fs.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected '%d' status, got '%d'", http.StatusOK, resp.Code)
+	}
+	if resp.Body.String() != "<div id=root></div>" {
+		t.Fatalf("Expected index.html content, got '%s'", resp.Body.String())
+	}
+	if resp.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatalf("Expected index.html not to be cached, got '%s'", resp.Header().Get("Cache-Control"))
+	}
+}
+
+func TestFileServerSPAModeReturns404ForMissingAssets(t *testing.T) {
+	dir := createTestDirectory(t)
+	writeTestFile(t, dir, "index.html", "<div id=root></div>")
+	fs := newFileServer(http.Dir(dir))
+	fs.spa = true
+
+	resp := doRequest(t, fs, "/static/missing.js")
+
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("Expected '%d' status, got '%d'", http.StatusNotFound, resp.Code)
+	}
+}
+
+func TestFileServerSPAModeCachesHashedAssets(t *testing.T) {
+	dir := createTestDirectory(t)
+	writeTestFile(t, dir, "index.html", "<div id=root></div>")
+	writeTestFile(t, dir, "static/main.3f2a1b9c.chunk.js", "console.log(1)")
+	fs := newFileServer(http.Dir(dir))
+	fs.spa = true
+
+	resp := doRequest(t, fs, "/static/main.3f2a1b9c.chunk.js")
+
+	if !strings.Contains(resp.Header().Get("Cache-Control"), "immutable") {
+		t.Fatalf("Expected hashed asset to be cached, got '%s'", resp.Header().Get("Cache-Control"))
+	}
+	for name, expected := range map[string]bool{
+		"index-BX3k9aQz.css":       true,
+		"screenshot-homepage1.png": false,
+		"jquery-3.6.0.validate.js": false,
+		"logo.png":                 false,
+	} {
+		if isHashedAsset(name) != expected {
+			t.Fatalf("Expected '%s' to be hashed asset: %t", name, expected)
+		}
+	}
+}
+
+func TestFileServerDisabledListingReturns404(t *testing.T) {
+	dir := createTestDirectory(t)
+	fs := newFileServer(http.Dir(dir))
+	fs.disableListing = true
+
+	resp := doRequest(t, fs, "/nested/")
+
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("Expected '%d' status, got '%d'", http.StatusNotFound, resp.Code)
+	}
+}
+
+func createTestDirectory(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "loophole-fileserver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	files := map[string]string{
+		"first.txt":        "hello",
+		"second.log":       "some longer content",
+		"nested/third.txt": "nested",
+	}
+	for name, content := range files {
+		writeTestFile(t, dir, name, content)
+	}
+	return dir
+}
+
+func writeTestFile(t *testing.T, dir string, name string, content string) {
+	filePath := filepath.Join(dir, filepath.FromSlash(name))
+	err := os.MkdirAll(filepath.Dir(filePath), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filePath, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func doRequest(t *testing.T, handler http.Handler, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestStaticServerExposesArchiveContent(t *testing.T) {
+	dir := createTestDirectory(t)
+	archiveName := filepath.Join(dir, "bundle.zip")
+	file, err := os.Create(archiveName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := zip.NewWriter(file)
+	entry, _ := writer.Create("logs/app.log")
+	entry.Write([]byte("0123456789"))
+	writer.Close()
+	file.Close()
+
+	server, err := New().ServeStatic().FromArchive(archiveName).Build()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	resp := doRequest(t, server.Handler, "/logs/")
+	if !strings.Contains(resp.Body.String(), "app.log") {
+		t.Fatalf("Expected listing to contain 'app.log', got: %s", resp.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/logs/app.log", nil)
+	req.Header.Set("Range", "bytes=2-5")
+	rangeResp := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rangeResp, req)
+	if rangeResp.Code != http.StatusPartialContent || rangeResp.Body.String() != "2345" {
+		t.Fatalf("Expected partial content '2345', got '%d' '%s'", rangeResp.Code, rangeResp.Body.String())
+	}
+}
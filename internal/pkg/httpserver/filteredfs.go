@@ -0,0 +1,178 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path"
+
+	"github.com/loophole/cli/internal/pkg/ignore"
+	"golang.org/x/net/webdav"
+)
+
+// filteredFileSystem hides paths matched by ignore rules from the static file server
+type filteredFileSystem struct {
+	fs      http.FileSystem
+	matcher *ignore.Matcher
+}
+
+type filteredFile struct {
+	http.File
+	name    string
+	matcher *ignore.Matcher
+}
+
+func (ffs *filteredFileSystem) Open(name string) (http.File, error) {
+	file, err := ffs.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if ffs.matcher.Ignored(name, stat.IsDir()) {
+		file.Close()
+		return nil, os.ErrNotExist
+	}
+	return &filteredFile{File: file, name: name, matcher: ffs.matcher}, nil
+}
+
+func (ff *filteredFile) Readdir(count int) ([]os.FileInfo, error) {
+	infos, err := ff.File.Readdir(count)
+	return filterFileInfos(ff.name, infos, ff.matcher), err
+}
+
+// filteredWebdavFileSystem hides paths matched by ignore rules from the WebDav server,
+// making them unavailable for both reads and writes
+type filteredWebdavFileSystem struct {
+	fs      webdav.FileSystem
+	matcher *ignore.Matcher
+}
+
+type filteredWebdavFile struct {
+	webdav.File
+	name    string
+	matcher *ignore.Matcher
+}
+
+func (fwfs *filteredWebdavFileSystem) ignored(ctx context.Context, name string) bool {
+	stat, err := fwfs.fs.Stat(ctx, name)
+	isDir := err == nil && stat.IsDir()
+	return fwfs.matcher.Ignored(name, isDir)
+}
+
+func (fwfs *filteredWebdavFileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	if fwfs.matcher.Ignored(name, true) {
+		return os.ErrPermission
+	}
+	return fwfs.fs.Mkdir(ctx, name, perm)
+}
+
+func (fwfs *filteredWebdavFileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if fwfs.ignored(ctx, name) {
+		if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE) != 0 {
+			return nil, os.ErrPermission
+		}
+		return nil, os.ErrNotExist
+	}
+	file, err := fwfs.fs.OpenFile(ctx, name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &filteredWebdavFile{File: file, name: name, matcher: fwfs.matcher}, nil
+}
+
+// containsIgnored returns whether the directory holds hidden entries, which would be removed or moved together with it.
+// When the directory is to be moved, the paths its entries would get under the new name are checked too
+func (fwfs *filteredWebdavFileSystem) containsIgnored(ctx context.Context, name string, newName string) (bool, error) {
+	stat, err := fwfs.fs.Stat(ctx, name)
+	if err != nil || !stat.IsDir() {
+		return false, err
+	}
+	dir, err := fwfs.fs.OpenFile(ctx, name, os.O_RDONLY, 0)
+	if err != nil {
+		return false, err
+	}
+	infos, err := dir.Readdir(-1)
+	dir.Close()
+	if err != nil {
+		return false, err
+	}
+	for _, info := range infos {
+		child := path.Join(name, info.Name())
+		newChild := ""
+		if newName != "" {
+			newChild = path.Join(newName, info.Name())
+		}
+		if fwfs.matcher.Ignored(child, info.IsDir()) || (newChild != "" && fwfs.matcher.Ignored(newChild, info.IsDir())) {
+			return true, nil
+		}
+		if info.IsDir() {
+			ignored, err := fwfs.containsIgnored(ctx, child, newChild)
+			if err != nil || ignored {
+				return ignored, err
+			}
+		}
+	}
+	return false, nil
+}
+
+func (fwfs *filteredWebdavFileSystem) RemoveAll(ctx context.Context, name string) error {
+	if fwfs.ignored(ctx, name) {
+		return os.ErrNotExist
+	}
+	ignored, err := fwfs.containsIgnored(ctx, name, "")
+	if err != nil {
+		return err
+	}
+	if ignored {
+		return os.ErrPermission
+	}
+	return fwfs.fs.RemoveAll(ctx, name)
+}
+
+func (fwfs *filteredWebdavFileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	if fwfs.ignored(ctx, oldName) {
+		return os.ErrNotExist
+	}
+	if fwfs.ignored(ctx, newName) {
+		return os.ErrPermission
+	}
+	ignored, err := fwfs.containsIgnored(ctx, oldName, newName)
+	if err != nil {
+		return err
+	}
+	if ignored {
+		return os.ErrPermission
+	}
+	return fwfs.fs.Rename(ctx, oldName, newName)
+}
+
+func (fwfs *filteredWebdavFileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	stat, err := fwfs.fs.Stat(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if fwfs.matcher.Ignored(name, stat.IsDir()) {
+		return nil, os.ErrNotExist
+	}
+	return stat, nil
+}
+
+func (fwf *filteredWebdavFile) Readdir(count int) ([]os.FileInfo, error) {
+	infos, err := fwf.File.Readdir(count)
+	return filterFileInfos(fwf.name, infos, fwf.matcher), err
+}
+
+func filterFileInfos(dir string, infos []os.FileInfo, matcher *ignore.Matcher) []os.FileInfo {
+	result := make([]os.FileInfo, 0, len(infos))
+	for _, info := range infos {
+		if matcher.Ignored(dir+"/"+info.Name(), info.IsDir()) {
+			continue
+		}
+		result = append(result, info)
+	}
+	return result
+}
@@ -0,0 +1,116 @@
+package httpserver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/loophole/cli/internal/pkg/ignore"
+	"golang.org/x/net/webdav"
+)
+
+func TestFilteredFileSystemHidesIgnoredPathsFromStaticServer(t *testing.T) {
+	dir := createTestDirectory(t)
+	writeTestFile(t, dir, ".env", "SECRET=1")
+	writeTestFile(t, dir, "node_modules/lib/index.js", "module.exports = {}")
+	matcher := ignore.New(false)
+	matcher.Add("node_modules/")
+	handler := newFileServer(&filteredFileSystem{fs: http.Dir(dir), matcher: matcher})
+
+	listing := doRequest(t, handler, "/").Body.String()
+	for _, hidden := range []string{".env", "node_modules"} {
+		if strings.Contains(listing, hidden) {
+			t.Fatalf("Expected listing not to contain '%s', got: %s", hidden, listing)
+		}
+	}
+	for _, target := range []string{"/.env", "/node_modules/lib/index.js"} {
+		if resp := doRequest(t, handler, target); resp.Code != http.StatusNotFound {
+			t.Fatalf("Expected '%d' status for '%s', got '%d'", http.StatusNotFound, target, resp.Code)
+		}
+	}
+	if resp := doRequest(t, handler, "/first.txt"); resp.Code != http.StatusOK {
+		t.Fatalf("Expected '%d' status, got '%d'", http.StatusOK, resp.Code)
+	}
+}
+
+func TestFilteredWebdavFileSystemHidesIgnoredPaths(t *testing.T) {
+	dir := createTestDirectory(t)
+	writeTestFile(t, dir, ".git/config", "[core]")
+	handler := &webdav.Handler{
+		FileSystem: &filteredWebdavFileSystem{fs: webdav.Dir(dir), matcher: ignore.New(false)},
+		LockSystem: webdav.NewMemLS(),
+	}
+
+	req := httptest.NewRequest("PROPFIND", "/", nil)
+	req.Header.Set("Depth", "1")
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	if strings.Contains(resp.Body.String(), ".git") {
+		t.Fatalf("Expected PROPFIND response not to contain '.git', got: %s", resp.Body.String())
+	}
+	if !strings.Contains(resp.Body.String(), "first.txt") {
+		t.Fatalf("Expected PROPFIND response to contain 'first.txt', got: %s", resp.Body.String())
+	}
+
+	resp = doRequest(t, handler, "/.git/config")
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("Expected '%d' status, got '%d'", http.StatusNotFound, resp.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/.env", strings.NewReader("SECRET=1"))
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	if resp.Code < 400 {
+		t.Fatalf("Expected PUT of ignored path to fail, got '%d'", resp.Code)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".env")); !os.IsNotExist(err) {
+		t.Fatalf("Expected ignored file not to be written")
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/uploaded.txt", strings.NewReader("content"))
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	content, err := ioutil.ReadFile(filepath.Join(dir, "uploaded.txt"))
+	if err != nil || string(content) != "content" {
+		t.Fatalf("Expected regular file to be written, got '%d' status", resp.Code)
+	}
+}
+
+func TestFilteredWebdavFileSystemProtectsIgnoredEntriesOfDirectories(t *testing.T) {
+	dir := createTestDirectory(t)
+	writeTestFile(t, dir, "project/.env", "SECRET=1")
+	writeTestFile(t, dir, "project/main.go", "package main")
+	writeTestFile(t, dir, "public/index.html", "<html></html>")
+	handler := &webdav.Handler{
+		FileSystem: &filteredWebdavFileSystem{fs: webdav.Dir(dir), matcher: ignore.New(false)},
+		LockSystem: webdav.NewMemLS(),
+	}
+
+	req := httptest.NewRequest("MOVE", "/project", nil)
+	req.Header.Set("Destination", "/moved")
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	if resp.Code < 400 {
+		t.Fatalf("Expected MOVE of directory with ignored entries to fail, got '%d'", resp.Code)
+	}
+	req = httptest.NewRequest(http.MethodDelete, "/project", nil)
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	if resp.Code < 400 {
+		t.Fatalf("Expected DELETE of directory with ignored entries to fail, got '%d'", resp.Code)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "project", ".env")); err != nil {
+		t.Fatalf("Expected ignored file to be kept, got: %v", err)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/public", nil)
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	if _, err := os.Stat(filepath.Join(dir, "public")); !os.IsNotExist(err) {
+		t.Fatalf("Expected directory without ignored entries to be removed, got '%d' status", resp.Code)
+	}
+}
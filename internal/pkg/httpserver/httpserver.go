@@ -3,13 +3,19 @@ package httpserver
 import (
 	"crypto/tls"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"os"
 
 	auth "github.com/abbot/go-http-auth"
 	lm "github.com/loophole/cli/internal/app/loophole/models"
+	"github.com/loophole/cli/internal/pkg/archivefs"
+	"github.com/loophole/cli/internal/pkg/ignore"
+	"github.com/loophole/cli/internal/pkg/maintenance"
+	"github.com/loophole/cli/internal/pkg/sharelink"
 	"github.com/loophole/cli/internal/pkg/urlmaker"
 	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/net/webdav"
@@ -23,15 +29,19 @@ type ServerBuilder interface {
 	WithSiteID(string) ServerBuilder
 	WithDomain(string) ServerBuilder
 	DisableOldCiphers(bool) ServerBuilder
+	WithMaintenance(*maintenance.Switch) ServerBuilder
 	Proxy() ProxyServerBuilder
 	ServeStatic() StaticServerBuilder
 	ServeWebdav() WebdavServerBuilder
+	ServeDropbox() DropboxServerBuilder
+	ServeFile() FileServerBuilder
 }
 
 type serverBuilder struct {
 	siteID            string
 	domain            string
 	disableOldCiphers bool
+	maintenance       *maintenance.Switch
 }
 
 func (sb *serverBuilder) WithSiteID(siteID string) ServerBuilder {
@@ -48,6 +58,23 @@ func (sb *serverBuilder) DisableOldCiphers(setting bool) ServerBuilder {
 	return sb
 }
 
+func (sb *serverBuilder) WithMaintenance(sw *maintenance.Switch) ServerBuilder {
+	sb.maintenance = sw
+	return sb
+}
+
+// build creates the server for the given handler, wrapping it with the common features
+func (sb *serverBuilder) build(handler http.Handler) *http.Server {
+	if sb.maintenance != nil {
+		handler = sb.maintenance.Handler(handler)
+	}
+
+	return &http.Server{
+		Handler:   handler,
+		TLSConfig: getTLSConfig(sb.siteID, sb.domain, sb.disableOldCiphers),
+	}
+}
+
 func (sb *serverBuilder) Proxy() ProxyServerBuilder {
 	return &proxyServerBuilder{
 		serverBuilder: sb,
@@ -64,6 +91,16 @@ func (sb *serverBuilder) ServeWebdav() WebdavServerBuilder {
 		serverBuilder: sb,
 	}
 }
+func (sb *serverBuilder) ServeDropbox() DropboxServerBuilder {
+	return &dropboxServerBuilder{
+		serverBuilder: sb,
+	}
+}
+func (sb *serverBuilder) ServeFile() FileServerBuilder {
+	return &fileServerBuilder{
+		serverBuilder: sb,
+	}
+}
 
 // ProxyServerBuilder is used to proxy to already running server
 type ProxyServerBuilder interface {
@@ -140,40 +177,42 @@ func (psb *proxyServerBuilder) Build() (*http.Server, error) {
 		}
 	}
 
-	var server *http.Server
-
 	if psb.basicAuthEnabled {
 		proxyWithAuth, err := getBasicAuthHandler(psb.serverBuilder.siteID, psb.serverBuilder.domain, psb.basicAuthUsername, psb.basicAuthPassword, proxy.ServeHTTP)
 		if err != nil {
 			return nil, err
 		}
 
-		server = &http.Server{
-			Handler:   proxyWithAuth,
-			TLSConfig: getTLSConfig(psb.serverBuilder.siteID, psb.serverBuilder.domain, psb.serverBuilder.disableOldCiphers),
-		}
-	} else {
-		server = &http.Server{
-			Handler:   proxy,
-			TLSConfig: getTLSConfig(psb.serverBuilder.siteID, psb.serverBuilder.domain, psb.serverBuilder.disableOldCiphers),
-		}
+		return psb.serverBuilder.build(proxyWithAuth), nil
 	}
 
-	return server, nil
+	return psb.serverBuilder.build(proxy), nil
 }
 
 // StaticServerBuilder is used to create server which expose local directory
 type StaticServerBuilder interface {
 	FromDirectory(string) StaticServerBuilder
+	FromArchive(string) StaticServerBuilder
 	WithBasicAuth(string, string) StaticServerBuilder
+	EnableSPAMode() StaticServerBuilder
+	DisableDirectoryListing() StaticServerBuilder
+	Excluding([]string) StaticServerBuilder
+	ShowDotfiles() StaticServerBuilder
+	WithShareLinks(*sharelink.Tunnel) StaticServerBuilder
 	Build() (*http.Server, error)
 }
 type staticServerBuilder struct {
-	serverBuilder     *serverBuilder
-	directory         string
-	basicAuthEnabled  bool
-	basicAuthUsername string
-	basicAuthPassword string
+	serverBuilder           *serverBuilder
+	directory               string
+	archive                 string
+	basicAuthEnabled        bool
+	basicAuthUsername       string
+	basicAuthPassword       string
+	spa                     bool
+	disableDirectoryListing bool
+	excludes                []string
+	showDotfiles            bool
+	shareLinkTunnel         *sharelink.Tunnel
 }
 
 func (ssb *staticServerBuilder) FromDirectory(directory string) StaticServerBuilder {
@@ -181,6 +220,11 @@ func (ssb *staticServerBuilder) FromDirectory(directory string) StaticServerBuil
 	return ssb
 }
 
+func (ssb *staticServerBuilder) FromArchive(archive string) StaticServerBuilder {
+	ssb.archive = archive
+	return ssb
+}
+
 func (ssb *staticServerBuilder) WithBasicAuth(username string, password string) StaticServerBuilder {
 	ssb.basicAuthEnabled = true
 	ssb.basicAuthUsername = username
@@ -188,35 +232,91 @@ func (ssb *staticServerBuilder) WithBasicAuth(username string, password string)
 	return ssb
 }
 
-func (ssb *staticServerBuilder) Build() (*http.Server, error) {
-	fs := http.FileServer(http.Dir(ssb.directory))
+func (ssb *staticServerBuilder) EnableSPAMode() StaticServerBuilder {
+	ssb.spa = true
+	return ssb
+}
+
+func (ssb *staticServerBuilder) DisableDirectoryListing() StaticServerBuilder {
+	ssb.disableDirectoryListing = true
+	return ssb
+}
+
+func (ssb *staticServerBuilder) Excluding(patterns []string) StaticServerBuilder {
+	ssb.excludes = patterns
+	return ssb
+}
 
-	var server *http.Server
+func (ssb *staticServerBuilder) ShowDotfiles() StaticServerBuilder {
+	ssb.showDotfiles = true
+	return ssb
+}
+
+func (ssb *staticServerBuilder) WithShareLinks(tunnel *sharelink.Tunnel) StaticServerBuilder {
+	ssb.shareLinkTunnel = tunnel
+	return ssb
+}
 
+func (ssb *staticServerBuilder) Build() (*http.Server, error) {
+	root, matcher, closer, err := ssb.root()
+	if err != nil {
+		return nil, err
+	}
+	fs := newFileServer(&filteredFileSystem{fs: root, matcher: matcher})
+	fs.spa = ssb.spa
+	fs.disableListing = ssb.disableDirectoryListing
+
+	var handler http.HandlerFunc = fs.ServeHTTP
 	if ssb.basicAuthEnabled {
-		handler, err := getBasicAuthHandler(ssb.serverBuilder.siteID, ssb.serverBuilder.domain, ssb.basicAuthUsername, ssb.basicAuthPassword, fs.ServeHTTP)
+		handler, err = getBasicAuthHandler(ssb.serverBuilder.siteID, ssb.serverBuilder.domain, ssb.basicAuthUsername, ssb.basicAuthPassword, fs.ServeHTTP)
 		if err != nil {
 			return nil, err
 		}
+	}
+	if ssb.shareLinkTunnel != nil {
+		handler = getShareLinkHandler(sharelink.NewVerifier(ssb.shareLinkTunnel), fs.ServeHTTP, handler)
+	}
 
-		server = &http.Server{
-			Handler:   handler,
-			TLSConfig: getTLSConfig(ssb.serverBuilder.siteID, ssb.serverBuilder.domain, ssb.serverBuilder.disableOldCiphers),
-		}
-	} else {
-		server = &http.Server{
-			Handler:   fs,
-			TLSConfig: getTLSConfig(ssb.serverBuilder.siteID, ssb.serverBuilder.domain, ssb.serverBuilder.disableOldCiphers),
+	server := ssb.serverBuilder.build(handler)
+	if closer != nil {
+		server.RegisterOnShutdown(func() { closer.Close() })
+	}
+	return server, nil
+}
+
+// root returns the exposed filesystem, archives are exposed read-only with their content and have to be closed
+func (ssb *staticServerBuilder) root() (http.FileSystem, *ignore.Matcher, io.Closer, error) {
+	if ssb.archive == "" {
+		matcher, err := ignore.Load(ssb.directory, ssb.excludes, ssb.showDotfiles)
+		if err != nil {
+			return nil, nil, nil, err
 		}
+		return http.Dir(ssb.directory), matcher, nil, nil
 	}
 
-	return server, nil
+	matcher := ignore.New(ssb.showDotfiles)
+	for _, pattern := range ssb.excludes {
+		if err := matcher.Add(pattern); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	archive, err := archivefs.Open(ssb.archive)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return http.FS(archive), matcher, archive, nil
 }
 
 // WebdavServerBuilder is used to create server which expose local directory
 type WebdavServerBuilder interface {
 	FromDirectory(string) WebdavServerBuilder
 	WithBasicAuth(string, string) WebdavServerBuilder
+	Excluding([]string) WebdavServerBuilder
+	ShowDotfiles() WebdavServerBuilder
+	WithUser(string, string, Permission) WebdavServerBuilder
+	ReadOnly() WebdavServerBuilder
+	WithQuota(int64) WebdavServerBuilder
+	WithMaxFileSize(int64) WebdavServerBuilder
 	Build() (*http.Server, error)
 }
 type webdavServerBuilder struct {
@@ -225,6 +325,12 @@ type webdavServerBuilder struct {
 	basicAuthEnabled  bool
 	basicAuthUsername string
 	basicAuthPassword string
+	excludes          []string
+	showDotfiles      bool
+	users             []webdavUser
+	readOnly          bool
+	quota             int64
+	maxFileSize       int64
 }
 
 func (wsb *webdavServerBuilder) FromDirectory(directory string) WebdavServerBuilder {
@@ -239,33 +345,219 @@ func (wsb *webdavServerBuilder) WithBasicAuth(username string, password string)
 	return wsb
 }
 
+func (wsb *webdavServerBuilder) Excluding(patterns []string) WebdavServerBuilder {
+	wsb.excludes = patterns
+	return wsb
+}
+
+func (wsb *webdavServerBuilder) ShowDotfiles() WebdavServerBuilder {
+	wsb.showDotfiles = true
+	return wsb
+}
+
+func (wsb *webdavServerBuilder) WithUser(username string, password string, permission Permission) WebdavServerBuilder {
+	wsb.users = append(wsb.users, webdavUser{
+		username:   username,
+		password:   password,
+		permission: permission,
+	})
+	return wsb
+}
+
+func (wsb *webdavServerBuilder) ReadOnly() WebdavServerBuilder {
+	wsb.readOnly = true
+	return wsb
+}
+
+func (wsb *webdavServerBuilder) WithQuota(bytes int64) WebdavServerBuilder {
+	wsb.quota = bytes
+	return wsb
+}
+
+func (wsb *webdavServerBuilder) WithMaxFileSize(bytes int64) WebdavServerBuilder {
+	wsb.maxFileSize = bytes
+	return wsb
+}
+
 func (wsb *webdavServerBuilder) Build() (*http.Server, error) {
+	matcher, err := ignore.Load(wsb.directory, wsb.excludes, wsb.showDotfiles)
+	if err != nil {
+		return nil, err
+	}
+	var fileSystem webdav.FileSystem = &filteredWebdavFileSystem{fs: webdav.Dir(wsb.directory), matcher: matcher}
+
+	var quotaFS *quotaFileSystem
+	if wsb.quota > 0 || wsb.maxFileSize > 0 {
+		quotaFS, err = newQuotaFileSystem(fileSystem, wsb.directory, wsb.quota, wsb.maxFileSize)
+		if err != nil {
+			return nil, err
+		}
+		fileSystem = quotaFS
+	}
+
+	lockSystem, err := newFileLockSystem(getLockFile(wsb.directory))
+	if err != nil {
+		return nil, err
+	}
+
 	wdHandler := &webdav.Handler{
 		Prefix:     "/",
-		FileSystem: webdav.Dir(wsb.directory),
-		LockSystem: webdav.NewMemLS(),
+		FileSystem: fileSystem,
+		LockSystem: lockSystem,
+	}
+	davHandler := wdHandler.ServeHTTP
+	if quotaFS != nil {
+		davHandler = getQuotaHandler(quotaFS, davHandler)
 	}
 
-	var server *http.Server
-
+	users := wsb.users
 	if wsb.basicAuthEnabled {
-		handler, err := getBasicAuthHandler(wsb.serverBuilder.siteID, wsb.serverBuilder.domain, wsb.basicAuthUsername, wsb.basicAuthPassword, wdHandler.ServeHTTP)
+		users = append(users, webdavUser{
+			username:   wsb.basicAuthUsername,
+			password:   wsb.basicAuthPassword,
+			permission: PermissionWrite,
+		})
+	}
+
+	handler, err := getWebdavAccessHandler(wsb.serverBuilder.siteID, wsb.serverBuilder.domain, users, wsb.readOnly, davHandler)
+	if err != nil {
+		return nil, err
+	}
+
+	return wsb.serverBuilder.build(handler), nil
+}
+
+// DropboxServerBuilder is used to create server which accepts uploads into local directory
+type DropboxServerBuilder interface {
+	FromDirectory(string) DropboxServerBuilder
+	WithBasicAuth(string, string) DropboxServerBuilder
+	WithMaxUploadSize(int64) DropboxServerBuilder
+	Build() (*http.Server, error)
+}
+type dropboxServerBuilder struct {
+	serverBuilder     *serverBuilder
+	directory         string
+	maxUploadSize     int64
+	basicAuthEnabled  bool
+	basicAuthUsername string
+	basicAuthPassword string
+}
+
+func (dsb *dropboxServerBuilder) FromDirectory(directory string) DropboxServerBuilder {
+	dsb.directory = directory
+	return dsb
+}
+
+func (dsb *dropboxServerBuilder) WithBasicAuth(username string, password string) DropboxServerBuilder {
+	dsb.basicAuthEnabled = true
+	dsb.basicAuthUsername = username
+	dsb.basicAuthPassword = password
+	return dsb
+}
+
+func (dsb *dropboxServerBuilder) WithMaxUploadSize(maxUploadSize int64) DropboxServerBuilder {
+	dsb.maxUploadSize = maxUploadSize
+	return dsb
+}
+
+func (dsb *dropboxServerBuilder) Build() (*http.Server, error) {
+	db := &dropboxHandler{directory: dsb.directory, maxUploadSize: dsb.maxUploadSize}
+
+	if dsb.basicAuthEnabled {
+		handler, err := getBasicAuthHandler(dsb.serverBuilder.siteID, dsb.serverBuilder.domain, dsb.basicAuthUsername, dsb.basicAuthPassword, db.ServeHTTP)
 		if err != nil {
 			return nil, err
 		}
 
-		server = &http.Server{
-			Handler:   handler,
-			TLSConfig: getTLSConfig(wsb.serverBuilder.siteID, wsb.serverBuilder.domain, wsb.serverBuilder.disableOldCiphers),
-		}
-	} else {
-		server = &http.Server{
-			Handler:   wdHandler,
-			TLSConfig: getTLSConfig(wsb.serverBuilder.siteID, wsb.serverBuilder.domain, wsb.serverBuilder.disableOldCiphers),
+		return dsb.serverBuilder.build(handler), nil
+	}
+
+	return dsb.serverBuilder.build(db), nil
+}
+
+// FileServerBuilder is used to create server which exposes single local file
+type FileServerBuilder interface {
+	FromFile(string) FileServerBuilder
+	WithBasicAuth(string, string) FileServerBuilder
+	WithMaxDownloads(int) FileServerBuilder
+	WithExpiry(*Deadline) FileServerBuilder
+	OnProgress(func(DownloadProgress)) FileServerBuilder
+	OnLimitReached(func()) FileServerBuilder
+	Build() (*http.Server, error)
+}
+type fileServerBuilder struct {
+	serverBuilder     *serverBuilder
+	file              string
+	basicAuthEnabled  bool
+	basicAuthUsername string
+	basicAuthPassword string
+	maxDownloads      int
+	expiry            *Deadline
+	onProgress        func(DownloadProgress)
+	onLimitReached    func()
+}
+
+func (fsb *fileServerBuilder) FromFile(file string) FileServerBuilder {
+	fsb.file = file
+	return fsb
+}
+
+func (fsb *fileServerBuilder) WithBasicAuth(username string, password string) FileServerBuilder {
+	fsb.basicAuthEnabled = true
+	fsb.basicAuthUsername = username
+	fsb.basicAuthPassword = password
+	return fsb
+}
+
+func (fsb *fileServerBuilder) WithMaxDownloads(maxDownloads int) FileServerBuilder {
+	fsb.maxDownloads = maxDownloads
+	return fsb
+}
+
+func (fsb *fileServerBuilder) WithExpiry(expiry *Deadline) FileServerBuilder {
+	fsb.expiry = expiry
+	return fsb
+}
+
+func (fsb *fileServerBuilder) OnProgress(callback func(DownloadProgress)) FileServerBuilder {
+	fsb.onProgress = callback
+	return fsb
+}
+
+func (fsb *fileServerBuilder) OnLimitReached(callback func()) FileServerBuilder {
+	fsb.onLimitReached = callback
+	return fsb
+}
+
+func (fsb *fileServerBuilder) Build() (*http.Server, error) {
+	stat, err := os.Stat(fsb.file)
+	if err != nil {
+		return nil, err
+	}
+	if stat.IsDir() {
+		return nil, fmt.Errorf("'%s' is a directory, use 'loophole path' to expose directories", fsb.file)
+	}
+
+	sfh := newSingleFileHandler(fsb.file)
+	sfh.maxDownloads = fsb.maxDownloads
+	sfh.expiry = fsb.expiry
+	if fsb.onProgress != nil {
+		sfh.onProgress = fsb.onProgress
+	}
+	if fsb.onLimitReached != nil {
+		sfh.onLimitReached = fsb.onLimitReached
+	}
+
+	if fsb.basicAuthEnabled {
+		handler, err := getBasicAuthHandler(fsb.serverBuilder.siteID, fsb.serverBuilder.domain, fsb.basicAuthUsername, fsb.basicAuthPassword, sfh.ServeHTTP)
+		if err != nil {
+			return nil, err
 		}
+
+		return fsb.serverBuilder.build(handler), nil
 	}
 
-	return server, nil
+	return fsb.serverBuilder.build(sfh), nil
 }
 
 // New starts creation of new server
@@ -294,6 +586,22 @@ func getBasicAuthSecretParser(username string, hashedPassword string) auth.Secre
 	}
 }
 
+// getShareLinkHandler serves signed links without other authentication, rejecting invalid ones,
+// requests without signature are passed to the regular handler
+func getShareLinkHandler(verifier *sharelink.Verifier, signed http.HandlerFunc, unsigned http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !sharelink.IsSigned(r) {
+			unsigned(w, r)
+			return
+		}
+		if err := verifier.Verify(r); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		signed(w, r)
+	}
+}
+
 func proxyErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
 	w.Write([]byte(fmt.Sprintf(proxyErrorTemplate, logoURL, err.Error())))
 }
@@ -0,0 +1,98 @@
+package httpserver
+
+const (
+	// rendered with html/template, data is listingPage
+	directoryListingTemplate = `<!DOCTYPE html>
+<html lang="en">
+	<head>
+	<meta charset="utf-8" />
+	<meta name="viewport" content="width=device-width, initial-scale=1" />
+	<title>Index of {{.Path}}</title>
+	<style>
+		body {
+			font-family: system-ui, -apple-system, "Segoe UI", Roboto, Ubuntu,
+				Cantarell, "Noto Sans", sans-serif, BlinkMacSystemFont, "Segoe UI",
+				Helvetica, Arial, sans-serif, "Apple Color Emoji", "Segoe UI Emoji",
+				"Segoe UI Symbol";
+			margin: 0 auto;
+			max-width: 960px;
+			padding: 16px;
+		}
+		nav a {
+			color: #2e8555;
+		}
+		form {
+			display: flex;
+			gap: 8px;
+			margin: 16px 0;
+		}
+		form input {
+			flex-grow: 1;
+		}
+		table {
+			border-collapse: collapse;
+			width: 100%;
+		}
+		th,
+		td {
+			border-bottom: 1px solid #dadde1;
+			padding: 6px 8px;
+			text-align: left;
+		}
+		th a {
+			color: inherit;
+		}
+		td.size,
+		th.size {
+			text-align: right;
+			white-space: nowrap;
+		}
+		td.modified {
+			white-space: nowrap;
+		}
+	</style>
+	</head>
+	<body>
+	<nav>
+		{{range $i, $crumb := .Breadcrumbs}}{{if $i}} / {{end}}<a href="{{$crumb.Href}}">{{$crumb.Name}}</a>{{end}}
+	</nav>
+	<form method="get">
+		<input type="search" name="q" value="{{.Query}}" placeholder="Filter by name" />
+		<input type="hidden" name="sort" value="{{.Sort}}" />
+		<input type="hidden" name="order" value="{{.Order}}" />
+		<button type="submit">Search</button>
+		<a href="?zip" download>Download as zip</a>
+	</form>
+	<table>
+		<thead>
+		<tr>
+			<th><a href="{{sortLink . "name"}}">Name</a></th>
+			<th class="size"><a href="{{sortLink . "size"}}">Size</a></th>
+			<th><a href="{{sortLink . "modified"}}">Modified</a></th>
+		</tr>
+		</thead>
+		<tbody>
+		{{if ne .Path "/"}}
+		<tr>
+			<td><a href="../">../</a></td>
+			<td class="size"></td>
+			<td></td>
+		</tr>
+		{{end}}
+		{{range .Entries}}
+		<tr>
+			<td><a href="{{.Href}}">{{.Name}}{{if .IsDir}}/{{end}}</a></td>
+			<td class="size">{{if not .IsDir}}{{humanSize .Size}}{{end}}</td>
+			<td class="modified">{{.Modified.Format "2006-01-02 15:04:05"}}</td>
+		</tr>
+		{{else}}
+		<tr>
+			<td colspan="3">Nothing to show</td>
+		</tr>
+		{{end}}
+		</tbody>
+	</table>
+	</body>
+</html>
+`
+)
@@ -0,0 +1,336 @@
+package httpserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beevik/guid"
+	"github.com/loophole/cli/internal/pkg/cache"
+	"github.com/loophole/cli/internal/pkg/filelock"
+	"golang.org/x/net/webdav"
+)
+
+// lockStoreTimeout is how long to wait for other process serving the same directory to update the locks
+var lockStoreTimeout = 10 * time.Second
+
+type storedLock struct {
+	Root      string        `json:"root"`
+	Duration  time.Duration `json:"duration"`
+	OwnerXML  string        `json:"ownerXml"`
+	ZeroDepth bool          `json:"zeroDepth"`
+	Expiry    time.Time     `json:"expiry"`
+
+	held bool
+}
+
+// fileLockSystem is a webdav.LockSystem keeping the locks in a file, so clients
+// like LibreOffice or Word keep their locks when the tunnel gets restarted
+//
+// Locks without timeout are kept in memory only, as the webdav handler uses them
+// as temporary locks for the time of a single request.
+type fileLockSystem struct {
+	file  string
+	mutex sync.Mutex
+	locks map[string]*storedLock
+}
+
+// getLockFile returns the location of the lock file for the given shared directory
+func getLockFile(directory string) string {
+	absolutePath, err := filepath.Abs(directory)
+	if err != nil {
+		absolutePath = directory
+	}
+	hash := sha256.Sum256([]byte(absolutePath))
+	return cache.GetLocalStorageFile(fmt.Sprintf("%s.json", hex.EncodeToString(hash[:8])), "webdav-locks")
+}
+
+func newFileLockSystem(file string) (*fileLockSystem, error) {
+	ls := &fileLockSystem{
+		file:  file,
+		locks: make(map[string]*storedLock),
+	}
+	err := ls.update(time.Now(), func() (bool, error) {
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ls, nil
+}
+
+// update runs the operation on locks loaded from the file, holding the file lock so that processes
+// serving the same directory don't overwrite each other's locks, the locks are saved if the operation changed them
+func (ls *fileLockSystem) update(now time.Time, operation func() (bool, error)) error {
+	fileLock, err := filelock.Acquire(ls.file+".lock", true, lockStoreTimeout)
+	if err != nil {
+		return fmt.Errorf("There was a problem locking WebDav locks: %v", err)
+	}
+	defer fileLock.Release()
+
+	err = ls.load()
+	if err != nil {
+		return err
+	}
+	if ls.collectExpired(now) {
+		err = ls.save()
+		if err != nil {
+			return err
+		}
+	}
+	changed, err := operation()
+	if err != nil {
+		return err
+	}
+	if changed {
+		return ls.save()
+	}
+	return nil
+}
+
+// load replaces persistent locks with the ones stored in the file, keeping temporary locks
+// and the state of locks held by this process
+func (ls *fileLockSystem) load() error {
+	stored := make(map[string]*storedLock)
+	content, err := ioutil.ReadFile(ls.file)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("There was a problem reading WebDav locks: %v", err)
+	} else if err == nil {
+		err = json.Unmarshal(content, &stored)
+		if err != nil {
+			return fmt.Errorf("There was a problem decoding WebDav locks: %v", err)
+		}
+	}
+
+	for token, lock := range ls.locks {
+		if lock.Duration < 0 {
+			continue
+		}
+		if storedLock, ok := stored[token]; ok {
+			storedLock.held = lock.held
+			*lock = *storedLock
+			delete(stored, token)
+		} else {
+			delete(ls.locks, token)
+		}
+	}
+	for token, lock := range stored {
+		ls.locks[token] = lock
+	}
+	return nil
+}
+
+func (ls *fileLockSystem) Confirm(now time.Time, name0, name1 string, conditions ...webdav.Condition) (func(), error) {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+
+	var lock0, lock1 *storedLock
+	err := ls.update(now, func() (bool, error) {
+		if name0 != "" {
+			if lock0 = ls.lookup(slashClean(name0), conditions...); lock0 == nil {
+				return false, webdav.ErrConfirmationFailed
+			}
+		}
+		if name1 != "" {
+			if lock1 = ls.lookup(slashClean(name1), conditions...); lock1 == nil {
+				return false, webdav.ErrConfirmationFailed
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Don't hold the same lock twice
+	if lock1 == lock0 {
+		lock1 = nil
+	}
+
+	if lock0 != nil {
+		lock0.held = true
+	}
+	if lock1 != nil {
+		lock1.held = true
+	}
+	return func() {
+		ls.mutex.Lock()
+		defer ls.mutex.Unlock()
+		if lock1 != nil {
+			lock1.held = false
+		}
+		if lock0 != nil {
+			lock0.held = false
+		}
+	}, nil
+}
+
+func (ls *fileLockSystem) Create(now time.Time, details webdav.LockDetails) (string, error) {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+	details.Root = slashClean(details.Root)
+
+	token := fmt.Sprintf("opaquelocktoken:%s", guid.NewString())
+	err := ls.update(now, func() (bool, error) {
+		if !ls.canCreate(details.Root, details.ZeroDepth) {
+			return false, webdav.ErrLocked
+		}
+		lock := &storedLock{
+			Root:      details.Root,
+			Duration:  details.Duration,
+			OwnerXML:  details.OwnerXML,
+			ZeroDepth: details.ZeroDepth,
+		}
+		if details.Duration >= 0 {
+			lock.Expiry = now.Add(details.Duration)
+		}
+		ls.locks[token] = lock
+		return details.Duration >= 0, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (ls *fileLockSystem) Refresh(now time.Time, token string, duration time.Duration) (webdav.LockDetails, error) {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+
+	var details webdav.LockDetails
+	err := ls.update(now, func() (bool, error) {
+		lock, ok := ls.locks[token]
+		if !ok {
+			return false, webdav.ErrNoSuchLock
+		}
+		if lock.held {
+			return false, webdav.ErrLocked
+		}
+		lock.Duration = duration
+		if duration >= 0 {
+			lock.Expiry = now.Add(duration)
+		}
+		details = lock.details()
+		return true, nil
+	})
+	return details, err
+}
+
+func (ls *fileLockSystem) Unlock(now time.Time, token string) error {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+
+	return ls.update(now, func() (bool, error) {
+		lock, ok := ls.locks[token]
+		if !ok {
+			return false, webdav.ErrNoSuchLock
+		}
+		if lock.held {
+			return false, webdav.ErrLocked
+		}
+		delete(ls.locks, token)
+		return lock.Duration >= 0, nil
+	})
+}
+
+// lookup returns the lock matching one of the conditions and covering the named resource,
+// as long as it isn't held by another request
+func (ls *fileLockSystem) lookup(name string, conditions ...webdav.Condition) *storedLock {
+	for _, condition := range conditions {
+		lock := ls.locks[condition.Token]
+		if lock == nil || lock.held {
+			continue
+		}
+		if lock.covers(name) {
+			return lock
+		}
+	}
+	return nil
+}
+
+func (ls *fileLockSystem) canCreate(name string, zeroDepth bool) bool {
+	for _, lock := range ls.locks {
+		if lock.covers(name) {
+			return false
+		}
+		if !zeroDepth && isAncestor(name, lock.Root) {
+			// The requested lock depth is infinite and a descendant is already locked
+			return false
+		}
+	}
+	return true
+}
+
+// collectExpired removes expired locks, returning whether there were any
+func (ls *fileLockSystem) collectExpired(now time.Time) bool {
+	changed := false
+	for token, lock := range ls.locks {
+		if lock.Duration >= 0 && !lock.held && !lock.Expiry.After(now) {
+			delete(ls.locks, token)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// save writes the locks to a temporary file and moves it in place, so the file is never left half written
+func (ls *fileLockSystem) save() error {
+	persistent := make(map[string]*storedLock)
+	for token, lock := range ls.locks {
+		if lock.Duration >= 0 {
+			persistent[token] = lock
+		}
+	}
+	content, err := json.Marshal(persistent)
+	if err != nil {
+		return fmt.Errorf("There was a problem encoding WebDav locks: %v", err)
+	}
+
+	tempFile := ls.file + ".tmp"
+	err = ioutil.WriteFile(tempFile, content, 0600)
+	if err != nil {
+		return fmt.Errorf("There was a problem writing WebDav locks: %v", err)
+	}
+	err = os.Rename(tempFile, ls.file)
+	if err != nil {
+		return fmt.Errorf("There was a problem writing WebDav locks: %v", err)
+	}
+	return nil
+}
+
+func (lock *storedLock) covers(name string) bool {
+	if lock.Root == name {
+		return true
+	}
+	return !lock.ZeroDepth && isAncestor(lock.Root, name)
+}
+
+func (lock *storedLock) details() webdav.LockDetails {
+	return webdav.LockDetails{
+		Root:      lock.Root,
+		Duration:  lock.Duration,
+		OwnerXML:  lock.OwnerXML,
+		ZeroDepth: lock.ZeroDepth,
+	}
+}
+
+func isAncestor(parent string, child string) bool {
+	if parent == child {
+		return false
+	}
+	return parent == "/" || strings.HasPrefix(child, parent+"/")
+}
+
+func slashClean(name string) string {
+	if name == "" || name[0] != '/' {
+		name = "/" + name
+	}
+	return path.Clean(name)
+}
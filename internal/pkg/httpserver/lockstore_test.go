@@ -0,0 +1,146 @@
+package httpserver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/net/webdav"
+)
+
+func TestFileLockSystemSurvivesRestart(t *testing.T) {
+	lockFile := createLockFilePath(t)
+	now := time.Now()
+
+	ls, err := newFileLockSystem(lockFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := ls.Create(now, webdav.LockDetails{Root: "/document.odt", Duration: time.Hour, ZeroDepth: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restarted, err := newFileLockSystem(lockFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release, err := restarted.Confirm(now, "/document.odt", "", webdav.Condition{Token: token})
+	if err != nil {
+		t.Fatalf("Expected lock to be confirmed after restart, got: %v", err)
+	}
+	release()
+
+	if _, err := restarted.Create(now, webdav.LockDetails{Root: "/document.odt", Duration: time.Hour, ZeroDepth: true}); err != webdav.ErrLocked {
+		t.Fatalf("Expected '%v' when locking already locked resource, got: %v", webdav.ErrLocked, err)
+	}
+}
+
+func TestFileLockSystemExpiresLocks(t *testing.T) {
+	ls, err := newFileLockSystem(createLockFilePath(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	token, err := ls.Create(now, webdav.LockDetails{Root: "/document.odt", Duration: time.Minute, ZeroDepth: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	later := now.Add(2 * time.Minute)
+	if _, err := ls.Refresh(later, token, time.Minute); err != webdav.ErrNoSuchLock {
+		t.Fatalf("Expected '%v' for expired lock, got: %v", webdav.ErrNoSuchLock, err)
+	}
+	if _, err := ls.Create(later, webdav.LockDetails{Root: "/document.odt", Duration: time.Minute, ZeroDepth: true}); err != nil {
+		t.Fatalf("Expected resource to be lockable after expiry, got: %v", err)
+	}
+}
+
+func TestFileLockSystemRespectsDepth(t *testing.T) {
+	ls, err := newFileLockSystem(createLockFilePath(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	_, err = ls.Create(now, webdav.LockDetails{Root: "/dir", Duration: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ls.Create(now, webdav.LockDetails{Root: "/dir/file.txt", Duration: time.Hour, ZeroDepth: true}); err != webdav.ErrLocked {
+		t.Fatalf("Expected descendant of infinite depth lock to be locked, got: %v", err)
+	}
+	if _, err := ls.Create(now, webdav.LockDetails{Root: "/", Duration: time.Hour}); err != webdav.ErrLocked {
+		t.Fatalf("Expected ancestor with locked descendant not to be lockable with infinite depth, got: %v", err)
+	}
+	if _, err := ls.Create(now, webdav.LockDetails{Root: "/other.txt", Duration: time.Hour, ZeroDepth: true}); err != nil {
+		t.Fatalf("Expected unrelated resource to be lockable, got: %v", err)
+	}
+}
+
+func TestFileLockSystemDoesNotPersistTemporaryLocks(t *testing.T) {
+	lockFile := createLockFilePath(t)
+	ls, err := newFileLockSystem(lockFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	_, err = ls.Create(now, webdav.LockDetails{Root: "/file.txt", Duration: -1, ZeroDepth: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restarted, err := newFileLockSystem(lockFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restarted.Create(now, webdav.LockDetails{Root: "/file.txt", Duration: time.Hour, ZeroDepth: true}); err != nil {
+		t.Fatalf("Expected temporary lock not to survive restart, got: %v", err)
+	}
+}
+
+func TestFileLockSystemIsSharedBetweenProcesses(t *testing.T) {
+	lockFile := createLockFilePath(t)
+	first, err := newFileLockSystem(lockFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := newFileLockSystem(lockFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	firstToken, err := first.Create(now, webdav.LockDetails{Root: "/first.odt", Duration: time.Hour, ZeroDepth: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.Create(now, webdav.LockDetails{Root: "/second.odt", Duration: time.Hour, ZeroDepth: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.Create(now, webdav.LockDetails{Root: "/first.odt", Duration: time.Hour, ZeroDepth: true}); err != webdav.ErrLocked {
+		t.Fatalf("Expected '%v' for resource locked by other process, got: %v", webdav.ErrLocked, err)
+	}
+
+	if err := second.Unlock(now, firstToken); err != nil {
+		t.Fatalf("Expected lock created by other process to be unlocked, got: %v", err)
+	}
+	restarted, err := newFileLockSystem(lockFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restarted.locks) != 1 {
+		t.Fatalf("Expected only the lock of the second process to be kept, got: %v", restarted.locks)
+	}
+}
+
+func createLockFilePath(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "loophole-locks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "locks.json")
+}
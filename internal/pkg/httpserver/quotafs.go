@@ -0,0 +1,376 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"golang.org/x/net/webdav"
+)
+
+var (
+	errQuotaExceeded = errors.New("quota of the shared directory exceeded")
+	errFileTooLarge  = errors.New("maximum file size exceeded")
+)
+
+var (
+	quotaAvailableBytesProperty = xml.Name{Space: "DAV:", Local: "quota-available-bytes"}
+	quotaUsedBytesProperty      = xml.Name{Space: "DAV:", Local: "quota-used-bytes"}
+)
+
+type quotaViolationKey struct{}
+
+// quotaViolation is passed with request context so the filesystem can report
+// which limit was hit, webdav.Handler itself turns all write errors into 405
+type quotaViolation struct {
+	mutex  sync.Mutex
+	status int
+}
+
+func (qv *quotaViolation) report(err error) {
+	qv.mutex.Lock()
+	defer qv.mutex.Unlock()
+	switch err {
+	case errQuotaExceeded:
+		qv.status = http.StatusInsufficientStorage
+	case errFileTooLarge:
+		qv.status = http.StatusRequestEntityTooLarge
+	}
+}
+
+func (qv *quotaViolation) get() int {
+	qv.mutex.Lock()
+	defer qv.mutex.Unlock()
+	return qv.status
+}
+
+// quotaFileSystem limits total size of the shared directory and size of single files
+type quotaFileSystem struct {
+	fs          webdav.FileSystem
+	root        string
+	quota       int64
+	maxFileSize int64
+
+	mutex sync.Mutex
+	used  int64
+	files map[string]*fileUsage
+}
+
+// fileUsage is the space accounted to the file, shared by all its open handles
+type fileUsage struct {
+	size   int64
+	opened int
+	// failed tells whether write of a handle which created the file hit the limits
+	failed bool
+}
+
+type quotaFile struct {
+	webdav.File
+	qfs       *quotaFileSystem
+	name      string
+	usage     *fileUsage
+	violation *quotaViolation
+	// created tells whether the file was created or truncated when opened, so that it can be removed on failed write
+	created bool
+}
+
+func newQuotaFileSystem(fs webdav.FileSystem, root string, quota int64, maxFileSize int64) (*quotaFileSystem, error) {
+	used, err := directorySize(root)
+	if err != nil {
+		return nil, err
+	}
+	return &quotaFileSystem{
+		fs:          fs,
+		root:        root,
+		quota:       quota,
+		maxFileSize: maxFileSize,
+		used:        used,
+		files:       map[string]*fileUsage{},
+	}, nil
+}
+
+// localPath resolves webdav name the same way webdav.Dir does
+func (qfs *quotaFileSystem) localPath(name string) string {
+	return filepath.Join(qfs.root, filepath.FromSlash(path.Clean("/"+name)))
+}
+
+func (qfs *quotaFileSystem) usage() (used int64, available int64) {
+	qfs.mutex.Lock()
+	defer qfs.mutex.Unlock()
+	available = qfs.quota - qfs.used
+	if available < 0 {
+		available = 0
+	}
+	return qfs.used, available
+}
+
+// grow reserves space for the growth of a file, failing if the quota would be exceeded
+func (qfs *quotaFileSystem) grow(delta int64) error {
+	qfs.mutex.Lock()
+	defer qfs.mutex.Unlock()
+	return qfs.growLocked(delta)
+}
+
+// growLocked is grow which must be called with the mutex held
+func (qfs *quotaFileSystem) growLocked(delta int64) error {
+	if delta > 0 && qfs.quota > 0 && qfs.used+delta > qfs.quota {
+		return errQuotaExceeded
+	}
+	qfs.used += delta
+	if qfs.used < 0 {
+		qfs.used = 0
+	}
+	return nil
+}
+
+func (qfs *quotaFileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	return qfs.fs.Mkdir(ctx, name, perm)
+}
+
+func (qfs *quotaFileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	localPath := qfs.localPath(name)
+	stat, statErr := os.Stat(localPath)
+
+	file, err := qfs.fs.OpenFile(ctx, name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	qfs.mutex.Lock()
+	usage, ok := qfs.files[localPath]
+	if !ok {
+		usage = &fileUsage{}
+		if statErr == nil && stat.Mode().IsRegular() {
+			usage.size = stat.Size()
+		}
+		qfs.files[localPath] = usage
+	}
+	usage.opened++
+	truncated := flag&os.O_TRUNC != 0
+	if truncated && usage.size > 0 {
+		qfs.growLocked(-usage.size)
+		usage.size = 0
+	}
+	qfs.mutex.Unlock()
+
+	violation, _ := ctx.Value(quotaViolationKey{}).(*quotaViolation)
+	return &quotaFile{
+		File:      file,
+		qfs:       qfs,
+		name:      name,
+		usage:     usage,
+		violation: violation,
+		created:   truncated || os.IsNotExist(statErr),
+	}, nil
+}
+
+// reserve accounts the file to span up to end, all handles of the file are accounted at once
+// so concurrent writers don't reserve the same space twice
+func (qfs *quotaFileSystem) reserve(usage *fileUsage, end int64) error {
+	qfs.mutex.Lock()
+	defer qfs.mutex.Unlock()
+	if end <= usage.size {
+		return nil
+	}
+	err := qfs.growLocked(end - usage.size)
+	if err != nil {
+		return err
+	}
+	usage.size = end
+	return nil
+}
+
+// release stops accounting the handle, the last handle closed removes the file partially written
+// by a handle which created it
+func (qfs *quotaFileSystem) release(qf *quotaFile) error {
+	localPath := qfs.localPath(qf.name)
+	qfs.mutex.Lock()
+	defer qfs.mutex.Unlock()
+
+	qf.usage.opened--
+	if qf.usage.opened > 0 {
+		return nil
+	}
+	delete(qfs.files, localPath)
+	if !qf.usage.failed {
+		return nil
+	}
+	err := os.Remove(localPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	qfs.growLocked(-qf.usage.size)
+	return nil
+}
+
+func (qfs *quotaFileSystem) RemoveAll(ctx context.Context, name string) error {
+	size, err := directorySize(qfs.localPath(name))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	err = qfs.fs.RemoveAll(ctx, name)
+	if err != nil {
+		return err
+	}
+	return qfs.grow(-size)
+}
+
+func (qfs *quotaFileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	return qfs.fs.Rename(ctx, oldName, newName)
+}
+
+func (qfs *quotaFileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	return qfs.fs.Stat(ctx, name)
+}
+
+func (qf *quotaFile) Write(p []byte) (int, error) {
+	position, err := qf.File.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	end := position + int64(len(p))
+	if qf.qfs.maxFileSize > 0 && end > qf.qfs.maxFileSize {
+		return 0, qf.fail(errFileTooLarge)
+	}
+	err = qf.qfs.reserve(qf.usage, end)
+	if err != nil {
+		return 0, qf.fail(err)
+	}
+	return qf.File.Write(p)
+}
+
+// Close removes partially written file which hit the limits
+func (qf *quotaFile) Close() error {
+	err := qf.File.Close()
+	releaseErr := qf.qfs.release(qf)
+	if err != nil {
+		return err
+	}
+	return releaseErr
+}
+
+func (qf *quotaFile) fail(err error) error {
+	if qf.created {
+		qf.qfs.mutex.Lock()
+		qf.usage.failed = true
+		qf.qfs.mutex.Unlock()
+	}
+	if qf.violation != nil {
+		qf.violation.report(err)
+	}
+	return err
+}
+
+// DeadProps exposes quota usage as RFC 4331 properties
+func (qf *quotaFile) DeadProps() (map[xml.Name]webdav.Property, error) {
+	props := make(map[xml.Name]webdav.Property)
+	if holder, ok := qf.File.(webdav.DeadPropsHolder); ok {
+		deadProps, err := holder.DeadProps()
+		if err != nil {
+			return nil, err
+		}
+		for name, prop := range deadProps {
+			props[name] = prop
+		}
+	}
+	if qf.qfs.quota <= 0 {
+		return props, nil
+	}
+
+	used, available := qf.qfs.usage()
+	props[quotaAvailableBytesProperty] = webdav.Property{
+		XMLName:  quotaAvailableBytesProperty,
+		InnerXML: []byte(strconv.FormatInt(available, 10)),
+	}
+	props[quotaUsedBytesProperty] = webdav.Property{
+		XMLName:  quotaUsedBytesProperty,
+		InnerXML: []byte(strconv.FormatInt(used, 10)),
+	}
+	return props, nil
+}
+
+// Patch forwards property changes to the underlying file if it supports them
+func (qf *quotaFile) Patch(patches []webdav.Proppatch) ([]webdav.Propstat, error) {
+	if holder, ok := qf.File.(webdav.DeadPropsHolder); ok {
+		return holder.Patch(patches)
+	}
+	propstat := webdav.Propstat{Status: http.StatusForbidden}
+	for _, patch := range patches {
+		for _, prop := range patch.Props {
+			propstat.Props = append(propstat.Props, webdav.Property{XMLName: prop.XMLName})
+		}
+	}
+	return []webdav.Propstat{propstat}, nil
+}
+
+// getQuotaHandler rejects uploads which are known upfront to exceed the limits and replaces
+// the generic error status with 507 or 413 when the limit is hit while writing
+func getQuotaHandler(qfs *quotaFileSystem, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut && r.ContentLength > 0 {
+			if qfs.maxFileSize > 0 && r.ContentLength > qfs.maxFileSize {
+				http.Error(w, errFileTooLarge.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
+			if qfs.quota > 0 {
+				var existingSize int64
+				if stat, err := os.Stat(qfs.localPath(r.URL.Path)); err == nil {
+					existingSize = stat.Size()
+				}
+				if _, available := qfs.usage(); r.ContentLength-existingSize > available {
+					http.Error(w, errQuotaExceeded.Error(), http.StatusInsufficientStorage)
+					return
+				}
+			}
+		}
+
+		violation := &quotaViolation{}
+		ctx := context.WithValue(r.Context(), quotaViolationKey{}, violation)
+		handler(&quotaResponseWriter{ResponseWriter: w, violation: violation}, r.WithContext(ctx))
+	}
+}
+
+type quotaResponseWriter struct {
+	http.ResponseWriter
+	violation  *quotaViolation
+	overridden bool
+}
+
+func (qrw *quotaResponseWriter) WriteHeader(statusCode int) {
+	if status := qrw.violation.get(); status != 0 && statusCode >= 400 {
+		qrw.overridden = true
+		statusCode = status
+	}
+	qrw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (qrw *quotaResponseWriter) Write(p []byte) (int, error) {
+	if qrw.overridden {
+		// replace generic error description written by webdav.Handler
+		qrw.overridden = false
+		_, err := qrw.ResponseWriter.Write([]byte(http.StatusText(qrw.violation.get())))
+		return len(p), err
+	}
+	return qrw.ResponseWriter.Write(p)
+}
+
+func directorySize(root string) (int64, error) {
+	var size int64
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,144 @@
+package httpserver
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/webdav"
+)
+
+func TestQuotaFileSystemRejectsTooLargeFiles(t *testing.T) {
+	handler := createQuotaHandler(t, 0, 10)
+
+	resp := doPut(handler, "/big.txt", strings.Repeat("a", 11), true)
+	if resp.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected '%d' status, got '%d'", http.StatusRequestEntityTooLarge, resp.Code)
+	}
+
+	// without Content-Length the limit can only be detected while writing
+	resp = doPut(handler, "/big.txt", strings.Repeat("a", 11), false)
+	if resp.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected '%d' status for chunked upload, got '%d'", http.StatusRequestEntityTooLarge, resp.Code)
+	}
+
+	resp = doPut(handler, "/small.txt", strings.Repeat("a", 10), true)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("Expected '%d' status, got '%d'", http.StatusCreated, resp.Code)
+	}
+}
+
+func TestQuotaFileSystemRejectsUploadsOverQuota(t *testing.T) {
+	// test directory already holds 30 bytes
+	handler := createQuotaHandler(t, 40, 0)
+
+	resp := doPut(handler, "/new.txt", strings.Repeat("a", 11), false)
+	if resp.Code != http.StatusInsufficientStorage {
+		t.Fatalf("Expected '%d' status, got '%d'", http.StatusInsufficientStorage, resp.Code)
+	}
+
+	resp = doPut(handler, "/new.txt", strings.Repeat("a", 10), true)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("Expected '%d' status, got '%d'", http.StatusCreated, resp.Code)
+	}
+
+	// replacing a file reuses its space
+	resp = doPut(handler, "/new.txt", strings.Repeat("b", 10), true)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("Expected '%d' status when overwriting, got '%d'", http.StatusCreated, resp.Code)
+	}
+}
+
+func TestQuotaFileSystemRemovesPartialUploads(t *testing.T) {
+	dir := createTestDirectory(t)
+	qfs, err := newQuotaFileSystem(webdav.Dir(dir), dir, 40, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := getQuotaHandler(qfs, (&webdav.Handler{FileSystem: qfs, LockSystem: webdav.NewMemLS()}).ServeHTTP)
+
+	resp := doPut(handler, "/new.txt", strings.Repeat("a", 11), false)
+	if resp.Code != http.StatusInsufficientStorage {
+		t.Fatalf("Expected '%d' status, got '%d'", http.StatusInsufficientStorage, resp.Code)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.txt")); !os.IsNotExist(err) {
+		t.Fatalf("Expected partially written file to be removed, got: %v", err)
+	}
+	if used, _ := qfs.usage(); used != 30 {
+		t.Fatalf("Expected 30 used bytes, got %d", used)
+	}
+}
+
+func TestQuotaFileSystemAccountsConcurrentWritersOnce(t *testing.T) {
+	dir := createTestDirectory(t)
+	qfs, err := newQuotaFileSystem(webdav.Dir(dir), dir, 40, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	first, err := qfs.OpenFile(ctx, "/shared.txt", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	second, err := qfs.OpenFile(ctx, "/shared.txt", os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+
+	for _, file := range []webdav.File{first, second} {
+		if _, err := file.Write([]byte(strings.Repeat("a", 8))); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+	}
+	if used, _ := qfs.usage(); used != 38 {
+		t.Fatalf("Expected the same range written twice to be accounted once, got %d used bytes", used)
+	}
+}
+
+func TestQuotaFileSystemReportsQuotaProperties(t *testing.T) {
+	handler := createQuotaHandler(t, 100, 0)
+
+	req := httptest.NewRequest("PROPFIND", "/", strings.NewReader(`<?xml version="1.0"?>
+<D:propfind xmlns:D="DAV:"><D:prop><D:quota-available-bytes/><D:quota-used-bytes/></D:prop></D:propfind>`))
+	req.Header.Set("Depth", "0")
+	resp := httptest.NewRecorder()
+	handler(resp, req)
+
+	body := resp.Body.String()
+	if !strings.Contains(body, ">70</") || !strings.Contains(body, ">30</") {
+		t.Fatalf("Expected quota properties with 70 available and 30 used bytes, got: %s", body)
+	}
+}
+
+func createQuotaHandler(t *testing.T, quota int64, maxFileSize int64) http.HandlerFunc {
+	dir := createTestDirectory(t)
+	qfs, err := newQuotaFileSystem(webdav.Dir(dir), dir, quota, maxFileSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wdHandler := &webdav.Handler{
+		FileSystem: qfs,
+		LockSystem: webdav.NewMemLS(),
+	}
+	return getQuotaHandler(qfs, wdHandler.ServeHTTP)
+}
+
+func doPut(handler http.HandlerFunc, target string, content string, withLength bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPut, target, ioutil.NopCloser(strings.NewReader(content)))
+	if withLength {
+		req.ContentLength = int64(len(content))
+	} else {
+		req.ContentLength = -1
+	}
+	resp := httptest.NewRecorder()
+	handler(resp, req)
+	return resp
+}
@@ -0,0 +1,30 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/loophole/cli/internal/pkg/sharelink"
+)
+
+func TestShareLinkHandlerRejectsInvalidSignature(t *testing.T) {
+	signedCalled, unsignedCalled := false, false
+	handler := getShareLinkHandler(sharelink.NewVerifier(&sharelink.Tunnel{SiteID: "site", Secret: []byte("secret")}),
+		func(w http.ResponseWriter, r *http.Request) { signedCalled = true },
+		func(w http.ResponseWriter, r *http.Request) { unsignedCalled = true })
+
+	resp := httptest.NewRecorder()
+	handler(resp, httptest.NewRequest(http.MethodGet, "/report.pdf?expires=4102444800&signature=forged", nil))
+	if resp.Code != http.StatusForbidden {
+		t.Fatalf("Expected '%d' status, got '%d'", http.StatusForbidden, resp.Code)
+	}
+	if signedCalled || unsignedCalled {
+		t.Fatalf("Expected request with invalid signature not to be served")
+	}
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/report.pdf", nil))
+	if !unsignedCalled {
+		t.Fatalf("Expected request without signature to be passed to regular handler")
+	}
+}
@@ -0,0 +1,207 @@
+package httpserver
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// progressSteps is the number of progress reports sent during a single download
+const progressSteps = 10
+
+// DownloadProgress describes the state of a single download of the shared file
+type DownloadProgress struct {
+	Client    string
+	Sent      int64
+	Total     int64
+	Completed bool
+}
+
+// Deadline is the moment the shared file stops being served, unset until the tunnel is established
+type Deadline struct {
+	mutex sync.Mutex
+	at    time.Time
+}
+
+// Set sets the moment the shared file stops being served
+func (d *Deadline) Set(at time.Time) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.at = at
+}
+
+func (d *Deadline) passed(now time.Time) bool {
+	if d == nil {
+		return false
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return !d.at.IsZero() && !now.Before(d.at)
+}
+
+// singleFileHandler serves one file as an attachment, until it expires or gets downloaded the allowed number of times
+type singleFileHandler struct {
+	path           string
+	maxDownloads   int
+	expiry         *Deadline
+	onProgress     func(DownloadProgress)
+	onLimitReached func()
+	now            func() time.Time
+
+	mutex     sync.Mutex
+	completed int
+	active    int
+}
+
+func newSingleFileHandler(path string) *singleFileHandler {
+	return &singleFileHandler{
+		path:           path,
+		onProgress:     func(DownloadProgress) {},
+		onLimitReached: func() {},
+		now:            time.Now,
+	}
+}
+
+func (sfh *singleFileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := filepath.Base(sfh.path)
+	if r.URL.Path != "/" && r.URL.Path != "/"+name {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if sfh.expiry.passed(sfh.now()) {
+		http.Error(w, "This file is no longer shared", http.StatusGone)
+		return
+	}
+	if r.Method == http.MethodGet {
+		switch sfh.admit() {
+		case downloadsExhausted:
+			http.Error(w, "This file was already downloaded the allowed number of times", http.StatusGone)
+			return
+		case downloadsInProgress:
+			w.Header().Set("Retry-After", "60")
+			http.Error(w, "This file is being downloaded the allowed number of times, try again later", http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	completed := false
+	if r.Method == http.MethodGet {
+		defer func() { sfh.finish(completed) }()
+	}
+
+	file, err := os.Open(sfh.path)
+	if err != nil {
+		http.Error(w, "Shared file is not available", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil || stat.IsDir() {
+		http.Error(w, "Shared file is not available", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	pw := &progressWriter{ResponseWriter: w, handler: sfh, client: r.RemoteAddr}
+	http.ServeContent(pw, r, name, stat.ModTime(), file)
+
+	if r.Method == http.MethodGet && pw.reachedEnd(stat.Size()) {
+		sfh.onProgress(DownloadProgress{Client: pw.client, Sent: pw.sent, Total: pw.total, Completed: true})
+		completed = true
+	}
+}
+
+type admission int
+
+const (
+	downloadAdmitted admission = iota
+	downloadsInProgress
+	downloadsExhausted
+)
+
+// admit counts the download as soon as it starts, so that parallel downloads can't exceed the limit
+func (sfh *singleFileHandler) admit() admission {
+	sfh.mutex.Lock()
+	defer sfh.mutex.Unlock()
+	if sfh.maxDownloads > 0 && sfh.completed >= sfh.maxDownloads {
+		return downloadsExhausted
+	}
+	if sfh.maxDownloads > 0 && sfh.completed+sfh.active >= sfh.maxDownloads {
+		return downloadsInProgress
+	}
+	sfh.active++
+	return downloadAdmitted
+}
+
+// finish releases the download admitted before, counting it when the whole file was sent
+func (sfh *singleFileHandler) finish(completed bool) {
+	sfh.mutex.Lock()
+	sfh.active--
+	if completed {
+		sfh.completed++
+	}
+	reached := completed && sfh.maxDownloads > 0 && sfh.completed == sfh.maxDownloads
+	sfh.mutex.Unlock()
+
+	if reached {
+		sfh.onLimitReached()
+	}
+}
+
+// progressWriter counts bytes of the response body and reports the progress in steps
+type progressWriter struct {
+	http.ResponseWriter
+	handler      *singleFileHandler
+	client       string
+	status       int
+	total        int64
+	sent         int64
+	reportedStep int64
+}
+
+func (pw *progressWriter) WriteHeader(statusCode int) {
+	pw.status = statusCode
+	pw.total, _ = strconv.ParseInt(pw.Header().Get("Content-Length"), 10, 64)
+	pw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	if pw.status == 0 {
+		pw.WriteHeader(http.StatusOK)
+	}
+	n, err := pw.ResponseWriter.Write(p)
+	pw.sent += int64(n)
+	if pw.total > 0 {
+		step := pw.sent * progressSteps / pw.total
+		if step > pw.reportedStep && pw.sent < pw.total {
+			pw.reportedStep = step
+			pw.handler.onProgress(DownloadProgress{Client: pw.client, Sent: pw.sent, Total: pw.total})
+		}
+	}
+	return n, err
+}
+
+// reachedEnd returns whether the whole response was sent and it ended with the last byte of the file,
+// so a download resumed with ranges is counted once
+func (pw *progressWriter) reachedEnd(size int64) bool {
+	if pw.sent != pw.total {
+		return false
+	}
+	switch pw.status {
+	case http.StatusOK:
+		return true
+	case http.StatusPartialContent:
+		return strings.HasSuffix(pw.Header().Get("Content-Range"), fmt.Sprintf("-%d/%d", size-1, size))
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package httpserver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func createTestFile(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "loophole-singlefile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	file := filepath.Join(dir, "report.txt")
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestSingleFileIsServedAsAttachment(t *testing.T) {
+	sfh := newSingleFileHandler(createTestFile(t, "0123456789"))
+
+	resp := doRequest(t, sfh, "/")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected '%d' status, got '%d'", http.StatusOK, resp.Code)
+	}
+	if disposition := resp.Header().Get("Content-Disposition"); disposition != "attachment; filename=report.txt" {
+		t.Fatalf("Expected attachment disposition, got '%s'", disposition)
+	}
+	if resp := doRequest(t, sfh, "/other.txt"); resp.Code != http.StatusNotFound {
+		t.Fatalf("Expected '%d' status, got '%d'", http.StatusNotFound, resp.Code)
+	}
+}
+
+func TestSingleFileResumedDownloadCountsOnce(t *testing.T) {
+	sfh := newSingleFileHandler(createTestFile(t, "0123456789"))
+	sfh.maxDownloads = 1
+	limitReached := false
+	sfh.onLimitReached = func() { limitReached = true }
+
+	req := httptest.NewRequest(http.MethodGet, "/report.txt", nil)
+	req.Header.Set("Range", "bytes=0-4")
+	resp := httptest.NewRecorder()
+	sfh.ServeHTTP(resp, req)
+	if resp.Code != http.StatusPartialContent || resp.Body.String() != "01234" {
+		t.Fatalf("Expected partial content '01234', got '%d' '%s'", resp.Code, resp.Body.String())
+	}
+	if limitReached {
+		t.Fatalf("Expected partial download not to be counted")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/report.txt", nil)
+	req.Header.Set("Range", "bytes=5-")
+	sfh.ServeHTTP(httptest.NewRecorder(), req)
+	if !limitReached {
+		t.Fatalf("Expected download limit to be reached once the file was fully sent")
+	}
+
+	if resp := doRequest(t, sfh, "/"); resp.Code != http.StatusGone {
+		t.Fatalf("Expected '%d' status, got '%d'", http.StatusGone, resp.Code)
+	}
+}
+
+func TestSingleFileIsNotServedAfterExpiry(t *testing.T) {
+	sfh := newSingleFileHandler(createTestFile(t, "content"))
+	sfh.expiry = &Deadline{}
+	if resp := doRequest(t, sfh, "/"); resp.Code != http.StatusOK {
+		t.Fatalf("Expected file to be served before the deadline is set, got '%d'", resp.Code)
+	}
+	sfh.expiry.Set(time.Now().Add(-time.Minute))
+
+	resp := doRequest(t, sfh, "/")
+	if resp.Code != http.StatusGone {
+		t.Fatalf("Expected '%d' status, got '%d'", http.StatusGone, resp.Code)
+	}
+	if strings.Contains(resp.Body.String(), "content") {
+		t.Fatalf("Expected file content not to be served")
+	}
+}
+
+func TestSingleFileParallelDownloadsDoNotExceedLimit(t *testing.T) {
+	sfh := newSingleFileHandler(createTestFile(t, "0123456789"))
+	sfh.maxDownloads = 1
+
+	if sfh.admit() != downloadAdmitted {
+		t.Fatalf("Expected first download to be admitted")
+	}
+	if resp := doRequest(t, sfh, "/"); resp.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected '%d' status while the allowed download is in progress, got '%d'", http.StatusServiceUnavailable, resp.Code)
+	}
+	sfh.finish(false)
+	if resp := doRequest(t, sfh, "/"); resp.Code != http.StatusOK {
+		t.Fatalf("Expected download to be admitted once the interrupted one finished, got '%d'", resp.Code)
+	}
+	if resp := doRequest(t, sfh, "/"); resp.Code != http.StatusGone {
+		t.Fatalf("Expected '%d' status, got '%d'", http.StatusGone, resp.Code)
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"crypto/tls"
 	"fmt"
 
+	"github.com/loophole/cli/config"
 	"github.com/loophole/cli/internal/pkg/cache"
 	"github.com/loophole/cli/internal/pkg/urlmaker"
+	"golang.org/x/crypto/acme"
 	"golang.org/x/crypto/acme/autocert"
 )
 
@@ -19,10 +21,13 @@ func getTLSConfig(siteID string, domain string, disableOldCiphers bool) *tls.Con
 		Cache:      autocert.DirCache(cache.GetLocalStorageDir("certs")),
 		Email:      fmt.Sprintf("lh-%s@main.dev", siteID),
 	}
+	if config.Config.ACMEDirectoryURL != "" {
+		certManager.Client = &acme.Client{DirectoryURL: config.Config.ACMEDirectoryURL}
+	}
 
-	config := certManager.TLSConfig()
+	tlsConfig := certManager.TLSConfig()
 	if disableOldCiphers {
-		config.MinVersion = tls.VersionTLS12
+		tlsConfig.MinVersion = tls.VersionTLS12
 	}
-	return config
+	return tlsConfig
 }
@@ -0,0 +1,77 @@
+package httpserver
+
+import (
+	"net/http"
+
+	auth "github.com/abbot/go-http-auth"
+	"github.com/loophole/cli/internal/pkg/urlmaker"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Permission defines access level of WebDav user
+type Permission string
+
+const (
+	// PermissionRead allows to list and download files
+	PermissionRead Permission = "read"
+	// PermissionWrite additionally allows to upload, modify and delete files
+	PermissionWrite Permission = "write"
+)
+
+type webdavUser struct {
+	username   string
+	password   string
+	permission Permission
+}
+
+// isWriteMethod returns whether the WebDav method modifies the exposed directory
+func isWriteMethod(method string) bool {
+	switch method {
+	case http.MethodPut, http.MethodDelete, http.MethodPost, http.MethodPatch,
+		"MKCOL", "MOVE", "COPY", "PROPPATCH", "LOCK", "UNLOCK":
+		return true
+	}
+	return false
+}
+
+// getWebdavAccessHandler restricts write methods according to permissions of authenticated user
+// or rejects them altogether in read-only mode
+func getWebdavAccessHandler(siteID string, domain string, users []webdavUser, readOnly bool, handler http.HandlerFunc) (http.HandlerFunc, error) {
+	if len(users) == 0 {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if readOnly && isWriteMethod(r.Method) {
+				http.Error(w, "This WebDav share is read-only", http.StatusForbidden)
+				return
+			}
+			handler(w, r)
+		}, nil
+	}
+
+	hashedPasswords := make(map[string]string)
+	permissions := make(map[string]Permission)
+	for _, user := range users {
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		hashedPasswords[user.username] = string(hashedPassword)
+		permissions[user.username] = user.permission
+	}
+
+	authenticator := auth.NewBasicAuthenticator(urlmaker.GetSiteFQDN(siteID, domain), func(user string, realm string) string {
+		return hashedPasswords[user]
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := authenticator.CheckAuth(r)
+		if username == "" {
+			authenticator.RequireAuth(w, r)
+			return
+		}
+		if isWriteMethod(r.Method) && (readOnly || permissions[username] != PermissionWrite) {
+			http.Error(w, "You don't have permission to modify this WebDav share", http.StatusForbidden)
+			return
+		}
+		handler(w, r)
+	}, nil
+}
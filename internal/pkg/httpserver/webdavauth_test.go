@@ -0,0 +1,71 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadOnlyWebdavRejectsWriteMethods(t *testing.T) {
+	handler, err := getWebdavAccessHandler("site", "loophole.site", nil, true, okHandlerFunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, method := range []string{http.MethodPut, http.MethodDelete, "MKCOL", "MOVE", "COPY", "PROPPATCH"} {
+		resp := httptest.NewRecorder()
+		handler(resp, httptest.NewRequest(method, "/file.txt", nil))
+		if resp.Code != http.StatusForbidden {
+			t.Fatalf("Expected '%d' status for %s, got '%d'", http.StatusForbidden, method, resp.Code)
+		}
+	}
+	for _, method := range []string{http.MethodGet, "PROPFIND", http.MethodOptions} {
+		resp := httptest.NewRecorder()
+		handler(resp, httptest.NewRequest(method, "/file.txt", nil))
+		if resp.Code != http.StatusOK {
+			t.Fatalf("Expected '%d' status for %s, got '%d'", http.StatusOK, method, resp.Code)
+		}
+	}
+}
+
+func TestWebdavPermissionsArePerUser(t *testing.T) {
+	users := []webdavUser{
+		{username: "uploader", password: "secret1", permission: PermissionWrite},
+		{username: "reader", password: "secret2", permission: PermissionRead},
+	}
+	handler, err := getWebdavAccessHandler("site", "loophole.site", users, false, okHandlerFunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		username string
+		password string
+		method   string
+		expected int
+	}{
+		{"uploader", "secret1", http.MethodPut, http.StatusOK},
+		{"reader", "secret2", http.MethodGet, http.StatusOK},
+		{"reader", "secret2", http.MethodPut, http.StatusForbidden},
+		{"reader", "secret2", http.MethodDelete, http.StatusForbidden},
+		{"reader", "secret2", "LOCK", http.StatusForbidden},
+		{"uploader", "secret1", "LOCK", http.StatusOK},
+		{"reader", "wrong", http.MethodGet, http.StatusUnauthorized},
+		{"", "", http.MethodGet, http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, "/file.txt", nil)
+		if c.username != "" {
+			req.SetBasicAuth(c.username, c.password)
+		}
+		resp := httptest.NewRecorder()
+		handler(resp, req)
+		if resp.Code != c.expected {
+			t.Fatalf("Expected '%d' status for %s by '%s', got '%d'", c.expected, c.method, c.username, resp.Code)
+		}
+	}
+}
+
+func okHandlerFunc(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
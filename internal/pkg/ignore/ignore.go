@@ -0,0 +1,191 @@
+package ignore
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// FileName is the name of the file holding ignore patterns, placed in the root of exposed directory
+const FileName = ".loopholeignore"
+
+type rule struct {
+	pattern *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// Matcher decides which paths of exposed directory should stay hidden, using gitignore syntax
+type Matcher struct {
+	rules        []rule
+	showDotfiles bool
+}
+
+// Load creates matcher from the .loopholeignore file in the root directory (if present) and additional patterns
+func Load(root string, patterns []string, showDotfiles bool) (*Matcher, error) {
+	matcher := New(showDotfiles)
+
+	file, err := os.Open(filepath.Join(root, FileName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("There was a problem reading %s: %v", FileName, err)
+	}
+	if err == nil {
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			err := matcher.Add(scanner.Text())
+			if err != nil {
+				return nil, err
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("There was a problem reading %s: %v", FileName, err)
+		}
+	}
+
+	for _, pattern := range patterns {
+		err := matcher.Add(pattern)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return matcher, nil
+}
+
+// New creates matcher without any patterns
+func New(showDotfiles bool) *Matcher {
+	return &Matcher{
+		showDotfiles: showDotfiles,
+	}
+}
+
+// Add parses single line of gitignore syntax and appends it to the matcher rules. Patterns are matched
+// case-insensitively, so that hidden files can't be reached with different case on case-insensitive filesystems
+func (m *Matcher) Add(line string) error {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil
+	}
+
+	r := rule{}
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return nil
+	}
+
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	expression := "(?i)^"
+	if !anchored {
+		expression += "(?:.*/)?"
+	}
+	expression += translate(line) + "$"
+
+	pattern, err := regexp.Compile(expression)
+	if err != nil {
+		return fmt.Errorf("Invalid ignore pattern '%s': %v", line, err)
+	}
+	r.pattern = pattern
+	m.rules = append(m.rules, r)
+	return nil
+}
+
+// Ignored returns whether given slash separated path (relative to the exposed directory) should stay hidden
+func (m *Matcher) Ignored(name string, isDir bool) bool {
+	name = strings.Trim(name, "/")
+	if name == "" || name == "." {
+		return false
+	}
+
+	parts := strings.Split(name, "/")
+	// the ignore file itself is never exposed, even with dotfiles shown
+	if strings.EqualFold(parts[len(parts)-1], FileName) {
+		return true
+	}
+	if !m.showDotfiles {
+		for _, part := range parts {
+			if strings.HasPrefix(part, ".") && part != "." && part != ".." {
+				return true
+			}
+		}
+	}
+
+	// content of ignored directory can't be brought back, same as with git
+	for i := 1; i < len(parts); i++ {
+		if m.match(strings.Join(parts[:i], "/"), true) {
+			return true
+		}
+	}
+	return m.match(name, isDir)
+}
+
+func (m *Matcher) match(name string, isDir bool) bool {
+	ignored := false
+	for _, r := range m.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.pattern.MatchString(name) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// translate converts glob pattern into regular expression
+func translate(pattern string) string {
+	var expression strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			if strings.HasPrefix(pattern[i:], "**") {
+				i++
+				if strings.HasPrefix(pattern[i+1:], "/") {
+					// "**/" matches zero or more directories
+					expression.WriteString("(?:.*/)?")
+					i++
+				} else {
+					expression.WriteString(".*")
+				}
+			} else {
+				expression.WriteString("[^/]*")
+			}
+		case '?':
+			expression.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				expression.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expression.WriteString("[" + class + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				expression.WriteString(regexp.QuoteMeta(string(pattern[i])))
+			}
+		default:
+			expression.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return expression.String()
+}
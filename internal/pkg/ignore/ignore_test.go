@@ -0,0 +1,105 @@
+package ignore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDotfilesAreHiddenByDefault(t *testing.T) {
+	matcher := New(false)
+
+	for _, name := range []string{".env", "/.git", ".git/config", "src/.secret"} {
+		if !matcher.Ignored(name, false) {
+			t.Fatalf("Expected '%s' to be ignored", name)
+		}
+	}
+	if matcher.Ignored("src/main.go", false) {
+		t.Fatalf("Expected 'src/main.go' not to be ignored")
+	}
+}
+
+func TestDotfilesCanBeShown(t *testing.T) {
+	matcher := New(true)
+
+	if matcher.Ignored(".env", false) {
+		t.Fatalf("Expected '.env' not to be ignored")
+	}
+	if !matcher.Ignored(FileName, false) {
+		t.Fatalf("Expected '%s' to be ignored even with dotfiles shown", FileName)
+	}
+}
+
+func TestPatternsIgnoreCase(t *testing.T) {
+	matcher := New(true)
+	matcher.Add("secret.env")
+	matcher.Add("/Build/")
+
+	for _, name := range []string{"Secret.env", "config/SECRET.ENV", "build/output.bin"} {
+		if !matcher.Ignored(name, false) {
+			t.Fatalf("Expected '%s' to be ignored", name)
+		}
+	}
+}
+
+func TestPatternsFollowGitignoreSyntax(t *testing.T) {
+	matcher := New(false)
+	for _, line := range []string{"# comment", "node_modules/", "*.log", "!keep.log", "/build", "docs/**/*.pdf"} {
+		if err := matcher.Add(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name     string
+		isDir    bool
+		expected bool
+	}{
+		{"node_modules", true, true},
+		{"node_modules/react/index.js", false, true},
+		{"web/node_modules", true, true},
+		{"node_modules", false, false},
+		{"error.log", false, true},
+		{"logs/error.log", false, true},
+		{"keep.log", false, false},
+		{"build", true, true},
+		{"src/build", true, false},
+		{"docs/manual.pdf", false, true},
+		{"docs/a/b/manual.pdf", false, true},
+		{"manual.pdf", false, false},
+		{"src/main.go", false, false},
+	}
+	for _, c := range cases {
+		if result := matcher.Ignored(c.name, c.isDir); result != c.expected {
+			t.Fatalf("Expected Ignored('%s', %v) to be %v, got %v", c.name, c.isDir, c.expected, result)
+		}
+	}
+}
+
+func TestLoadReadsIgnoreFileAndExcludes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loophole-ignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, FileName), []byte("secrets/\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matcher, err := Load(dir, []string{"*.key"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !matcher.Ignored("secrets/password.txt", false) {
+		t.Fatalf("Expected pattern from %s to be applied", FileName)
+	}
+	if !matcher.Ignored("certs/server.key", false) {
+		t.Fatalf("Expected exclude pattern to be applied")
+	}
+	if !matcher.Ignored(FileName, false) {
+		t.Fatalf("Expected %s itself to be hidden", FileName)
+	}
+}
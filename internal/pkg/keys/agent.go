@@ -0,0 +1,53 @@
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// AgentSigner returns authentication method using the key held by SSH agent, the private key is never read from disk.
+// The key is selected by its fingerprint, the first one is used when no fingerprint is given.
+// The connection to the agent stays open as the agent signs every handshake, including reconnections,
+// so it has to be closed by the caller once the tunnel ends
+func AgentSigner(fingerprint string) (ssh.AuthMethod, ssh.PublicKey, io.Closer, error) {
+	conn, err := dialAgent()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	signers, err := agent.NewClient(conn).Signers()
+	if err != nil {
+		conn.Close()
+		return nil, nil, nil, fmt.Errorf("There was a problem listing keys of SSH agent: %v", err)
+	}
+	if len(signers) == 0 {
+		conn.Close()
+		return nil, nil, nil, errors.New("SSH agent has no keys, add one with ssh-add")
+	}
+	for _, signer := range signers {
+		if fingerprint == "" || matchesFingerprint(signer.PublicKey(), fingerprint) {
+			return ssh.PublicKeys(signer), signer.PublicKey(), conn, nil
+		}
+	}
+	conn.Close()
+	return nil, nil, nil, fmt.Errorf("Key '%s' not found in SSH agent", fingerprint)
+}
+
+// LooksLikeFingerprint returns whether the value is SHA256 or MD5 fingerprint of a key
+func LooksLikeFingerprint(value string) bool {
+	return strings.HasPrefix(value, "SHA256:") || strings.HasPrefix(value, "MD5:")
+}
+
+// matchesFingerprint compares the key with SHA256 fingerprint, with or without the prefix, or legacy MD5 one
+func matchesFingerprint(publicKey ssh.PublicKey, fingerprint string) bool {
+	fingerprint = strings.TrimSpace(fingerprint)
+	sha256Fingerprint := ssh.FingerprintSHA256(publicKey)
+	return fingerprint == sha256Fingerprint ||
+		"SHA256:"+fingerprint == sha256Fingerprint ||
+		strings.TrimPrefix(fingerprint, "MD5:") == ssh.FingerprintLegacyMD5(publicKey)
+}
@@ -0,0 +1,194 @@
+package keys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// serveAgent starts in-process SSH agent holding the given keys on unix socket in the directory,
+// returned function stops it and restores the previous agent
+func serveAgent(t *testing.T, dir string, keys ...ed25519.PrivateKey) func() {
+	keyring := agent.NewKeyring()
+	for _, key := range keys {
+		if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	socket := filepath.Join(dir, "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go agent.ServeAgent(keyring, conn)
+		}
+	}()
+
+	oldSocket, wasSet := os.LookupEnv("SSH_AUTH_SOCK")
+	os.Setenv("SSH_AUTH_SOCK", socket)
+	return func() {
+		listener.Close()
+		if wasSet {
+			os.Setenv("SSH_AUTH_SOCK", oldSocket)
+		} else {
+			os.Unsetenv("SSH_AUTH_SOCK")
+		}
+	}
+}
+
+func generateEd25519(t *testing.T) (ed25519.PrivateKey, ssh.PublicKey) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := ssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return private, publicKey
+}
+
+func TestAgentKeyIsSelectedByFingerprint(t *testing.T) {
+	firstKey, _ := generateEd25519(t)
+	secondKey, secondPublicKey := generateEd25519(t)
+	dir, err := ioutil.TempDir("", "loophole-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	stopAgent := serveAgent(t, dir, firstKey, secondKey)
+	defer stopAgent()
+
+	authMethod, publicKey, conn, err := AgentSigner(ssh.FingerprintSHA256(secondPublicKey))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if authMethod == nil || ssh.FingerprintSHA256(publicKey) != ssh.FingerprintSHA256(secondPublicKey) {
+		t.Fatalf("Expected second key to be selected, got '%s'", ssh.FingerprintSHA256(publicKey))
+	}
+	conn.Close()
+
+	_, publicKey, conn, err = AgentSigner(ssh.FingerprintLegacyMD5(secondPublicKey))
+	if err != nil || ssh.FingerprintSHA256(publicKey) != ssh.FingerprintSHA256(secondPublicKey) {
+		t.Fatalf("Expected key to be selected by MD5 fingerprint, got: %v", err)
+	}
+	conn.Close()
+}
+
+func TestFirstAgentKeyIsUsedByDefault(t *testing.T) {
+	key, expectedPublicKey := generateEd25519(t)
+	dir, err := ioutil.TempDir("", "loophole-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	stopAgent := serveAgent(t, dir, key)
+	defer stopAgent()
+
+	_, publicKey, conn, err := AgentSigner("")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	defer conn.Close()
+	if ssh.FingerprintSHA256(publicKey) != ssh.FingerprintSHA256(expectedPublicKey) {
+		t.Fatalf("Expected the only key to be used, got '%s'", ssh.FingerprintSHA256(publicKey))
+	}
+}
+
+func TestMissingAgentKeyIsReported(t *testing.T) {
+	key, _ := generateEd25519(t)
+	_, otherPublicKey := generateEd25519(t)
+	dir, err := ioutil.TempDir("", "loophole-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	stopAgent := serveAgent(t, dir, key)
+	defer stopAgent()
+
+	if _, _, _, err := AgentSigner(ssh.FingerprintSHA256(otherPublicKey)); err == nil {
+		t.Fatalf("Expected error for key not held by the agent")
+	}
+
+	emptyDir, err := ioutil.TempDir("", "loophole-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(emptyDir)
+	stopEmptyAgent := serveAgent(t, emptyDir)
+	defer stopEmptyAgent()
+	if _, _, _, err := AgentSigner(""); err == nil {
+		t.Fatalf("Expected error for agent without keys")
+	}
+}
+
+func TestAgentKeyAuthenticates(t *testing.T) {
+	key, expectedPublicKey := generateEd25519(t)
+	dir, err := ioutil.TempDir("", "loophole-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	stopAgent := serveAgent(t, dir, key)
+	defer stopAgent()
+	authMethod, _, conn, err := AgentSigner("")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	hostKey, _ := generateEd25519(t)
+	hostSigner, _ := ssh.NewSignerFromKey(hostKey)
+	serverConfig := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, publicKey ssh.PublicKey) (*ssh.Permissions, error) {
+			if ssh.FingerprintSHA256(publicKey) != ssh.FingerprintSHA256(expectedPublicKey) {
+				return nil, errors.New("unknown key")
+			}
+			return nil, nil
+		},
+	}
+	serverConfig.AddHostKey(hostSigner)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go ssh.NewServerConn(conn, serverConfig)
+		}
+	}()
+
+	clientConfig := &ssh.ClientConfig{
+		User:            "loophole",
+		Auth:            []ssh.AuthMethod{authMethod},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}
+	client, err := ssh.Dial("tcp", listener.Addr().String(), clientConfig)
+	if err != nil {
+		t.Fatalf("Expected agent key to authenticate, got: %v", err)
+	}
+	client.Close()
+
+	conn.Close()
+	if _, err := ssh.Dial("tcp", listener.Addr().String(), clientConfig); err == nil {
+		t.Fatalf("Expected authentication to fail once agent connection is closed")
+	}
+}
@@ -0,0 +1,25 @@
+//go:build !windows
+// +build !windows
+
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+)
+
+// dialAgent connects to SSH agent listening on unix socket given with SSH_AUTH_SOCK
+func dialAgent() (io.ReadWriteCloser, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, errors.New("SSH agent is not available, SSH_AUTH_SOCK is not set")
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("There was a problem connecting to SSH agent: %v", err)
+	}
+	return conn, nil
+}
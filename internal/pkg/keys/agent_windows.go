@@ -0,0 +1,35 @@
+//go:build windows
+// +build windows
+
+package keys
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// openSSHAgentPipe is the named pipe of the agent service shipped with Windows OpenSSH
+const openSSHAgentPipe = `\\.\pipe\openssh-ssh-agent`
+
+// dialAgent connects to SSH agent given with SSH_AUTH_SOCK, either named pipe or unix socket,
+// falling back to Windows OpenSSH agent
+func dialAgent() (io.ReadWriteCloser, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		socket = openSSHAgentPipe
+	}
+	var conn io.ReadWriteCloser
+	var err error
+	if strings.HasPrefix(socket, `\\.\pipe\`) {
+		conn, err = os.OpenFile(socket, os.O_RDWR, 0)
+	} else {
+		conn, err = net.Dial("unix", socket)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("There was a problem connecting to SSH agent at %s: %v", socket, err)
+	}
+	return conn, nil
+}
@@ -0,0 +1,168 @@
+package keys
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/loophole/cli/internal/pkg/communication"
+	"golang.org/x/crypto/ssh"
+)
+
+// CertificateExpiredError is returned when the certificate found next to the identity file is no longer valid
+type CertificateExpiredError struct {
+	File        string
+	ValidBefore time.Time
+}
+
+func (err *CertificateExpiredError) Error() string {
+	return fmt.Sprintf("The certificate %s expired at %s, please renew it", err.File, err.ValidBefore.Format(time.RFC3339))
+}
+
+// CertificateFile returns the path of the OpenSSH certificate accompanying the identity file, e.g. id_ed25519-cert.pub
+func CertificateFile(identityFile string) string {
+	return identityFile + "-cert.pub"
+}
+
+// CheckCertificate verifies the certificate used for authentication with the identity file is currently valid,
+// missing certificate or certificate issued for a different key is not an error as it's not used
+func CheckCertificate(identityFile string) error {
+	certificateStates.mutex.Lock()
+	signers, ok := certificateStates.byIdentity[identityFile]
+	certificateStates.mutex.Unlock()
+	if !ok {
+		return nil
+	}
+	certificate, err := signers.load()
+	if err != nil || certificate == nil {
+		return err
+	}
+	return checkValidity(signers.file, certificate, time.Now())
+}
+
+// certificateStates keeps the certificate of each identity file, so that it's checked once per change
+// no matter how many times the tunnels reconnect
+var certificateStates = struct {
+	mutex      sync.Mutex
+	byIdentity map[string]*certificateSigners
+}{byIdentity: map[string]*certificateSigners{}}
+
+// certificateSigners provides the signers for authentication, the certificate is read again whenever its file changes
+// so that renewed certificates are used for reconnections without restarting
+type certificateSigners struct {
+	file    string
+	signer  ssh.Signer
+	mutex   sync.Mutex
+	loaded  bool
+	modTime time.Time
+	cert    *ssh.Certificate
+}
+
+// certificateAuthMethod returns authentication method using the certificate of the identity file if present,
+// followed by the plain key
+func certificateAuthMethod(identityFile string, signer ssh.Signer) ssh.AuthMethod {
+	certificateStates.mutex.Lock()
+	defer certificateStates.mutex.Unlock()
+
+	signers, ok := certificateStates.byIdentity[identityFile]
+	if !ok || !bytes.Equal(signers.signer.PublicKey().Marshal(), signer.PublicKey().Marshal()) {
+		signers = &certificateSigners{
+			file:   CertificateFile(identityFile),
+			signer: signer,
+		}
+		certificateStates.byIdentity[identityFile] = signers
+	}
+	return ssh.PublicKeysCallback(signers.signers)
+}
+
+// load returns the certificate if it was issued for the key, reading it only when the file changed
+func (s *certificateSigners) load() (*ssh.Certificate, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	info, err := os.Stat(s.file)
+	if os.IsNotExist(err) {
+		s.loaded = false
+		s.cert = nil
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("There was a problem reading certificate %s: %v", s.file, err)
+	}
+	if s.loaded && info.ModTime().Equal(s.modTime) {
+		return s.cert, nil
+	}
+
+	certificate, err := readCertificate(s.file)
+	if err != nil {
+		return nil, err
+	}
+	if certificate == nil {
+		// removed after it was checked
+		s.loaded = false
+		s.cert = nil
+		return nil, nil
+	}
+	if !bytes.Equal(certificate.Key.Marshal(), s.signer.PublicKey().Marshal()) {
+		communication.Warn(fmt.Sprintf("The certificate %s was issued for a different key, ignoring it", s.file))
+		certificate = nil
+	} else {
+		communication.Debug(fmt.Sprintf("Loaded certificate %s with key ID '%s'", s.file, certificate.KeyId))
+	}
+	s.cert = certificate
+	s.modTime = info.ModTime()
+	s.loaded = true
+	return s.cert, nil
+}
+
+func (s *certificateSigners) signers() ([]ssh.Signer, error) {
+	certificate, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	if certificate == nil {
+		return []ssh.Signer{s.signer}, nil
+	}
+
+	err = checkValidity(s.file, certificate, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	certSigner, err := ssh.NewCertSigner(certificate, s.signer)
+	if err != nil {
+		return nil, fmt.Errorf("There was a problem using certificate %s: %v", s.file, err)
+	}
+	return []ssh.Signer{certSigner, s.signer}, nil
+}
+
+// readCertificate parses the certificate file, returning nil when it doesn't exist
+func readCertificate(file string) (*ssh.Certificate, error) {
+	content, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("There was a problem reading certificate %s: %v", file, err)
+	}
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey(content)
+	if err != nil {
+		return nil, fmt.Errorf("There was a problem parsing certificate %s: %v", file, err)
+	}
+	certificate, ok := publicKey.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("The file %s is not an SSH certificate", file)
+	}
+	return certificate, nil
+}
+
+func checkValidity(file string, certificate *ssh.Certificate, now time.Time) error {
+	validBefore := time.Unix(int64(certificate.ValidBefore), 0)
+	if certificate.ValidBefore != ssh.CertTimeInfinity && now.After(validBefore) {
+		return &CertificateExpiredError{File: file, ValidBefore: validBefore}
+	}
+	if now.Before(time.Unix(int64(certificate.ValidAfter), 0)) {
+		return fmt.Errorf("The certificate %s is not valid yet", file)
+	}
+	return nil
+}
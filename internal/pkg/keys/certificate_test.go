@@ -0,0 +1,164 @@
+package keys
+
+import (
+	"crypto/rand"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// writeCertificate signs the public key with the CA and stores the certificate next to the identity file
+func writeCertificate(t *testing.T, ca ssh.Signer, identityFile string, publicKey ssh.PublicKey, validBefore time.Time, modTime time.Time) {
+	certificate := &ssh.Certificate{
+		Key:             publicKey,
+		KeyId:           "loophole-test",
+		CertType:        ssh.UserCert,
+		ValidPrincipals: []string{"site"},
+		ValidAfter:      uint64(time.Now().Add(-time.Hour).Unix()),
+		ValidBefore:     uint64(validBefore.Unix()),
+	}
+	if err := certificate.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+	file := CertificateFile(identityFile)
+	if err := ioutil.WriteFile(file, ssh.MarshalAuthorizedKey(certificate), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(file, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serveCertificateOnlySSH starts SSH server accepting only certificates signed by the given CA
+func serveCertificateOnlySSH(t *testing.T, ca ssh.PublicKey) string {
+	checker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return ssh.FingerprintSHA256(auth) == ssh.FingerprintSHA256(ca)
+		},
+	}
+	hostKey, _ := generateEd25519(t)
+	hostSigner, _ := ssh.NewSignerFromKey(hostKey)
+	serverConfig := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, publicKey ssh.PublicKey) (*ssh.Permissions, error) {
+			if _, ok := publicKey.(*ssh.Certificate); !ok {
+				return nil, errors.New("only certificates are accepted")
+			}
+			return checker.Authenticate(conn, publicKey)
+		},
+	}
+	serverConfig.AddHostKey(hostSigner)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go ssh.NewServerConn(conn, serverConfig)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func dialWith(address string, authMethod ssh.AuthMethod) error {
+	client, err := ssh.Dial("tcp", address, &ssh.ClientConfig{
+		User:            "site",
+		Auth:            []ssh.AuthMethod{authMethod},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		return err
+	}
+	return client.Close()
+}
+
+func TestCertificateIsUsedAndReloaded(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loophole-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	identityFile := filepath.Join(dir, "id_ed25519")
+	caKey, caPublicKey := generateEd25519(t)
+	ca, _ := ssh.NewSignerFromKey(caKey)
+	address := serveCertificateOnlySSH(t, caPublicKey)
+
+	authMethod, publicKey, err := ParsePublicKey(identityFile, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := dialWith(address, authMethod); err == nil {
+		t.Fatalf("Expected plain key to be rejected without certificate")
+	}
+
+	now := time.Now()
+	writeCertificate(t, ca, identityFile, publicKey, now.Add(time.Hour), now.Add(-time.Minute))
+	if err := dialWith(address, authMethod); err != nil {
+		t.Fatalf("Expected certificate to authenticate, got: %v", err)
+	}
+
+	writeCertificate(t, ca, identityFile, publicKey, now.Add(-time.Minute), now)
+	var expiredError *CertificateExpiredError
+	if err := CheckCertificate(identityFile); !errors.As(err, &expiredError) {
+		t.Fatalf("Expected expired certificate error, got: %v", err)
+	}
+	if err := dialWith(address, authMethod); err == nil {
+		t.Fatalf("Expected expired certificate to be rejected")
+	}
+
+	writeCertificate(t, ca, identityFile, publicKey, now.Add(time.Hour), now.Add(time.Minute))
+	if err := CheckCertificate(identityFile); err != nil {
+		t.Fatalf("Expected renewed certificate to be valid, got: %v", err)
+	}
+	if err := dialWith(address, authMethod); err != nil {
+		t.Fatalf("Expected renewed certificate to be reloaded, got: %v", err)
+	}
+}
+
+func TestMissingCertificateIsNotAnError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loophole-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	identityFile := filepath.Join(dir, "id_ed25519")
+	if err := CheckCertificate(identityFile); err != nil {
+		t.Fatalf("Expected no error without certificate, got: %v", err)
+	}
+}
+
+func TestCertificateOfOtherKeyIsIgnored(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loophole-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	identityFile := filepath.Join(dir, "id_ed25519")
+	caKey, _ := generateEd25519(t)
+	ca, _ := ssh.NewSignerFromKey(caKey)
+	_, otherPublicKey := generateEd25519(t)
+
+	if _, _, err := ParsePublicKey(identityFile, ""); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	now := time.Now()
+	writeCertificate(t, ca, identityFile, otherPublicKey, now.Add(-time.Minute), now)
+
+	if err := CheckCertificate(identityFile); err != nil {
+		t.Fatalf("Expected certificate of other key to be ignored, got: %v", err)
+	}
+	signers, err := certificateStates.byIdentity[identityFile].signers()
+	if err != nil || len(signers) != 1 {
+		t.Fatalf("Expected only the plain key to be used, got %d signers: %v", len(signers), err)
+	}
+}
@@ -0,0 +1,155 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// KeyTypeEd25519 is the default key type, fast to generate and short
+	KeyTypeEd25519 = "ed25519"
+	// KeyTypeECDSA is ECDSA key on NIST curve
+	KeyTypeECDSA = "ecdsa"
+	// KeyTypeRSA is RSA key, used by older versions
+	KeyTypeRSA = "rsa"
+
+	// DefaultKeyType is used for generated identities
+	DefaultKeyType = KeyTypeEd25519
+
+	defaultRSABits   = 4096
+	defaultECDSABits = 256
+)
+
+// identityFileNames are the names of identity files looked up in order when none is given explicitly
+var identityFileNames = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// DefaultIdentityFile returns the first existing identity in the directory, or the path of the one
+// which should be generated when there is none
+func DefaultIdentityFile(sshDir string) string {
+	for _, name := range identityFileNames {
+		path := filepath.Join(sshDir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(sshDir, IdentityFileName(DefaultKeyType))
+}
+
+// IdentityFileName returns the conventional file name of the key type
+func IdentityFileName(keyType string) string {
+	return fmt.Sprintf("id_%s", keyType)
+}
+
+// IdentityFileNameFor returns the conventional file name of the key
+func IdentityFileNameFor(publicKey ssh.PublicKey) string {
+	switch {
+	case publicKey.Type() == ssh.KeyAlgoED25519:
+		return IdentityFileName(KeyTypeEd25519)
+	case strings.HasPrefix(publicKey.Type(), "ecdsa-"):
+		return IdentityFileName(KeyTypeECDSA)
+	case publicKey.Type() == ssh.KeyAlgoRSA:
+		return IdentityFileName(KeyTypeRSA)
+	}
+	return "id_imported"
+}
+
+// keyTypeForFile guesses the type of key to be generated from its file name, keeping RSA for id_rsa used by older versions
+func keyTypeForFile(file string) string {
+	name := filepath.Base(file)
+	switch {
+	case strings.Contains(name, KeyTypeRSA):
+		return KeyTypeRSA
+	case strings.Contains(name, KeyTypeECDSA):
+		return KeyTypeECDSA
+	}
+	return DefaultKeyType
+}
+
+// GenerateKeyPair creates the private key in OpenSSH format and the public key in authorized_keys format,
+// bits are used by RSA and ECDSA keys, 0 selects the default size
+func GenerateKeyPair(keyType string, bits int, comment string) (private []byte, public []byte, err error) {
+	var privateKey crypto.PrivateKey
+	var publicKey crypto.PublicKey
+
+	switch keyType {
+	case KeyTypeEd25519:
+		publicKey, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	case KeyTypeECDSA:
+		var curve elliptic.Curve
+		switch bits {
+		case 0, 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		case 521:
+			curve = elliptic.P521()
+		default:
+			return nil, nil, fmt.Errorf("Unsupported ECDSA key size %d, use 256, 384 or 521", bits)
+		}
+		var key *ecdsa.PrivateKey
+		key, err = ecdsa.GenerateKey(curve, rand.Reader)
+		if err == nil {
+			privateKey, publicKey = key, &key.PublicKey
+		}
+	case KeyTypeRSA:
+		if bits == 0 {
+			bits = defaultRSABits
+		}
+		if bits < 2048 {
+			return nil, nil, fmt.Errorf("RSA keys have to be at least 2048 bits long")
+		}
+		var key *rsa.PrivateKey
+		key, err = rsa.GenerateKey(rand.Reader, bits)
+		if err == nil {
+			privateKey, publicKey = key, &key.PublicKey
+		}
+	default:
+		return nil, nil, fmt.Errorf("Unsupported key type '%s', use %s, %s or %s", keyType, KeyTypeEd25519, KeyTypeECDSA, KeyTypeRSA)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("There was a problem generating %s key: %v", keyType, err)
+	}
+
+	privateBlock, err := ssh.MarshalPrivateKey(privateKey, comment)
+	if err != nil {
+		return nil, nil, fmt.Errorf("There was a problem encoding private key: %v", err)
+	}
+	sshPublicKey, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("There was a problem encoding public key: %v", err)
+	}
+	return pem.EncodeToMemory(privateBlock), marshalAuthorizedKey(sshPublicKey, comment), nil
+}
+
+// WriteKeyPair saves the private key readable only by the user and the public key next to it with .pub extension
+func WriteKeyPair(file string, private []byte, public []byte) error {
+	err := os.MkdirAll(filepath.Dir(file), 0700)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(file, private, 0600)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file+".pub", public, 0644)
+}
+
+func marshalAuthorizedKey(publicKey ssh.PublicKey, comment string) []byte {
+	authorizedKey := ssh.MarshalAuthorizedKey(publicKey)
+	if comment == "" {
+		return authorizedKey
+	}
+	return []byte(fmt.Sprintf("%s %s\n", strings.TrimSpace(string(authorizedKey)), comment))
+}
@@ -0,0 +1,166 @@
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// BackupExtension is added to the key pair replaced by RotateKey until the rotation is finished
+const BackupExtension = ".old"
+
+// Identity describes a key pair kept in the SSH directory
+type Identity struct {
+	Path        string
+	Type        string
+	Fingerprint string
+	Comment     string
+}
+
+// DefaultComment is the comment of generated keys
+func DefaultComment() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "loophole"
+	}
+	return fmt.Sprintf("loophole@%s", hostname)
+}
+
+// ReadPublicKey returns the public key of the identity, read from .pub file next to it,
+// or from the private key itself if it's not protected with passphrase
+func ReadPublicKey(file string) (ssh.PublicKey, string, error) {
+	content, err := ioutil.ReadFile(strings.TrimSuffix(file, ".pub") + ".pub")
+	if err == nil {
+		publicKey, comment, _, _, err := ssh.ParseAuthorizedKey(content)
+		if err != nil {
+			return nil, "", fmt.Errorf("There was a problem parsing public key of '%s': %v", file, err)
+		}
+		return publicKey, comment, nil
+	}
+
+	content, err = ioutil.ReadFile(file)
+	if err != nil {
+		return nil, "", err
+	}
+	signer, err := ssh.ParsePrivateKey(content)
+	var passphraseErr *ssh.PassphraseMissingError
+	if errors.As(err, &passphraseErr) && passphraseErr.PublicKey != nil {
+		return passphraseErr.PublicKey, "", nil
+	} else if err != nil {
+		return nil, "", fmt.Errorf("There was a problem parsing private key '%s': %v", file, err)
+	}
+	return signer.PublicKey(), "", nil
+}
+
+// Fingerprint returns SHA256 fingerprint of the identity in the format used by OpenSSH
+func Fingerprint(file string) (string, error) {
+	publicKey, _, err := ReadPublicKey(file)
+	if err != nil {
+		return "", err
+	}
+	return ssh.FingerprintSHA256(publicKey), nil
+}
+
+// ListIdentities returns key pairs found in the directory
+func ListIdentities(sshDir string) ([]Identity, error) {
+	publicKeyFiles, err := filepath.Glob(filepath.Join(sshDir, "*.pub"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(publicKeyFiles)
+
+	identities := []Identity{}
+	for _, publicKeyFile := range publicKeyFiles {
+		if strings.HasSuffix(publicKeyFile, "-cert.pub") {
+			continue
+		}
+		file := strings.TrimSuffix(publicKeyFile, ".pub")
+		if _, err := os.Stat(file); err != nil {
+			continue
+		}
+		publicKey, comment, err := ReadPublicKey(file)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, Identity{
+			Path:        file,
+			Type:        publicKey.Type(),
+			Fingerprint: ssh.FingerprintSHA256(publicKey),
+			Comment:     comment,
+		})
+	}
+	return identities, nil
+}
+
+// ImportKey copies existing private key into the destination, writing its public key next to it
+func ImportKey(source string, destination string, overwrite bool) (ssh.PublicKey, error) {
+	if !overwrite {
+		if _, err := os.Stat(destination); err == nil {
+			return nil, fmt.Errorf("Identity '%s' already exists", destination)
+		}
+	}
+	private, err := ioutil.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, comment, err := ReadPublicKey(source)
+	if err != nil {
+		return nil, err
+	}
+	err = WriteKeyPair(destination, private, marshalAuthorizedKey(publicKey, comment))
+	if err != nil {
+		return nil, fmt.Errorf("There was a problem writing identity '%s': %v", destination, err)
+	}
+	return publicKey, nil
+}
+
+// RotateKey generates new key pair in place of the old one, keeping the old pair with .old extension
+// until the rotation is either finished with RemoveRotated or reverted with RestoreRotated
+func RotateKey(oldFile string, newFile string, keyType string, bits int) (ssh.PublicKey, error) {
+	private, public, err := GenerateKeyPair(keyType, bits, DefaultComment())
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range []string{oldFile, oldFile + ".pub"} {
+		err = os.Rename(file, file+BackupExtension)
+		if err != nil && !os.IsNotExist(err) {
+			RestoreRotated(oldFile, newFile)
+			return nil, fmt.Errorf("There was a problem backing up '%s': %v", file, err)
+		}
+	}
+	err = WriteKeyPair(newFile, private, public)
+	if err != nil {
+		RestoreRotated(oldFile, newFile)
+		return nil, fmt.Errorf("There was a problem writing identity '%s': %v", newFile, err)
+	}
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey(public)
+	if err != nil {
+		return nil, err
+	}
+	return publicKey, nil
+}
+
+// RestoreRotated brings back the key pair replaced by RotateKey
+func RestoreRotated(oldFile string, newFile string) {
+	if _, err := os.Stat(oldFile + BackupExtension); err != nil {
+		return
+	}
+	os.Remove(newFile)
+	os.Remove(newFile + ".pub")
+	for _, file := range []string{oldFile, oldFile + ".pub"} {
+		os.Rename(file+BackupExtension, file)
+	}
+}
+
+// RemoveRotated removes the backup of key pair replaced by RotateKey
+func RemoveRotated(oldFile string) {
+	for _, file := range []string{oldFile, oldFile + ".pub"} {
+		os.Remove(file + BackupExtension)
+	}
+}
@@ -0,0 +1,151 @@
+package keys
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestGeneratedKeysAreUsable(t *testing.T) {
+	for keyType, expectedType := range map[string]string{
+		KeyTypeEd25519: ssh.KeyAlgoED25519,
+		KeyTypeECDSA:   ssh.KeyAlgoECDSA256,
+		KeyTypeRSA:     ssh.KeyAlgoRSA,
+	} {
+		t.Run(keyType, func(t *testing.T) {
+			bits := 0
+			if keyType == KeyTypeRSA {
+				bits = 2048
+			}
+			private, public, err := GenerateKeyPair(keyType, bits, "test")
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if !strings.Contains(string(private), "OPENSSH PRIVATE KEY") {
+				t.Fatalf("Expected private key in OpenSSH format, got: %s", private)
+			}
+			dir, err := ioutil.TempDir("", "loophole-keys")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			file := filepath.Join(dir, IdentityFileName(keyType))
+			if err := WriteKeyPair(file, private, public); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			_, publicKey, err := ParsePublicKey(file, "")
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if publicKey.Type() != expectedType {
+				t.Fatalf("Expected key type '%s', got '%s'", expectedType, publicKey.Type())
+			}
+			fingerprint, err := Fingerprint(file)
+			if err != nil || fingerprint != ssh.FingerprintSHA256(publicKey) {
+				t.Fatalf("Expected fingerprint '%s', got '%s' (%v)", ssh.FingerprintSHA256(publicKey), fingerprint, err)
+			}
+		})
+	}
+}
+
+func TestMissingIdentityIsGeneratedAsEd25519(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loophole-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := DefaultIdentityFile(dir)
+	if filepath.Base(file) != "id_ed25519" {
+		t.Fatalf("Expected id_ed25519 to be used, got '%s'", file)
+	}
+	_, publicKey, err := ParsePublicKey(file, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if publicKey.Type() != ssh.KeyAlgoED25519 {
+		t.Fatalf("Expected Ed25519 key, got '%s'", publicKey.Type())
+	}
+	if info, err := os.Stat(file); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("Expected private key readable only by the user, got: %v (%v)", info, err)
+	}
+}
+
+func TestExistingRSAIdentityIsKept(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loophole-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	private, public, _ := GenerateKeyPair(KeyTypeRSA, 2048, "")
+	WriteKeyPair(filepath.Join(dir, "id_rsa"), private, public)
+
+	if file := DefaultIdentityFile(dir); filepath.Base(file) != "id_rsa" {
+		t.Fatalf("Expected existing id_rsa to be used, got '%s'", file)
+	}
+}
+
+func TestIdentitiesAreListedAndImported(t *testing.T) {
+	source, err := ioutil.TempDir("", "loophole-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(source)
+	private, public, _ := GenerateKeyPair(KeyTypeEd25519, 0, "work laptop")
+	ioutil.WriteFile(filepath.Join(source, "key"), private, 0600)
+
+	dir, err := ioutil.TempDir("", "loophole-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	publicKey, err := ImportKey(filepath.Join(source, "key"), filepath.Join(dir, "id_ed25519"), false)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, err := ImportKey(filepath.Join(source, "key"), filepath.Join(dir, "id_ed25519"), false); err == nil {
+		t.Fatalf("Expected existing identity not to be overwritten")
+	}
+
+	identities, err := ListIdentities(dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(identities) != 1 || identities[0].Fingerprint != ssh.FingerprintSHA256(publicKey) {
+		t.Fatalf("Expected imported identity to be listed, got: %v", identities)
+	}
+	expected, _, _, _, _ := ssh.ParseAuthorizedKey(public)
+	if identities[0].Fingerprint != ssh.FingerprintSHA256(expected) {
+		t.Fatalf("Expected fingerprint of the source key, got '%s'", identities[0].Fingerprint)
+	}
+}
+
+func TestRotationCanBeReverted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loophole-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "id_ed25519")
+	private, public, _ := GenerateKeyPair(KeyTypeEd25519, 0, "")
+	WriteKeyPair(file, private, public)
+	oldFingerprint, _ := Fingerprint(file)
+
+	newKey, err := RotateKey(file, file, KeyTypeEd25519, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if fingerprint, _ := Fingerprint(file); fingerprint != ssh.FingerprintSHA256(newKey) || fingerprint == oldFingerprint {
+		t.Fatalf("Expected new key to replace the old one, got '%s'", fingerprint)
+	}
+
+	RestoreRotated(file, file)
+	if fingerprint, _ := Fingerprint(file); fingerprint != oldFingerprint {
+		t.Fatalf("Expected old key to be restored, got '%s'", fingerprint)
+	}
+}
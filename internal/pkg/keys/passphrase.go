@@ -0,0 +1,73 @@
+package keys
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/loophole/cli/internal/pkg/communication"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+// PassphraseEnvVar provides the passphrase of encrypted identity file
+const PassphraseEnvVar = "LOOPHOLE_KEY_PASSPHRASE"
+
+// decryptPrivateKey decrypts the key with passphrase taken from, in order, the passphrase file,
+// LOOPHOLE_KEY_PASSPHRASE, SSH_ASKPASS helper or the user asked through communication mechanism
+func decryptPrivateKey(privateKey []byte, file string, passphraseFile string) (ssh.Signer, error) {
+	if passphraseFile != "" {
+		content, err := ioutil.ReadFile(passphraseFile)
+		if err != nil {
+			return nil, fmt.Errorf("There was a problem reading passphrase file: %v", err)
+		}
+		return parseWithPassphrase(privateKey, strings.TrimRight(string(content), "\r\n"), fmt.Sprintf("file '%s'", passphraseFile))
+	}
+	if passphrase, ok := os.LookupEnv(PassphraseEnvVar); ok {
+		return parseWithPassphrase(privateKey, passphrase, PassphraseEnvVar)
+	}
+	if askpass := os.Getenv("SSH_ASKPASS"); askpass != "" && useAskpass() {
+		passphrase, err := runAskpass(askpass, fmt.Sprintf("Enter passphrase for key '%s': ", file))
+		if err != nil {
+			return nil, err
+		}
+		return parseWithPassphrase(privateKey, passphrase, "SSH_ASKPASS")
+	}
+
+	passphrase, err := communication.PassphraseRequest(file)
+	if err != nil {
+		return nil, fmt.Errorf("%v, provide it with %s, --key-passphrase-file or SSH_ASKPASS", err, PassphraseEnvVar)
+	}
+	return parseWithPassphrase(privateKey, passphrase, "prompt")
+}
+
+func parseWithPassphrase(privateKey []byte, passphrase string, source string) (ssh.Signer, error) {
+	signer, err := ssh.ParsePrivateKeyWithPassphrase(privateKey, []byte(passphrase))
+	if errors.Is(err, x509.IncorrectPasswordError) {
+		return nil, fmt.Errorf("Passphrase from %s doesn't match the key", source)
+	}
+	return signer, err
+}
+
+// useAskpass follows OpenSSH rules, the helper is used without terminal unless SSH_ASKPASS_REQUIRE says otherwise
+func useAskpass() bool {
+	switch os.Getenv("SSH_ASKPASS_REQUIRE") {
+	case "force", "prefer":
+		return true
+	case "never":
+		return false
+	}
+	return !term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+func runAskpass(askpass string, prompt string) (string, error) {
+	output, err := exec.Command(askpass, prompt).Output()
+	if err != nil {
+		return "", fmt.Errorf("There was a problem running SSH_ASKPASS helper: %v", err)
+	}
+	return strings.TrimRight(string(output), "\r\n"), nil
+}
@@ -0,0 +1,130 @@
+package keys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+func writeEncryptedKey(t *testing.T, dir string, passphrase string) (string, ssh.PublicKey) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKeyWithPassphrase(private, "", []byte(passphrase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "id_ed25519")
+	if err := ioutil.WriteFile(file, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	publicKey, _ := ssh.NewPublicKey(public)
+	return file, publicKey
+}
+
+// setEnv sets the environment variable, returned function restores its previous value
+func setEnv(name string, value string) func() {
+	oldValue, wasSet := os.LookupEnv(name)
+	os.Setenv(name, value)
+	return func() {
+		if wasSet {
+			os.Setenv(name, oldValue)
+		} else {
+			os.Unsetenv(name)
+		}
+	}
+}
+
+func TestPassphraseIsReadFromEnvironment(t *testing.T) {
+	defer setEnv("SSH_AUTH_SOCK", "")()
+	dir, err := ioutil.TempDir("", "loophole-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file, expectedPublicKey := writeEncryptedKey(t, dir, "secret")
+
+	defer setEnv(PassphraseEnvVar, "secret")()
+	_, publicKey, err := ParsePublicKey(file, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if ssh.FingerprintSHA256(publicKey) != ssh.FingerprintSHA256(expectedPublicKey) {
+		t.Fatalf("Expected key to be decrypted, got '%s'", ssh.FingerprintSHA256(publicKey))
+	}
+
+	defer setEnv(PassphraseEnvVar, "wrong")()
+	_, _, err = ParsePublicKey(file, "")
+	if err == nil || !strings.Contains(err.Error(), PassphraseEnvVar) {
+		t.Fatalf("Expected error naming the passphrase source, got: %v", err)
+	}
+}
+
+func TestPassphraseIsReadFromFile(t *testing.T) {
+	defer setEnv("SSH_AUTH_SOCK", "")()
+	defer setEnv(PassphraseEnvVar, "wrong")()
+	dir, err := ioutil.TempDir("", "loophole-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file, _ := writeEncryptedKey(t, dir, "secret")
+	passphraseFile := filepath.Join(filepath.Dir(file), "passphrase")
+	ioutil.WriteFile(passphraseFile, []byte("secret\n"), 0600)
+
+	if _, _, err := ParsePublicKey(file, passphraseFile); err != nil {
+		t.Fatalf("Expected passphrase file to take precedence, got: %v", err)
+	}
+}
+
+func TestPassphraseIsReadFromAskpassHelper(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Helper script requires shell")
+	}
+	defer setEnv("SSH_AUTH_SOCK", "")()
+	os.Unsetenv(PassphraseEnvVar)
+	dir, err := ioutil.TempDir("", "loophole-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file, _ := writeEncryptedKey(t, dir, "secret")
+	helper := filepath.Join(filepath.Dir(file), "askpass.sh")
+	ioutil.WriteFile(helper, []byte("#!/bin/sh\necho secret\n"), 0700)
+	defer setEnv("SSH_ASKPASS", helper)()
+	defer setEnv("SSH_ASKPASS_REQUIRE", "force")()
+
+	if _, _, err := ParsePublicKey(file, ""); err != nil {
+		t.Fatalf("Expected passphrase from helper to be used, got: %v", err)
+	}
+}
+
+func TestMissingPassphraseIsReportedWithoutTerminal(t *testing.T) {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		t.Skip("Passphrase would be asked for in terminal")
+	}
+	defer setEnv("SSH_AUTH_SOCK", "")()
+	defer setEnv("SSH_ASKPASS", "")()
+	os.Unsetenv(PassphraseEnvVar)
+	dir, err := ioutil.TempDir("", "loophole-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file, _ := writeEncryptedKey(t, dir, "secret")
+
+	_, _, err = ParsePublicKey(file, "")
+	if err == nil || !strings.Contains(err.Error(), PassphraseEnvVar) {
+		t.Fatalf("Expected error suggesting passphrase sources, got: %v", err)
+	}
+}
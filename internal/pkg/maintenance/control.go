@@ -0,0 +1,70 @@
+package maintenance
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+)
+
+type statusResponse struct {
+	Maintenance bool `json:"maintenance"`
+}
+
+// ControlHandler returns handler which allows to check and switch maintenance mode
+//
+// GET /maintenance returns current state, POST /maintenance/on and POST /maintenance/off change it
+// when authorized with the token as bearer, requests sent by browsers from other pages are rejected
+func ControlHandler(sw *Switch, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/maintenance", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		writeStatus(w, sw)
+	})
+	mux.HandleFunc("/maintenance/on", switchHandler(sw, token, true))
+	mux.HandleFunc("/maintenance/off", switchHandler(sw, token, false))
+	return mux
+}
+
+func switchHandler(sw *Switch, token string, enabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		authorization := []byte(r.Header.Get("Authorization"))
+		if r.Header.Get("Origin") != "" || subtle.ConstantTimeCompare(authorization, []byte("Bearer "+token)) != 1 {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		sw.Set(enabled)
+		writeStatus(w, sw)
+	}
+}
+
+func writeStatus(w http.ResponseWriter, sw *Switch) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statusResponse{Maintenance: sw.Enabled()})
+}
+
+// ServeControl starts control endpoint for the given switch on local address, returning the token
+// generated for this run which is required to switch maintenance mode
+func ServeControl(address string, sw *Switch) (net.Listener, string, error) {
+	buffer := make([]byte, 16)
+	if _, err := rand.Read(buffer); err != nil {
+		return nil, "", err
+	}
+	token := hex.EncodeToString(buffer)
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, "", err
+	}
+	go http.Serve(listener, ControlHandler(sw, token))
+	return listener, token, nil
+}
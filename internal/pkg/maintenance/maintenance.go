@@ -0,0 +1,141 @@
+package maintenance
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/loophole/cli/internal/pkg/communication"
+)
+
+// DefaultRetryAfter is the default value (in seconds) of Retry-After header sent with maintenance page
+const DefaultRetryAfter = 120
+
+// Switch holds the maintenance state of a single tunnel
+type Switch struct {
+	tunnelID   string
+	mutex      sync.RWMutex
+	enabled    bool
+	page       []byte
+	retryAfter int
+}
+
+var switches = make(map[string]*Switch)
+var switchesMutex sync.Mutex
+
+// ForTunnel returns maintenance switch of the given tunnel, creating it if it doesn't exist yet
+func ForTunnel(tunnelID string) *Switch {
+	switchesMutex.Lock()
+	defer switchesMutex.Unlock()
+
+	sw, ok := switches[tunnelID]
+	if !ok {
+		sw = New()
+		sw.tunnelID = tunnelID
+		switches[tunnelID] = sw
+	}
+	return sw
+}
+
+// Forget removes maintenance switch of the given tunnel
+func Forget(tunnelID string) {
+	switchesMutex.Lock()
+	defer switchesMutex.Unlock()
+
+	delete(switches, tunnelID)
+}
+
+// New creates maintenance switch with default page, turned off
+func New() *Switch {
+	return &Switch{
+		page:       []byte(fmt.Sprintf(defaultPageTemplate, logoURL)),
+		retryAfter: DefaultRetryAfter,
+	}
+}
+
+// Enabled returns whether maintenance mode is on
+func (sw *Switch) Enabled() bool {
+	sw.mutex.RLock()
+	defer sw.mutex.RUnlock()
+	return sw.enabled
+}
+
+// Set turns maintenance mode on or off, returns whether the state has changed
+func (sw *Switch) Set(enabled bool) bool {
+	sw.mutex.Lock()
+	changed := sw.enabled != enabled
+	sw.enabled = enabled
+	sw.mutex.Unlock()
+
+	if changed {
+		sw.report(enabled)
+	}
+	return changed
+}
+
+// Toggle flips maintenance mode, returns the new state
+func (sw *Switch) Toggle() bool {
+	sw.mutex.Lock()
+	sw.enabled = !sw.enabled
+	enabled := sw.enabled
+	sw.mutex.Unlock()
+
+	sw.report(enabled)
+	return enabled
+}
+
+func (sw *Switch) report(enabled bool) {
+	if enabled {
+		communication.TunnelInfo(sw.tunnelID, "Maintenance mode enabled, visitors are now getting the maintenance page")
+	} else {
+		communication.TunnelInfo(sw.tunnelID, "Maintenance mode disabled, traffic is forwarded again")
+	}
+}
+
+// SetPage replaces the page served while in maintenance mode
+func (sw *Switch) SetPage(page []byte) {
+	sw.mutex.Lock()
+	defer sw.mutex.Unlock()
+	sw.page = page
+}
+
+// LoadPage replaces the page served while in maintenance mode with the content of given file
+func (sw *Switch) LoadPage(file string) error {
+	page, err := ioutil.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("There was a problem reading maintenance page: %v", err)
+	}
+	sw.SetPage(page)
+	return nil
+}
+
+// SetRetryAfter sets the value (in seconds) of Retry-After header
+func (sw *Switch) SetRetryAfter(seconds int) {
+	sw.mutex.Lock()
+	defer sw.mutex.Unlock()
+	sw.retryAfter = seconds
+}
+
+// Handler wraps the given handler, serving the maintenance page instead while maintenance mode is on
+func (sw *Switch) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw.mutex.RLock()
+		enabled, page, retryAfter := sw.enabled, sw.page, sw.retryAfter
+		sw.mutex.RUnlock()
+
+		if !enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		if retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write(page)
+	})
+}
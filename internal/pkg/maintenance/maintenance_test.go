@@ -0,0 +1,155 @@
+package maintenance
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandlerPassesThroughWhenDisabled(t *testing.T) {
+	sw := New()
+	srv := httptest.NewServer(sw.Handler(okHandler()))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected '%d' status, got '%d'", http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestHandlerServesMaintenancePageWhenEnabled(t *testing.T) {
+	expectedPage := "back soon"
+	sw := New()
+	sw.SetPage([]byte(expectedPage))
+	sw.SetRetryAfter(30)
+	sw.Set(true)
+	srv := httptest.NewServer(sw.Handler(okHandler()))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Expected '%d' status, got '%d'", http.StatusServiceUnavailable, resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") != "30" {
+		t.Fatalf("Expected Retry-After '30', got '%s'", resp.Header.Get("Retry-After"))
+	}
+	if string(body) != expectedPage {
+		t.Fatalf("Expected page '%s', got '%s'", expectedPage, body)
+	}
+}
+
+func TestControlHandlerSwitchesMaintenanceMode(t *testing.T) {
+	sw := New()
+	srv := httptest.NewServer(ControlHandler(sw, "token"))
+	defer srv.Close()
+
+	resp, err := controlRequest(srv.URL+"/maintenance/on", "token", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := statusResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&status)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Maintenance || !sw.Enabled() {
+		t.Fatalf("Expected maintenance mode to be enabled")
+	}
+
+	resp, err = controlRequest(srv.URL+"/maintenance/off", "token", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if sw.Enabled() {
+		t.Fatalf("Expected maintenance mode to be disabled")
+	}
+}
+
+func TestControlHandlerRejectsGetOnSwitch(t *testing.T) {
+	sw := New()
+	srv := httptest.NewServer(ControlHandler(sw, "token"))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/maintenance/on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected '%d' status, got '%d'", http.StatusMethodNotAllowed, resp.StatusCode)
+	}
+	if sw.Enabled() {
+		t.Fatalf("Expected maintenance mode to stay disabled")
+	}
+}
+
+func TestControlHandlerRequiresTokenAndRejectsBrowsers(t *testing.T) {
+	sw := New()
+	srv := httptest.NewServer(ControlHandler(sw, "token"))
+	defer srv.Close()
+
+	for _, c := range []struct {
+		token  string
+		origin string
+	}{{"", ""}, {"other", ""}, {"token", "https://example.com"}} {
+		resp, err := controlRequest(srv.URL+"/maintenance/on", c.token, c.origin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("Expected '%d' status for token '%s' and origin '%s', got '%d'", http.StatusForbidden, c.token, c.origin, resp.StatusCode)
+		}
+	}
+	if sw.Enabled() {
+		t.Fatalf("Expected maintenance mode to stay disabled")
+	}
+}
+
+// controlRequest switches maintenance mode with the token, the way curl would unless origin is given
+func controlRequest(url string, token string, origin string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	return http.DefaultClient.Do(req)
+}
+
+func TestForTunnelReturnsSameSwitch(t *testing.T) {
+	defer Forget("some-tunnel")
+
+	if ForTunnel("some-tunnel") != ForTunnel("some-tunnel") {
+		t.Fatalf("Expected the same switch to be returned for the same tunnel")
+	}
+}
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
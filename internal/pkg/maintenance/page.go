@@ -0,0 +1,56 @@
+package maintenance
+
+const (
+	logoURL = "https://raw.githubusercontent.com/loophole/website/master/static/img/logo.png"
+
+	// %s is logoUrl
+	defaultPageTemplate = `<!DOCTYPE html>
+<html lang="en">
+	<head>
+	<meta charset="utf-8" />
+	<title>Back soon</title>
+	<style>
+		html {
+		height: 100%%;
+		}
+		body {
+			max-height: 100%%;
+			font-family: system-ui, -apple-system, "Segoe UI", Roboto, Ubuntu,
+				Cantarell, "Noto Sans", sans-serif, BlinkMacSystemFont, "Segoe UI",
+				Helvetica, Arial, sans-serif, "Apple Color Emoji", "Segoe UI Emoji",
+				"Segoe UI Symbol";
+			overflow-y: auto;
+		}
+		.container {
+			text-align: center;
+			width: 800px;
+			height: fit-content;
+
+			position: absolute;
+			top: 0;
+			bottom: 0;
+			left: 0;
+			right: 0;
+
+			margin: auto;
+		}
+	</style>
+	</head>
+	<body>
+	<div class="container">
+		<img
+		src="%s"
+		width="500px"
+		alt="Loophole"
+		/>
+		<h1>We'll be back soon!</h1>
+		<p>
+		This site is undergoing maintenance right now.
+		<br />
+		Please try again in a few minutes.
+		</p>
+	</div>
+	</body>
+</html>
+`
+)
@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package maintenance
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// HandleSignals toggles maintenance mode of the given switch each time SIGUSR1 is received
+func HandleSignals(sw *Switch) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGUSR1)
+
+	go func() {
+		for range c {
+			sw.Toggle()
+		}
+	}()
+}
@@ -0,0 +1,7 @@
+//go:build windows
+// +build windows
+
+package maintenance
+
+// HandleSignals is a no-op on Windows, which has no SIGUSR1
+func HandleSignals(sw *Switch) {}
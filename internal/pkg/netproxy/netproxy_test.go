@@ -0,0 +1,191 @@
+package netproxy
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// listen starts TCP server on random local port handling every connection with the handler
+func listen(t *testing.T, handler func(conn net.Conn)) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handler(conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// serveBanner starts server which greets first, like SSH server does, then echoes
+func serveBanner(t *testing.T) string {
+	return listen(t, func(conn net.Conn) {
+		conn.Write([]byte("hello\n"))
+		io.Copy(conn, conn)
+	})
+}
+
+func pipe(a net.Conn, b net.Conn) {
+	go io.Copy(a, b)
+	io.Copy(b, a)
+}
+
+// serveConnectProxy starts HTTP proxy stand-in accepting CONNECT requests with the given authorization
+func serveConnectProxy(t *testing.T, expectedAuthorization string) string {
+	return listen(t, func(conn net.Conn) {
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil {
+			return
+		}
+		if req.Method != "CONNECT" || req.Header.Get("Proxy-Authorization") != expectedAuthorization {
+			conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\n\r\n"))
+			return
+		}
+		target, err := net.Dial("tcp", req.Host)
+		if err != nil {
+			conn.Write([]byte("HTTP/1.1 502 Bad Gateway\r\n\r\n"))
+			return
+		}
+		defer target.Close()
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		pipe(conn, target)
+	})
+}
+
+// serveSOCKS5Proxy starts SOCKS5 proxy stand-in requiring username and password authentication
+func serveSOCKS5Proxy(t *testing.T, username string, password string) string {
+	return listen(t, func(conn net.Conn) {
+		reader := bufio.NewReader(conn)
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(reader, header); err != nil {
+			return
+		}
+		methods := make([]byte, header[1])
+		io.ReadFull(reader, methods)
+		conn.Write([]byte{5, 2})
+
+		version, _ := reader.ReadByte()
+		usernameLength, _ := reader.ReadByte()
+		givenUsername := make([]byte, usernameLength)
+		io.ReadFull(reader, givenUsername)
+		passwordLength, _ := reader.ReadByte()
+		givenPassword := make([]byte, passwordLength)
+		io.ReadFull(reader, givenPassword)
+		if version != 1 || string(givenUsername) != username || string(givenPassword) != password {
+			conn.Write([]byte{1, 1})
+			return
+		}
+		conn.Write([]byte{1, 0})
+
+		request := make([]byte, 4)
+		io.ReadFull(reader, request)
+		var host string
+		switch request[3] {
+		case 1:
+			ip := make([]byte, 4)
+			io.ReadFull(reader, ip)
+			host = net.IP(ip).String()
+		case 3:
+			length, _ := reader.ReadByte()
+			name := make([]byte, length)
+			io.ReadFull(reader, name)
+			host = string(name)
+		default:
+			return
+		}
+		port := make([]byte, 2)
+		io.ReadFull(reader, port)
+
+		target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))))
+		if err != nil {
+			conn.Write([]byte{5, 1, 0, 1, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		defer target.Close()
+		conn.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+		pipe(conn, target)
+	})
+}
+
+// expectBanner verifies the connection reaches the banner server through the dialer
+func expectBanner(t *testing.T, dialer Dialer, address string) {
+	conn, err := dialer.Dial("tcp", address)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	banner, err := reader.ReadString('\n')
+	if err != nil || banner != "hello\n" {
+		t.Fatalf("Expected banner to be received, got '%s': %v", banner, err)
+	}
+	conn.Write([]byte("ping\n"))
+	echo, err := reader.ReadString('\n')
+	if err != nil || echo != "ping\n" {
+		t.Fatalf("Expected data to be echoed, got '%s': %v", echo, err)
+	}
+}
+
+func TestDirectConnectionIsUsedWithoutProxy(t *testing.T) {
+	dialer, err := NewDialer("")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	expectBanner(t, dialer, serveBanner(t))
+}
+
+func TestConnectionIsTunnelledThroughHTTPProxy(t *testing.T) {
+	target := serveBanner(t)
+	proxyAddress := serveConnectProxy(t, "Basic dXNlcjpzZWNyZXQ=")
+
+	dialer, err := NewDialer("http://user:secret@" + proxyAddress)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	expectBanner(t, dialer, target)
+
+	dialer, _ = NewDialer("http://user:wrong@" + proxyAddress)
+	if _, err := dialer.Dial("tcp", target); err == nil {
+		t.Fatalf("Expected error for rejected credentials")
+	}
+}
+
+func TestConnectionIsTunnelledThroughSOCKS5Proxy(t *testing.T) {
+	target := serveBanner(t)
+	proxyAddress := serveSOCKS5Proxy(t, "user", "secret")
+
+	dialer, err := NewDialer("socks5://user:secret@" + proxyAddress)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	expectBanner(t, dialer, target)
+
+	dialer, _ = NewDialer("socks5://user:wrong@" + proxyAddress)
+	if _, err := dialer.Dial("tcp", target); err == nil {
+		t.Fatalf("Expected error for rejected credentials")
+	}
+}
+
+func TestUnsupportedProxyIsRejected(t *testing.T) {
+	for _, proxyURL := range []string{"ftp://proxy:21", "http://", "://proxy"} {
+		if _, err := NewDialer(proxyURL); err == nil {
+			t.Fatalf("Expected error for proxy '%s'", proxyURL)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package profile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/loophole/cli/internal/pkg/cache"
+)
+
+const (
+	// DefaultName is the name of the profile used when none is selected, it keeps files directly in loophole directory
+	DefaultName = "default"
+	// EnvVar selects the profile, it's overridden by --profile flag
+	EnvVar = "LOOPHOLE_PROFILE"
+
+	profilesDir  = "profiles"
+	currentFile  = "current-profile"
+	defaultsFile = "defaults.json"
+)
+
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+var active string
+var mutex sync.RWMutex
+
+// storageDir returns loophole directory, replaced in tests
+var storageDir = func() string {
+	return cache.GetLocalStorageDir("")
+}
+
+// Validate checks whether the name can be used as profile name
+func Validate(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("Invalid profile name '%s', use only letters, numbers, dots, dashes and underscores", name)
+	}
+	return nil
+}
+
+// Current returns the name of active profile, selected with Set, LOOPHOLE_PROFILE or 'account use'
+func Current() (string, error) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	if active != "" {
+		return active, nil
+	}
+	if name := os.Getenv(EnvVar); name != "" {
+		if err := Validate(name); err != nil {
+			return "", fmt.Errorf("There was a problem selecting profile from %s: %v", EnvVar, err)
+		}
+		return name, nil
+	}
+	if content, err := ioutil.ReadFile(filepath.Join(storageDir(), currentFile)); err == nil {
+		if name := strings.TrimSpace(string(content)); Validate(name) == nil {
+			return name, nil
+		}
+	}
+	return DefaultName, nil
+}
+
+// currentOrDefault returns the active profile, the error of Current is reported when the application starts
+func currentOrDefault() string {
+	name, err := Current()
+	if err != nil {
+		return DefaultName
+	}
+	return name
+}
+
+// Set activates the profile for the current process
+func Set(name string) error {
+	if err := Validate(name); err != nil {
+		return err
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	active = name
+	return nil
+}
+
+// Use makes the profile the default one for following runs
+func Use(name string) error {
+	if err := Validate(name); err != nil {
+		return err
+	}
+	err := ioutil.WriteFile(filepath.Join(storageDir(), currentFile), []byte(name+"\n"), 0600)
+	if err != nil {
+		return fmt.Errorf("There was a problem saving current profile: %v", err)
+	}
+	return nil
+}
+
+// Dir returns the directory keeping files of the profile, creating it if needed
+func Dir(name string) string {
+	dir := storageDir()
+	if name != DefaultName {
+		dir = filepath.Join(dir, profilesDir, name)
+	}
+	os.MkdirAll(dir, 0700)
+	return dir
+}
+
+// File returns the path of the file in the directory of active profile
+func File(fileName string) string {
+	return filepath.Join(Dir(currentOrDefault()), fileName)
+}
+
+// SSHDir returns the directory with SSH identity of active profile, creating it if needed
+func SSHDir() string {
+	dir := filepath.Join(Dir(currentOrDefault()), ".ssh")
+	os.MkdirAll(dir, 0700)
+	return dir
+}
+
+// List returns names of all profiles, the default one is always present
+func List() ([]string, error) {
+	names := []string{DefaultName}
+	infos, err := ioutil.ReadDir(filepath.Join(storageDir(), profilesDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("There was a problem listing profiles: %v", err)
+	}
+	for _, info := range infos {
+		if info.IsDir() && info.Name() != DefaultName && Validate(info.Name()) == nil {
+			names = append(names, info.Name())
+		}
+	}
+	sort.Strings(names[1:])
+	return names, nil
+}
+
+// Defaults returns default command line options of the profile, stored as flag name to value map
+// in defaults.json file of the profile directory
+func Defaults(name string) (map[string]string, error) {
+	defaults := map[string]string{}
+	content, err := ioutil.ReadFile(filepath.Join(Dir(name), defaultsFile))
+	if os.IsNotExist(err) {
+		return defaults, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("There was a problem reading defaults of profile '%s': %v", name, err)
+	}
+	err = json.Unmarshal(content, &defaults)
+	if err != nil {
+		return nil, fmt.Errorf("There was a problem decoding defaults of profile '%s': %v", name, err)
+	}
+	return defaults, nil
+}
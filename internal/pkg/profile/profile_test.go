@@ -0,0 +1,120 @@
+package profile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProfileSelectionPrecedence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loophole-profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	oldStorageDir := storageDir
+	defer func() {
+		storageDir = oldStorageDir
+		active = ""
+	}()
+	storageDir = func() string { return dir }
+	os.Unsetenv(EnvVar)
+	defer os.Unsetenv(EnvVar)
+
+	for _, step := range []struct {
+		selectProfile func()
+		expected      string
+	}{
+		{func() {}, DefaultName},
+		{func() { Use("persisted") }, "persisted"},
+		{func() { os.Setenv(EnvVar, "environment") }, "environment"},
+		{func() { Set("flag") }, "flag"},
+	} {
+		step.selectProfile()
+		name, err := Current()
+		if err != nil || name != step.expected {
+			t.Fatalf("Expected '%s' profile, got '%s' (%v)", step.expected, name, err)
+		}
+	}
+}
+
+func TestInvalidProfileFromEnvironmentIsReported(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loophole-profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	oldStorageDir := storageDir
+	defer func() { storageDir = oldStorageDir }()
+	storageDir = func() string { return dir }
+	Use("persisted")
+	os.Setenv(EnvVar, "work profile")
+	defer os.Unsetenv(EnvVar)
+
+	name, err := Current()
+	if err == nil || !strings.Contains(err.Error(), "work profile") {
+		t.Fatalf("Expected error naming the invalid profile, got '%s' (%v)", name, err)
+	}
+}
+
+func TestProfilesHaveSeparateDirectories(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loophole-profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	oldStorageDir := storageDir
+	defer func() {
+		storageDir = oldStorageDir
+		active = ""
+	}()
+	storageDir = func() string { return dir }
+
+	if Dir(DefaultName) != dir {
+		t.Fatalf("Expected default profile to use '%s', got '%s'", dir, Dir(DefaultName))
+	}
+	Set("work")
+	if File("tokens.enc") != filepath.Join(dir, "profiles", "work", "tokens.enc") {
+		t.Fatalf("Expected tokens of 'work' profile in its directory, got '%s'", File("tokens.enc"))
+	}
+	if _, err := os.Stat(SSHDir()); err != nil {
+		t.Fatalf("Expected SSH directory to be created, got: %v", err)
+	}
+
+	names, err := List()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(names, []string{DefaultName, "work"}) {
+		t.Fatalf("Expected default and work profiles, got: %v", names)
+	}
+}
+
+func TestProfileDefaultsAreRead(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loophole-profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	oldStorageDir := storageDir
+	defer func() {
+		storageDir = oldStorageDir
+		active = ""
+	}()
+	storageDir = func() string { return dir }
+	ioutil.WriteFile(filepath.Join(Dir("work"), defaultsFile), []byte(`{"qr": "true", "hostname": "company"}`), 0600)
+
+	defaults, err := Defaults("work")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if defaults["hostname"] != "company" || defaults["qr"] != "true" {
+		t.Fatalf("Expected defaults to be read, got: %v", defaults)
+	}
+	if err := Set("../escape"); err == nil {
+		t.Fatalf("Expected invalid profile name to be rejected")
+	}
+}
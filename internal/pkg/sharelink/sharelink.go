@@ -0,0 +1,424 @@
+package sharelink
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/loophole/cli/internal/pkg/cache"
+	"github.com/loophole/cli/internal/pkg/filelock"
+	"github.com/loophole/cli/internal/pkg/urlmaker"
+)
+
+const (
+	expiresParam      = "expires"
+	maxDownloadsParam = "downloads"
+	signatureParam    = "signature"
+	secretSize        = 32
+)
+
+var (
+	// ErrInvalidSignature is returned when the link was tampered with or signed by another tunnel
+	ErrInvalidSignature = errors.New("Invalid share link signature")
+	// ErrExpired is returned when the link expiry time has passed
+	ErrExpired = errors.New("Share link has expired")
+	// ErrDownloadLimitReached is returned when the link was already downloaded the allowed number of times
+	ErrDownloadLimitReached = errors.New("Share link download limit reached")
+)
+
+// storageDir returns the directory keeping secrets of the tunnels
+var storageDir = func() string {
+	return cache.GetLocalStorageDir("share-links")
+}
+
+// storageLockTimeout is how long to wait for other process updating the share links
+var storageLockTimeout = 10 * time.Second
+
+// running keeps the locks held by tunnels of this process until they are stopped,
+// the system releases them when the process crashes
+var running = map[string]*filelock.Lock{}
+var runningMutex sync.Mutex
+
+// Tunnel holds the signing secret of a path tunnel and downloads of its links, so links can be created
+// by another process and download limits survive tunnel restart
+type Tunnel struct {
+	SiteID    string           `json:"siteId"`
+	Domain    string           `json:"domain"`
+	Root      string           `json:"root"`
+	Secret    []byte           `json:"secret"`
+	StartedAt time.Time        `json:"startedAt"`
+	StoppedAt time.Time        `json:"stoppedAt"`
+	Links     map[string]*link `json:"links"`
+}
+
+// link is the state of link created for the tunnel, identified by its signature
+type link struct {
+	Expires   int64 `json:"expires"`
+	Downloads int   `json:"downloads"`
+}
+
+func tunnelFile(siteID string) string {
+	return filepath.Join(storageDir(), fmt.Sprintf("%s.json", siteID))
+}
+
+func runningFile(siteID string) string {
+	return filepath.Join(storageDir(), fmt.Sprintf("%s.running", siteID))
+}
+
+// markRunning locks the running file of the site for as long as the tunnel is served
+func markRunning(siteID string) error {
+	runningMutex.Lock()
+	defer runningMutex.Unlock()
+	if _, ok := running[siteID]; ok {
+		return nil
+	}
+	lock, err := filelock.Acquire(runningFile(siteID), true, 0)
+	if err == filelock.ErrTimeout {
+		return fmt.Errorf("Tunnel '%s' is already running in another process", siteID)
+	} else if err != nil {
+		return fmt.Errorf("There was a problem locking tunnel '%s': %v", siteID, err)
+	}
+	running[siteID] = lock
+	return nil
+}
+
+func unmarkRunning(siteID string) {
+	runningMutex.Lock()
+	defer runningMutex.Unlock()
+	if lock, ok := running[siteID]; ok {
+		lock.Release()
+		delete(running, siteID)
+	}
+}
+
+// isRunning returns whether some process holds the running file of the site
+func isRunning(siteID string) bool {
+	lock, err := filelock.Acquire(runningFile(siteID), false, 0)
+	if err != nil {
+		return err == filelock.ErrTimeout
+	}
+	lock.Release()
+	return false
+}
+
+// withStorageLock runs the function holding the lock of the stored tunnels, shared with other loophole processes
+func withStorageLock(fn func() error) error {
+	lock, err := filelock.Acquire(filepath.Join(storageDir(), "share-links.lock"), true, storageLockTimeout)
+	if err != nil {
+		return fmt.Errorf("There was a problem locking share links: %v", err)
+	}
+	defer lock.Release()
+	return fn()
+}
+
+// update runs the function on the stored tunnel of the site and saves it
+func update(siteID string, fn func(tunnel *Tunnel) error) error {
+	return withStorageLock(func() error {
+		tunnel, err := load(tunnelFile(siteID))
+		if err != nil {
+			return err
+		}
+		err = fn(tunnel)
+		if err != nil {
+			return err
+		}
+		return tunnel.save()
+	})
+}
+
+// Register stores the shared directory of the tunnel, reusing the secret if the same directory was shared
+// with the site before so links stay valid after tunnel restart, links of other directories stop working
+func Register(siteID string, domain string, root string) (*Tunnel, error) {
+	absoluteRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("There was a problem resolving shared directory: %v", err)
+	}
+
+	if err := markRunning(siteID); err != nil {
+		return nil, err
+	}
+	var tunnel *Tunnel
+	err = withStorageLock(func() error {
+		collectStale(time.Now())
+
+		tunnel, err = load(tunnelFile(siteID))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if os.IsNotExist(err) || tunnel.Root != absoluteRoot {
+			secret := make([]byte, secretSize)
+			if _, err := rand.Read(secret); err != nil {
+				return fmt.Errorf("There was a problem generating share link secret: %v", err)
+			}
+			tunnel = &Tunnel{SiteID: siteID, Root: absoluteRoot, Secret: secret, Links: map[string]*link{}}
+		}
+		tunnel.Domain = domain
+		tunnel.StartedAt = time.Now()
+		tunnel.StoppedAt = time.Time{}
+		return tunnel.save()
+	})
+	if err != nil {
+		unmarkRunning(siteID)
+		return nil, err
+	}
+	return tunnel, nil
+}
+
+// Stop marks the tunnel of the site as stopped, so it isn't used for new links and is removed once its links expire
+func Stop(siteID string) error {
+	defer unmarkRunning(siteID)
+	err := update(siteID, func(tunnel *Tunnel) error {
+		tunnel.StoppedAt = time.Now()
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func load(file string) (*Tunnel, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var tunnel Tunnel
+	err = json.Unmarshal(content, &tunnel)
+	if err != nil {
+		return nil, fmt.Errorf("There was a problem decoding share link secret: %v", err)
+	}
+	if tunnel.Links == nil {
+		tunnel.Links = map[string]*link{}
+	}
+	return &tunnel, nil
+}
+
+func (t *Tunnel) save() error {
+	content, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("There was a problem encoding share link secret: %v", err)
+	}
+	err = ioutil.WriteFile(tunnelFile(t.SiteID), content, 0600)
+	if err != nil {
+		return fmt.Errorf("There was a problem writing share link secret: %v", err)
+	}
+	return nil
+}
+
+func (t *Tunnel) stopped() bool {
+	return !t.StoppedAt.IsZero()
+}
+
+// collectStale forgets expired links, stops tunnels of crashed processes and removes stopped tunnels
+// without valid links, it must be called holding the storage lock
+func collectStale(now time.Time) {
+	files, err := filepath.Glob(filepath.Join(storageDir(), "*.json"))
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		tunnel, err := load(file)
+		if err != nil {
+			continue
+		}
+		changed := false
+		if !tunnel.stopped() && !isRunning(tunnel.SiteID) {
+			tunnel.StoppedAt = now
+			changed = true
+		}
+		for signature, link := range tunnel.Links {
+			if !now.Before(time.Unix(link.Expires, 0)) {
+				delete(tunnel.Links, signature)
+				changed = true
+			}
+		}
+		if tunnel.stopped() && len(tunnel.Links) == 0 {
+			os.Remove(file)
+			os.Remove(runningFile(tunnel.SiteID))
+		} else if changed {
+			tunnel.save()
+		}
+	}
+}
+
+// Find returns the most recently started running tunnel sharing the file, tunnels of crashed processes are skipped, optionally limited to the given site
+func Find(file string, siteID string) (*Tunnel, error) {
+	absoluteFile, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(storageDir(), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var found *Tunnel
+	for _, file := range files {
+		tunnel, err := load(file)
+		if err != nil || tunnel.stopped() || !isRunning(tunnel.SiteID) {
+			continue
+		}
+		if siteID != "" && tunnel.SiteID != siteID {
+			continue
+		}
+		if _, err := tunnel.relativePath(absoluteFile); err != nil {
+			continue
+		}
+		if found == nil || tunnel.StartedAt.After(found.StartedAt) {
+			found = tunnel
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("No path tunnel shares '%s', start one with 'loophole path'", file)
+	}
+	return found, nil
+}
+
+func (t *Tunnel) relativePath(absoluteFile string) (string, error) {
+	relative, err := filepath.Rel(t.Root, absoluteFile)
+	if err != nil || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("File '%s' is not inside of '%s'", absoluteFile, t.Root)
+	}
+	return "/" + filepath.ToSlash(relative), nil
+}
+
+// Link creates signed URL of the file valid for the given time, maxDownloads of 0 means no limit
+func (t *Tunnel) Link(file string, ttl time.Duration, maxDownloads int) (string, error) {
+	absoluteFile, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	stat, err := os.Stat(absoluteFile)
+	if err != nil {
+		return "", err
+	}
+	if stat.IsDir() {
+		return "", fmt.Errorf("'%s' is a directory, only files can be shared with links", file)
+	}
+	path, err := t.relativePath(absoluteFile)
+	if err != nil {
+		return "", err
+	}
+
+	expires := time.Now().Add(ttl).Unix()
+	query := url.Values{}
+	query.Set(expiresParam, strconv.FormatInt(expires, 10))
+	if maxDownloads > 0 {
+		query.Set(maxDownloadsParam, strconv.Itoa(maxDownloads))
+	}
+	signature := sign(t.Secret, path, expires, maxDownloads)
+	query.Set(signatureParam, signature)
+
+	err = update(t.SiteID, func(stored *Tunnel) error {
+		if !hmac.Equal(stored.Secret, t.Secret) {
+			return fmt.Errorf("Tunnel '%s' was restarted with another directory", t.SiteID)
+		}
+		stored.Links[signature] = &link{Expires: expires}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	linkURL := url.URL{
+		Scheme:   "https",
+		Host:     urlmaker.GetSiteFQDN(t.SiteID, t.Domain),
+		Path:     path,
+		RawQuery: query.Encode(),
+	}
+	return linkURL.String(), nil
+}
+
+func sign(secret []byte, path string, expires int64, maxDownloads int) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(fmt.Sprintf("%s\n%d\n%d", path, expires, maxDownloads)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// IsSigned returns whether the request carries a share link signature
+func IsSigned(r *http.Request) bool {
+	return r.URL.Query().Get(signatureParam) != ""
+}
+
+// Verifier checks share links of a single tunnel and counts their downloads in the stored tunnel
+type Verifier struct {
+	siteID string
+	secret []byte
+	now    func() time.Time
+	mutex  sync.Mutex
+}
+
+// NewVerifier creates verifier of links signed by the tunnel
+func NewVerifier(tunnel *Tunnel) *Verifier {
+	return &Verifier{
+		siteID: tunnel.SiteID,
+		secret: tunnel.Secret,
+		now:    time.Now,
+	}
+}
+
+// Verify checks signature, expiry and download limit of the request
+func (v *Verifier) Verify(r *http.Request) error {
+	query := r.URL.Query()
+	signature := query.Get(signatureParam)
+	expires, err := strconv.ParseInt(query.Get(expiresParam), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	maxDownloads := 0
+	if query.Get(maxDownloadsParam) != "" {
+		maxDownloads, err = strconv.Atoi(query.Get(maxDownloadsParam))
+		if err != nil || maxDownloads < 0 {
+			return ErrInvalidSignature
+		}
+	}
+
+	expected := sign(v.secret, r.URL.Path, expires, maxDownloads)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return ErrInvalidSignature
+	}
+	if !v.now().Before(time.Unix(expires, 0)) {
+		return ErrExpired
+	}
+	if maxDownloads == 0 || !isDownloadStart(r) {
+		return nil
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return update(v.siteID, func(tunnel *Tunnel) error {
+		state, ok := tunnel.Links[signature]
+		if !ok {
+			state = &link{Expires: expires}
+			tunnel.Links[signature] = state
+		}
+		if state.Downloads >= maxDownloads {
+			return ErrDownloadLimitReached
+		}
+		state.Downloads++
+		return nil
+	})
+}
+
+// isDownloadStart returns whether the request starts a new download, so resumed downloads
+// using ranges are not counted again
+func isDownloadStart(r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		return false
+	}
+	rangeHeader := r.Header.Get("Range")
+	return rangeHeader == "" || strings.HasPrefix(rangeHeader, "bytes=0-")
+}
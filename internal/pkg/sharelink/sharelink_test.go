@@ -0,0 +1,206 @@
+package sharelink
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// setupTunnel registers tunnel sharing temporary directory with a file, returned function restores the storage
+func setupTunnel(t *testing.T) (*Tunnel, string, func()) {
+	storage, err := ioutil.TempDir("", "loophole-sharelink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := ioutil.TempDir("", "loophole-sharelink-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldStorageDir := storageDir
+	storageDir = func() string { return storage }
+	cleanup := func() {
+		unmarkRunning("some-site")
+		storageDir = oldStorageDir
+		os.RemoveAll(storage)
+		os.RemoveAll(root)
+	}
+
+	file := filepath.Join(root, "report.pdf")
+	if err := ioutil.WriteFile(file, []byte("content"), 0644); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+
+	tunnel, err := Register("some-site", "loophole.site", root)
+	if err != nil {
+		cleanup()
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	return tunnel, file, cleanup
+}
+
+func linkRequest(t *testing.T, link string) *http.Request {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("Expected valid link, got: %v", err)
+	}
+	return httptest.NewRequest(http.MethodGet, parsed.RequestURI(), nil)
+}
+
+func TestLinkIsAcceptedUntilExpiry(t *testing.T) {
+	tunnel, file, cleanup := setupTunnel(t)
+	defer cleanup()
+	link, err := tunnel.Link(file, time.Hour, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	verifier := NewVerifier(tunnel)
+
+	if err := verifier.Verify(linkRequest(t, link)); err != nil {
+		t.Fatalf("Expected link to be valid, got: %v", err)
+	}
+
+	verifier.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if err := verifier.Verify(linkRequest(t, link)); err != ErrExpired {
+		t.Fatalf("Expected '%v', got: %v", ErrExpired, err)
+	}
+}
+
+func TestTamperedLinkIsRejected(t *testing.T) {
+	tunnel, file, cleanup := setupTunnel(t)
+	defer cleanup()
+	link, _ := tunnel.Link(file, time.Hour, 1)
+	verifier := NewVerifier(tunnel)
+
+	req := linkRequest(t, link)
+	query := req.URL.Query()
+	query.Set(maxDownloadsParam, "100")
+	req.URL.RawQuery = query.Encode()
+	if err := verifier.Verify(req); err != ErrInvalidSignature {
+		t.Fatalf("Expected '%v', got: %v", ErrInvalidSignature, err)
+	}
+
+	req = linkRequest(t, link)
+	req.URL.Path = "/other.pdf"
+	if err := verifier.Verify(req); err != ErrInvalidSignature {
+		t.Fatalf("Expected '%v', got: %v", ErrInvalidSignature, err)
+	}
+}
+
+func TestDownloadLimitIgnoresResumedDownloads(t *testing.T) {
+	tunnel, file, cleanup := setupTunnel(t)
+	defer cleanup()
+	link, _ := tunnel.Link(file, time.Hour, 1)
+	verifier := NewVerifier(tunnel)
+
+	if err := verifier.Verify(linkRequest(t, link)); err != nil {
+		t.Fatalf("Expected first download to be allowed, got: %v", err)
+	}
+	resumed := linkRequest(t, link)
+	resumed.Header.Set("Range", "bytes=3-")
+	if err := verifier.Verify(resumed); err != nil {
+		t.Fatalf("Expected resumed download to be allowed, got: %v", err)
+	}
+	if err := verifier.Verify(linkRequest(t, link)); err != ErrDownloadLimitReached {
+		t.Fatalf("Expected '%v', got: %v", ErrDownloadLimitReached, err)
+	}
+}
+
+func TestDownloadLimitSurvivesRestart(t *testing.T) {
+	tunnel, file, cleanup := setupTunnel(t)
+	defer cleanup()
+	link, _ := tunnel.Link(file, time.Hour, 1)
+	if err := NewVerifier(tunnel).Verify(linkRequest(t, link)); err != nil {
+		t.Fatalf("Expected first download to be allowed, got: %v", err)
+	}
+
+	restarted, err := Register(tunnel.SiteID, tunnel.Domain, tunnel.Root)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := NewVerifier(restarted).Verify(linkRequest(t, link)); err != ErrDownloadLimitReached {
+		t.Fatalf("Expected '%v' after restart, got: %v", ErrDownloadLimitReached, err)
+	}
+}
+
+func TestSharingOtherDirectoryInvalidatesLinks(t *testing.T) {
+	tunnel, file, cleanup := setupTunnel(t)
+	defer cleanup()
+	link, _ := tunnel.Link(file, time.Hour, 0)
+
+	other, err := Register(tunnel.SiteID, tunnel.Domain, filepath.Dir(tunnel.Root))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := NewVerifier(other).Verify(linkRequest(t, link)); err != ErrInvalidSignature {
+		t.Fatalf("Expected '%v' for link of previous share, got: %v", ErrInvalidSignature, err)
+	}
+}
+
+func TestStoppedTunnelIsRemovedOnceLinksExpire(t *testing.T) {
+	tunnel, file, cleanup := setupTunnel(t)
+	defer cleanup()
+	if _, err := tunnel.Link(file, time.Hour, 0); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := Stop(tunnel.SiteID); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, err := Find(file, ""); err == nil {
+		t.Fatalf("Expected stopped tunnel not to be used for new links")
+	}
+
+	collectStale(time.Now())
+	if _, err := os.Stat(tunnelFile(tunnel.SiteID)); err != nil {
+		t.Fatalf("Expected tunnel with valid links to be kept, got: %v", err)
+	}
+	collectStale(time.Now().Add(2 * time.Hour))
+	if _, err := os.Stat(tunnelFile(tunnel.SiteID)); !os.IsNotExist(err) {
+		t.Fatalf("Expected stopped tunnel to be removed once its links expired, got: %v", err)
+	}
+}
+
+func TestTunnelOfCrashedProcessIsNotUsed(t *testing.T) {
+	tunnel, file, cleanup := setupTunnel(t)
+	defer cleanup()
+	// the lock is released by the system when the process crashes
+	unmarkRunning(tunnel.SiteID)
+
+	if _, err := Find(file, ""); err == nil {
+		t.Fatalf("Expected tunnel of crashed process not to be used for new links")
+	}
+	collectStale(time.Now())
+	stored, err := load(tunnelFile(tunnel.SiteID))
+	if err == nil && !stored.stopped() {
+		t.Fatalf("Expected tunnel of crashed process to be stopped")
+	}
+}
+
+func TestRegisterKeepsSecretOfTheSite(t *testing.T) {
+	tunnel, file, cleanup := setupTunnel(t)
+	defer cleanup()
+
+	again, err := Register(tunnel.SiteID, tunnel.Domain, tunnel.Root)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if string(again.Secret) != string(tunnel.Secret) {
+		t.Fatalf("Expected secret to be reused for the same site")
+	}
+
+	found, err := Find(file, "")
+	if err != nil {
+		t.Fatalf("Expected tunnel sharing the file to be found, got: %v", err)
+	}
+	if found.SiteID != tunnel.SiteID {
+		t.Fatalf("Expected site '%s', got '%s'", tunnel.SiteID, found.SiteID)
+	}
+	if _, err := Find(os.TempDir(), ""); err == nil {
+		t.Fatalf("Expected error for path outside of shared directory")
+	}
+}
@@ -0,0 +1,202 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/loophole/cli/config"
+	authModels "github.com/loophole/cli/internal/pkg/token/models"
+)
+
+const callbackPath = "/callback"
+
+// browserLoginTimeout is how long the callback server waits for the user to finish logging in
+var browserLoginTimeout = 5 * time.Minute
+
+const callbackPageTemplate = `<!DOCTYPE html>
+<html lang="en">
+	<head>
+	<meta charset="utf-8" />
+	<title>Loophole</title>
+	</head>
+	<body style="font-family: sans-serif; text-align: center; padding: 48px;">
+	<h1>%s</h1>
+	<p>%s</p>
+	</body>
+</html>
+`
+
+// BrowserLogin is the authorization code flow with PKCE, finished by the browser redirecting to local callback server
+type BrowserLogin struct {
+	// AuthorizationURL is the page which has to be opened in the browser
+	AuthorizationURL string
+
+	verifier    string
+	state       string
+	redirectURI string
+	listener    net.Listener
+	result      chan callbackResult
+	finished    sync.Once
+}
+
+type callbackResult struct {
+	tokens *authModels.TokenSpec
+	err    error
+}
+
+// StartBrowserLogin starts the callback server on loopback interface and prepares the authorization URL
+func StartBrowserLogin() (*BrowserLogin, error) {
+	verifier, err := randomString(32)
+	if err != nil {
+		return nil, fmt.Errorf("There was a problem generating code verifier: %v", err)
+	}
+	state, err := randomString(16)
+	if err != nil {
+		return nil, fmt.Errorf("There was a problem generating login state: %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("There was a problem starting login callback server: %v", err)
+	}
+
+	bl := &BrowserLogin{
+		verifier:    verifier,
+		state:       state,
+		redirectURI: fmt.Sprintf("http://%s%s", listener.Addr().String(), callbackPath),
+		listener:    listener,
+		result:      make(chan callbackResult, 1),
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {config.Config.OAuth.ClientID},
+		"redirect_uri":          {bl.redirectURI},
+		"scope":                 {config.Config.OAuth.Scope},
+		"audience":              {config.Config.OAuth.Audience},
+		"state":                 {state},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	bl.AuthorizationURL = fmt.Sprintf("%s?%s", config.Config.OAuth.AuthorizeURL, query.Encode())
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(callbackPath, bl.handleCallback)
+	go http.Serve(listener, mux)
+	return bl, nil
+}
+
+func (bl *BrowserLogin) handleCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("state") != bl.state {
+		http.Error(w, "Invalid login state", http.StatusBadRequest)
+		return
+	}
+
+	handled := false
+	bl.finished.Do(func() {
+		handled = true
+		var result callbackResult
+		switch {
+		case query.Get("error") != "":
+			result.err = fmt.Errorf("Authorization failed: %s %s", query.Get("error"), query.Get("error_description"))
+		case query.Get("code") == "":
+			result.err = errors.New("Authorization server didn't return the authorization code")
+		default:
+			result.tokens, result.err = bl.exchangeCode(query.Get("code"))
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if result.err != nil {
+			fmt.Fprintf(w, callbackPageTemplate, "Login failed", "Please go back to the terminal to see the details.")
+		} else {
+			fmt.Fprintf(w, callbackPageTemplate, "Logged in", "You can close this window and go back to the terminal.")
+		}
+		bl.result <- result
+	})
+	if !handled {
+		http.Error(w, "Login was already finished", http.StatusConflict)
+	}
+}
+
+// Wait waits for the browser to return the authorization code exchanged for tokens
+func (bl *BrowserLogin) Wait(quitChannel <-chan bool) (*authModels.TokenSpec, error) {
+	defer bl.Close()
+
+	select {
+	case result := <-bl.result:
+		return result.tokens, result.err
+	case <-quitChannel:
+		return nil, fmt.Errorf("Login operation aborted")
+	case <-time.After(browserLoginTimeout):
+		return nil, fmt.Errorf("Login wasn't finished within %s, please try again", browserLoginTimeout)
+	}
+}
+
+// Close stops the callback server
+func (bl *BrowserLogin) Close() error {
+	return bl.listener.Close()
+}
+
+func (bl *BrowserLogin) exchangeCode(code string) (*authModels.TokenSpec, error) {
+	payload := strings.NewReader(url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {config.Config.OAuth.ClientID},
+		"code":          {code},
+		"code_verifier": {bl.verifier},
+		"redirect_uri":  {bl.redirectURI},
+	}.Encode())
+
+	req, err := http.NewRequest("POST", config.Config.OAuth.TokenURL, payload)
+	if err != nil {
+		return nil, fmt.Errorf("There was a problem creating HTTP POST request for token")
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("There was a problem executing request for token: %v", err)
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("There was a problem reading token response body")
+	}
+
+	if res.StatusCode >= 400 && res.StatusCode < 500 {
+		var jsonResponseBody authModels.AuthError
+		err := json.Unmarshal(body, &jsonResponseBody)
+		if err != nil {
+			return nil, fmt.Errorf("There was a problem decoding token response body")
+		}
+		return nil, fmt.Errorf("Exchanging authorization code failed: %s %s", jsonResponseBody.Error, jsonResponseBody.ErrorDescription)
+	} else if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, fmt.Errorf("Unexpected response from authorization server: %s", body)
+	}
+
+	var jsonResponseBody authModels.TokenSpec
+	err = json.Unmarshal(body, &jsonResponseBody)
+	if err != nil {
+		return nil, fmt.Errorf("There was a problem decoding token response body")
+	}
+	return &jsonResponseBody, nil
+}
+
+func randomString(size int) (string, error) {
+	buffer := make([]byte, size)
+	_, err := rand.Read(buffer)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buffer), nil
+}
@@ -0,0 +1,149 @@
+package token
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/loophole/cli/config"
+)
+
+// newAuthorizationServer starts fake authorization server which approves every login immediately
+func newAuthorizationServer() *httptest.Server {
+	challenges := map[string]string{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("code_challenge_method") != "S256" || !strings.HasPrefix(query.Get("redirect_uri"), "http://127.0.0.1:") {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		challenges["code-1"] = query.Get("code_challenge")
+		redirect := fmt.Sprintf("%s?code=code-1&state=%s", query.Get("redirect_uri"), url.QueryEscape(query.Get("state")))
+		http.Redirect(w, r, redirect, http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("grant_type") != "authorization_code" || challenges[r.PostForm.Get("code")] != base64.RawURLEncoding.EncodeToString(verifier[:]) {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"error": "invalid_grant", "error_description": "Invalid authorization code"}`)
+			return
+		}
+		fmt.Fprint(w, `{"access_token": "access", "refresh_token": "refresh", "expires_in": 3600}`)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestBrowserLoginExchangesCodeWithVerifier(t *testing.T) {
+	srv := newAuthorizationServer()
+	defer srv.Close()
+	oldOAuth := config.Config.OAuth
+	defer func() { config.Config.OAuth = oldOAuth }()
+	config.Config.OAuth.AuthorizeURL = srv.URL + "/authorize"
+	config.Config.OAuth.TokenURL = srv.URL + "/token"
+
+	login, err := StartBrowserLogin()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	res, err := http.Get(login.AuthorizationURL)
+	if err != nil {
+		t.Fatalf("Expected browser to be redirected to callback, got: %v", err)
+	}
+	res.Body.Close()
+
+	token, err := login.Wait(make(chan bool))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if token.AccessToken != "access" || token.RefreshToken != "refresh" {
+		t.Fatalf("Expected tokens to be obtained, got: %v", token)
+	}
+}
+
+func TestBrowserLoginRejectsForeignState(t *testing.T) {
+	srv := newAuthorizationServer()
+	defer srv.Close()
+	oldOAuth := config.Config.OAuth
+	defer func() { config.Config.OAuth = oldOAuth }()
+	config.Config.OAuth.AuthorizeURL = srv.URL + "/authorize"
+	config.Config.OAuth.TokenURL = srv.URL + "/token"
+
+	login, err := StartBrowserLogin()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	defer login.Close()
+	res, err := http.Get(login.redirectURI + "?code=code-1&state=forged")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, res.StatusCode)
+	}
+	select {
+	case result := <-login.result:
+		t.Fatalf("Expected callback with foreign state to be ignored, got: %v", result)
+	default:
+	}
+}
+
+func TestBrowserLoginReportsDeniedAuthorization(t *testing.T) {
+	srv := newAuthorizationServer()
+	defer srv.Close()
+	oldOAuth := config.Config.OAuth
+	defer func() { config.Config.OAuth = oldOAuth }()
+	config.Config.OAuth.AuthorizeURL = srv.URL + "/authorize"
+	config.Config.OAuth.TokenURL = srv.URL + "/token"
+
+	login, err := StartBrowserLogin()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	res, err := http.Get(fmt.Sprintf("%s?error=access_denied&state=%s", login.redirectURI, url.QueryEscape(login.state)))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	res.Body.Close()
+
+	_, err = login.Wait(make(chan bool))
+	if err == nil || !strings.Contains(err.Error(), "access_denied") {
+		t.Fatalf("Expected denied authorization to be reported, got: %v", err)
+	}
+}
+
+func TestBrowserLoginPageReportsFailedExchange(t *testing.T) {
+	srv := newAuthorizationServer()
+	defer srv.Close()
+	oldOAuth := config.Config.OAuth
+	defer func() { config.Config.OAuth = oldOAuth }()
+	config.Config.OAuth.AuthorizeURL = srv.URL + "/authorize"
+	config.Config.OAuth.TokenURL = srv.URL + "/token"
+
+	login, err := StartBrowserLogin()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	res, err := http.Get(fmt.Sprintf("%s?code=unknown&state=%s", login.redirectURI, url.QueryEscape(login.state)))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	page, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if !strings.Contains(string(page), "Login failed") {
+		t.Fatalf("Expected page to report failed login, got: %s", page)
+	}
+
+	_, err = login.Wait(make(chan bool))
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("Expected failed exchange to be reported, got: %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package token
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	authModels "github.com/loophole/cli/internal/pkg/token/models"
+)
+
+const (
+	// TokenEnvVar provides the access token without logging in, e.g. in CI
+	TokenEnvVar = "LOOPHOLE_TOKEN"
+	// RefreshTokenEnvVar provides the refresh token without logging in, used to obtain access tokens when needed
+	RefreshTokenEnvVar = "LOOPHOLE_REFRESH_TOKEN"
+)
+
+// InvalidTokenError is returned when the token provided non-interactively can't be used
+type InvalidTokenError struct {
+	Source string
+	Err    error
+}
+
+func (e *InvalidTokenError) Error() string {
+	return fmt.Sprintf("The token provided with %s is invalid: %v", e.Source, e.Err)
+}
+
+func (e *InvalidTokenError) Unwrap() error {
+	return e.Err
+}
+
+// envStore keeps the tokens provided with environment variables in memory,
+// the ones obtained by refreshing them are never written to disk
+type envStore struct {
+	mutex sync.Mutex
+	token *authModels.TokenSpec
+	err   error
+}
+
+// newEnvStore returns the store of tokens from environment variables, nil if none is set
+func newEnvStore() *envStore {
+	token := &authModels.TokenSpec{
+		AccessToken:  strings.TrimSpace(os.Getenv(TokenEnvVar)),
+		RefreshToken: strings.TrimSpace(os.Getenv(RefreshTokenEnvVar)),
+	}
+	if token.AccessToken == "" && token.RefreshToken == "" {
+		return nil
+	}
+	source := TokenEnvVar
+	if token.AccessToken == "" {
+		source = RefreshTokenEnvVar
+	}
+	return &envStore{
+		token: token,
+		err:   validateTokens(token, source),
+	}
+}
+
+func (es *envStore) Name() string {
+	return "environment variables"
+}
+
+func (es *envStore) Load() (*authModels.TokenSpec, error) {
+	es.mutex.Lock()
+	defer es.mutex.Unlock()
+	if es.err != nil {
+		return nil, es.err
+	}
+	token := *es.token
+	return &token, nil
+}
+
+func (es *envStore) Save(token *authModels.TokenSpec) error {
+	es.mutex.Lock()
+	defer es.mutex.Unlock()
+	saved := *token
+	es.token = &saved
+	es.err = nil
+	return nil
+}
+
+func (es *envStore) Delete() error {
+	return fmt.Errorf("Tokens provided with %s or %s can't be removed, unset the variables instead", TokenEnvVar, RefreshTokenEnvVar)
+}
+
+// ParseTokens reads tokens passed non-interactively, either as JSON object with 'access_token' and 'refresh_token'
+// fields or as a single token, which is treated as access token when it's a JWT and as refresh token otherwise
+func ParseTokens(content string, source string) (*authModels.TokenSpec, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, &InvalidTokenError{Source: source, Err: errors.New("no token given")}
+	}
+	var token authModels.TokenSpec
+	if strings.HasPrefix(content, "{") {
+		err := json.Unmarshal([]byte(content), &token)
+		if err != nil {
+			return nil, &InvalidTokenError{Source: source, Err: fmt.Errorf("cannot decode tokens: %v", err)}
+		}
+	} else if strings.Count(content, ".") == 2 {
+		token.AccessToken = content
+	} else {
+		token.RefreshToken = content
+	}
+	return &token, validateTokens(&token, source)
+}
+
+// validateTokens checks whether the access token can be used or, if it's missing or expired, whether it can be refreshed
+func validateTokens(token *authModels.TokenSpec, source string) error {
+	if token.AccessToken == "" {
+		if token.RefreshToken == "" {
+			return &InvalidTokenError{Source: source, Err: errors.New("neither access token nor refresh token given")}
+		}
+		return nil
+	}
+	var claims struct {
+		ExpiresAt int64 `json:"exp"`
+	}
+	err := decodeJWTClaims(token.AccessToken, &claims)
+	if err != nil {
+		return &InvalidTokenError{Source: source, Err: fmt.Errorf("cannot decode access token: %v", err)}
+	}
+	if claims.ExpiresAt != 0 && token.RefreshToken == "" && !now().Before(time.Unix(claims.ExpiresAt, 0)) {
+		return &InvalidTokenError{Source: source, Err: fmt.Errorf("access token expired at %s", time.Unix(claims.ExpiresAt, 0).Format(time.RFC1123))}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package token
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/loophole/cli/config"
+)
+
+func testJWT(expiresAt time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp": %d}`, expiresAt.Unix())))
+	return "header." + payload + ".signature"
+}
+
+func TestEnvironmentRefreshTokenIsUsedInMemory(t *testing.T) {
+	os.Setenv(RefreshTokenEnvVar, "refresh-0")
+	defer os.Unsetenv(RefreshTokenEnvVar)
+	oldStore := currentStore
+	defer func() { currentStore = oldStore }()
+	currentStore = newEnvStore()
+	srv, refreshes := newTokenServer()
+	defer srv.Close()
+	oldTokenURL := config.Config.OAuth.TokenURL
+	defer func() { config.Config.OAuth.TokenURL = oldTokenURL }()
+	config.Config.OAuth.TokenURL = srv.URL
+
+	accessToken, err := GetAccessToken()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if accessToken != "access-1" || *refreshes != 1 {
+		t.Fatalf("Expected access token to be obtained with refresh token, got '%s' after %d refreshes", accessToken, *refreshes)
+	}
+	if accessToken, _ := GetAccessToken(); accessToken != "access-1" || *refreshes != 1 {
+		t.Fatalf("Expected refreshed token to be kept in memory, got '%s' after %d refreshes", accessToken, *refreshes)
+	}
+	if err := DeleteTokens(); err == nil {
+		t.Fatalf("Expected tokens from environment not to be removable")
+	}
+}
+
+func TestInvalidEnvironmentTokenIsReported(t *testing.T) {
+	for name, accessToken := range map[string]string{
+		"malformed": "not-a-token",
+		"expired":   testJWT(time.Now().Add(-time.Minute)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			os.Setenv(TokenEnvVar, accessToken)
+			defer os.Unsetenv(TokenEnvVar)
+			oldStore := currentStore
+			defer func() { currentStore = oldStore }()
+			currentStore = newEnvStore()
+
+			if !IsTokenSaved() {
+				t.Fatalf("Expected invalid token to be reported on use, not as missing")
+			}
+			_, err := GetAccessToken()
+			var invalidTokenErr *InvalidTokenError
+			if !errors.As(err, &invalidTokenErr) || invalidTokenErr.Source != TokenEnvVar {
+				t.Fatalf("Expected invalid token error, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoginWithRejectedRefreshTokenFails(t *testing.T) {
+	oldStore := currentStore
+	defer func() { currentStore = oldStore }()
+	currentStore = newTestStore(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"error": "invalid_grant", "error_description": "Unknown or invalid refresh token."}`)
+	}))
+	defer srv.Close()
+	oldTokenURL := config.Config.OAuth.TokenURL
+	config.Config.OAuth.TokenURL = srv.URL
+	defer func() { config.Config.OAuth.TokenURL = oldTokenURL }()
+
+	token, err := ParseTokens("revoked-refresh-token\n", "stdin")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	_, err = LoginWithToken(token, "stdin")
+	var invalidTokenErr *InvalidTokenError
+	if !errors.As(err, &invalidTokenErr) {
+		t.Fatalf("Expected invalid token error, got: %v", err)
+	}
+	if IsTokenSaved() {
+		t.Fatalf("Expected rejected token not to be saved")
+	}
+}
+
+func TestParseTokens(t *testing.T) {
+	accessToken := testJWT(time.Now().Add(time.Hour))
+
+	token, err := ParseTokens(accessToken, "stdin")
+	if err != nil || token.AccessToken != accessToken || token.RefreshToken != "" {
+		t.Fatalf("Expected JWT to be read as access token, got '%v' (%v)", token, err)
+	}
+	token, err = ParseTokens(fmt.Sprintf(`{"access_token": "%s", "refresh_token": "refresh"}`, accessToken), "stdin")
+	if err != nil || token.AccessToken != accessToken || token.RefreshToken != "refresh" {
+		t.Fatalf("Expected both tokens to be read from JSON, got '%v' (%v)", token, err)
+	}
+	if _, err := ParseTokens("  ", "stdin"); err == nil {
+		t.Fatalf("Expected empty input to be rejected")
+	}
+}
@@ -0,0 +1,253 @@
+package token
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	authModels "github.com/loophole/cli/internal/pkg/token/models"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	keySize          = 32
+	saltSize         = 16
+	kdfScrypt        = "scrypt"
+	kdfMachineSecret = "machine-secret"
+
+	machineSecretFile = "machine.key"
+)
+
+// machineSecret returns the key used when there is no passphrase, replaced in tests
+var machineSecret = loadMachineSecret
+
+// encryptedTokens is the content of the tokens file
+type encryptedTokens struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Salt       []byte `json:"salt,omitempty"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// derivedKey is the key derived last time, kept so that scrypt doesn't run on every load and save
+type derivedKey struct {
+	kdf  string
+	salt []byte
+	key  []byte
+}
+
+// fileStore keeps the tokens in a file encrypted with AES-GCM, the key is derived from passphrase or,
+// when there is none, a random machine secret kept next to the tokens and readable only by the user
+type fileStore struct {
+	path       string
+	secretPath string
+	passphrase string
+
+	derivedMutex sync.Mutex
+	derived      *derivedKey
+}
+
+func newFileStore(path string, passphrase string) *fileStore {
+	return &fileStore{
+		path:       path,
+		secretPath: filepath.Join(filepath.Dir(path), machineSecretFile),
+		passphrase: passphrase,
+	}
+}
+
+func (fs *fileStore) Name() string {
+	return "encrypted file"
+}
+
+func (fs *fileStore) Load() (*authModels.TokenSpec, error) {
+	content, err := ioutil.ReadFile(fs.path)
+	if os.IsNotExist(err) {
+		return nil, ErrTokenNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("There was a problem reading tokens file: %v", err)
+	}
+	var encrypted encryptedTokens
+	err = json.Unmarshal(content, &encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("There was a problem decoding tokens file: %v", err)
+	}
+	if encrypted.KDF == kdfScrypt && fs.passphrase == "" {
+		return nil, fmt.Errorf("Tokens file is protected with passphrase, set it with %s", PassphraseEnvVar)
+	}
+
+	key, err := fs.key(encrypted.KDF, encrypted.Salt, false)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, encrypted.Nonce, encrypted.Ciphertext, nil)
+	if err != nil {
+		return nil, errors.New("There was a problem decrypting tokens file, the passphrase or machine secret doesn't match")
+	}
+
+	var token authModels.TokenSpec
+	err = json.Unmarshal(plaintext, &token)
+	if err != nil {
+		return nil, fmt.Errorf("There was a problem decoding tokens: %v", err)
+	}
+	return &token, nil
+}
+
+func (fs *fileStore) Save(token *authModels.TokenSpec) error {
+	plaintext, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("There was a problem encoding tokens: %v", err)
+	}
+
+	encrypted := encryptedTokens{Version: 1, KDF: kdfMachineSecret}
+	if fs.passphrase != "" {
+		encrypted.KDF = kdfScrypt
+		encrypted.Salt, err = fs.salt()
+		if err != nil {
+			return err
+		}
+	}
+	key, err := fs.key(encrypted.KDF, encrypted.Salt, true)
+	if err != nil {
+		return err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+	encrypted.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(encrypted.Nonce); err != nil {
+		return fmt.Errorf("There was a problem generating nonce: %v", err)
+	}
+	encrypted.Ciphertext = aead.Seal(nil, encrypted.Nonce, plaintext, nil)
+
+	content, err := json.Marshal(encrypted)
+	if err != nil {
+		return fmt.Errorf("There was a problem encoding tokens file: %v", err)
+	}
+	return writePrivateFile(fs.path, content)
+}
+
+func (fs *fileStore) Delete() error {
+	err := os.Remove(fs.path)
+	if os.IsNotExist(err) {
+		return ErrTokenNotFound
+	} else if err != nil {
+		return fmt.Errorf("There was a problem removing tokens file: %v", err)
+	}
+	return nil
+}
+
+// salt returns the salt of the last derived passphrase key, so that saving doesn't derive the key again, or a new one
+func (fs *fileStore) salt() ([]byte, error) {
+	fs.derivedMutex.Lock()
+	defer fs.derivedMutex.Unlock()
+	if fs.derived != nil && fs.derived.kdf == kdfScrypt {
+		return fs.derived.salt, nil
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("There was a problem generating salt: %v", err)
+	}
+	return salt, nil
+}
+
+// key derives the key of the tokens file, reusing the last derived one when the salt is the same
+func (fs *fileStore) key(kdf string, salt []byte, create bool) ([]byte, error) {
+	fs.derivedMutex.Lock()
+	defer fs.derivedMutex.Unlock()
+	if fs.derived != nil && fs.derived.kdf == kdf && bytes.Equal(fs.derived.salt, salt) {
+		return fs.derived.key, nil
+	}
+
+	var key []byte
+	var err error
+	switch kdf {
+	case kdfScrypt:
+		key, err = scrypt.Key([]byte(fs.passphrase), salt, 1<<15, 8, 1, keySize)
+		if err != nil {
+			return nil, fmt.Errorf("There was a problem deriving tokens key: %v", err)
+		}
+	case kdfMachineSecret:
+		key, err = machineSecret(fs.secretPath, create)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported tokens file encryption '%s'", kdf)
+	}
+	fs.derived = &derivedKey{kdf: kdf, salt: salt, key: key}
+	return key, nil
+}
+
+// loadMachineSecret returns random key kept in a file readable only by the user, creating it if requested
+func loadMachineSecret(path string, create bool) ([]byte, error) {
+	secret, err := ioutil.ReadFile(path)
+	if err == nil && len(secret) == keySize {
+		return secret, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("There was a problem reading machine secret: %v", err)
+	}
+	if !create {
+		return nil, errors.New("Machine secret used to encrypt tokens is missing")
+	}
+
+	secret = make([]byte, keySize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("There was a problem generating machine secret: %v", err)
+	}
+	if err := writePrivateFile(path, secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("There was a problem creating tokens cipher: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// writePrivateFile writes the file readable only by the user, replacing the previous content at once
+// so that other processes never read partially written file
+func writePrivateFile(path string, content []byte) error {
+	tempFile, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("There was a problem writing '%s': %v", path, err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	err = tempFile.Chmod(0600)
+	if err == nil {
+		_, err = tempFile.Write(content)
+	}
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	closeErr := tempFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempFile.Name(), path)
+	}
+	if err != nil {
+		return fmt.Errorf("There was a problem writing '%s': %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package token
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/loophole/cli/internal/pkg/profile"
+	authModels "github.com/loophole/cli/internal/pkg/token/models"
+	"github.com/zalando/go-keyring"
+)
+
+const (
+	keyringService = "loophole"
+	keyringUser    = "tokens"
+)
+
+// keyringStore keeps the tokens in the system keyring (Secret Service, macOS Keychain or Windows Credential Manager)
+type keyringStore struct {
+	service     string
+	user        string
+	profileName string
+}
+
+// newKeyringStore returns the store of given profile, the default one keeps the entry used by older versions
+func newKeyringStore(profileName string) *keyringStore {
+	user := keyringUser
+	if profileName != profile.DefaultName {
+		user = fmt.Sprintf("%s@%s", keyringUser, profileName)
+	}
+	return &keyringStore{
+		service:     keyringService,
+		user:        user,
+		profileName: profileName,
+	}
+}
+
+func (ks *keyringStore) Name() string {
+	return "system keyring"
+}
+
+func (ks *keyringStore) Load() (*authModels.TokenSpec, error) {
+	secret, err := keyring.Get(ks.service, ks.user)
+	if err == keyring.ErrNotFound {
+		return nil, ErrTokenNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("There was a problem reading tokens from keyring: %v", err)
+	}
+	var token authModels.TokenSpec
+	err = json.Unmarshal([]byte(secret), &token)
+	if err != nil {
+		return nil, fmt.Errorf("There was a problem decoding tokens: %v", err)
+	}
+	return &token, nil
+}
+
+func (ks *keyringStore) Save(token *authModels.TokenSpec) error {
+	secret, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("There was a problem encoding tokens: %v", err)
+	}
+	err = keyring.Set(ks.service, ks.user, string(secret))
+	if err != nil {
+		return fmt.Errorf("There was a problem writing tokens to keyring: %v", err)
+	}
+	return nil
+}
+
+func (ks *keyringStore) Delete() error {
+	err := keyring.Delete(ks.service, ks.user)
+	if err == keyring.ErrNotFound {
+		return ErrTokenNotFound
+	} else if err != nil {
+		return fmt.Errorf("There was a problem removing tokens from keyring: %v", err)
+	}
+	return nil
+}
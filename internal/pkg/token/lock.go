@@ -0,0 +1,52 @@
+package token
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/loophole/cli/internal/pkg/filelock"
+	"github.com/loophole/cli/internal/pkg/profile"
+)
+
+// lockTimeout is how long to wait for other process to finish updating the tokens
+var lockTimeout = 30 * time.Second
+
+// lockable is implemented by stores shared between processes, the advisory lock
+// is kept in a separate file so that the stored tokens can be replaced with rename
+type lockable interface {
+	lockPath() string
+}
+
+func (fs *fileStore) lockPath() string {
+	return fs.path + ".lock"
+}
+
+func (ks *keyringStore) lockPath() string {
+	return filepath.Join(profile.Dir(ks.profileName), "tokens.lock")
+}
+
+func (fs *fallbackStore) lockPath() string {
+	if secondary, ok := fs.secondary.(lockable); ok {
+		return secondary.lockPath()
+	}
+	return ""
+}
+
+// withLock runs the function holding the lock of the store, shared for reading and exclusive for updating the tokens
+func withLock(store Store, exclusive bool, fn func() error) error {
+	locked, ok := store.(lockable)
+	if !ok || locked.lockPath() == "" {
+		return fn()
+	}
+
+	lock, err := filelock.Acquire(locked.lockPath(), exclusive, lockTimeout)
+	if err == filelock.ErrTimeout {
+		return fmt.Errorf("Timed out waiting for other loophole process to finish updating tokens")
+	} else if err != nil {
+		return fmt.Errorf("There was a problem locking tokens: %v", err)
+	}
+	defer lock.Release()
+
+	return fn()
+}
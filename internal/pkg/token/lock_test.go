@@ -0,0 +1,91 @@
+package token
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/loophole/cli/config"
+	authModels "github.com/loophole/cli/internal/pkg/token/models"
+)
+
+const helperStoreEnvVar = "LOOPHOLE_TEST_TOKENS_DIR"
+const helperTokenURLEnvVar = "LOOPHOLE_TEST_TOKEN_URL"
+
+// TestHelperRefreshProcess is run as a separate process by TestRefreshIsSharedBetweenProcesses
+func TestHelperRefreshProcess(t *testing.T) {
+	dir := os.Getenv(helperStoreEnvVar)
+	if dir == "" {
+		t.Skip("Only run as helper process")
+	}
+	currentStore = newFileStore(filepath.Join(dir, "tokens.enc"), "")
+	config.Config.OAuth.TokenURL = os.Getenv(helperTokenURLEnvVar)
+
+	accessToken, err := RefreshAccessToken("access-0")
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("token: %s\n", accessToken)
+}
+
+func TestRefreshIsSharedBetweenProcesses(t *testing.T) {
+	store := newTestStore(t)
+	oldStore := currentStore
+	defer func() { currentStore = oldStore }()
+	currentStore = store
+	srv, refreshes := newTokenServer()
+	defer srv.Close()
+	oldTokenURL := config.Config.OAuth.TokenURL
+	defer func() { config.Config.OAuth.TokenURL = oldTokenURL }()
+	config.Config.OAuth.TokenURL = srv.URL
+	store.Save(&authModels.TokenSpec{AccessToken: "access-0", RefreshToken: "refresh-0"})
+	dir := filepath.Dir(store.(*fileStore).path)
+
+	var wg sync.WaitGroup
+	outputs := make([]string, 4)
+	for i := range outputs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cmd := exec.Command(os.Args[0], "-test.run=^TestHelperRefreshProcess$")
+			cmd.Env = append(os.Environ(), helperStoreEnvVar+"="+dir, helperTokenURLEnvVar+"="+config.Config.OAuth.TokenURL)
+			output, err := cmd.CombinedOutput()
+			if err != nil {
+				outputs[i] = fmt.Sprintf("%v: %s", err, output)
+				return
+			}
+			outputs[i] = string(output)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, output := range outputs {
+		if !strings.Contains(output, "token: access-1") {
+			t.Fatalf("Expected every process to get the refreshed token, got: %s", output)
+		}
+	}
+	if *refreshes != 1 {
+		t.Fatalf("Expected token to be refreshed once, got %d refreshes", *refreshes)
+	}
+}
+
+func TestWritesReplaceTokensFileAtOnce(t *testing.T) {
+	dir := createStoreDirectory(t)
+	path := filepath.Join(dir, "tokens.enc")
+
+	if err := writePrivateFile(path, []byte("content")); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	infos, _ := os.ReadDir(dir)
+	if len(infos) != 1 {
+		t.Fatalf("Expected no temporary files to be left, got %d files", len(infos))
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Fatalf("Expected file to be readable only by the user, got: %v", info.Mode())
+	}
+}
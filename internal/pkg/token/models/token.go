@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type DeviceCodeSpec struct {
 	DeviceCode              string `json:"device_code"`
 	UserCode                string `json:"user_code"`
@@ -15,9 +17,21 @@ type AuthError struct {
 }
 
 type TokenSpec struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
-	IDToken      string `json:"id_token"`
-	TokenType    string `json:"token_type"`
-	ExpiresIn    int    `json:"expires_in"`
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	IDToken      string    `json:"id_token"`
+	TokenType    string    `json:"token_type"`
+	ExpiresIn    int       `json:"expires_in"`
+	IssuedAt     time.Time `json:"issued_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// IDTokenClaims are the claims of ID token describing the logged in user
+type IDTokenClaims struct {
+	Subject   string `json:"sub"`
+	Name      string `json:"name"`
+	Nickname  string `json:"nickname"`
+	Email     string `json:"email"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
 }
@@ -0,0 +1,235 @@
+package token
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/loophole/cli/config"
+	"github.com/loophole/cli/internal/pkg/communication"
+	authModels "github.com/loophole/cli/internal/pkg/token/models"
+	"github.com/rs/zerolog/log"
+)
+
+// refreshMargin is how long before the expiry the access token gets refreshed
+const refreshMargin = 5 * time.Minute
+
+// refreshClient is used for refresh requests, sent while holding the lock shared with other processes,
+// so a request that hangs has to time out instead of blocking all of them
+var refreshClient = &http.Client{Timeout: 30 * time.Second}
+
+// refreshMutex makes sure tunnels started at once don't refresh the token concurrently,
+// refresh tokens may be rotated and using one twice would log the user out
+var refreshMutex sync.Mutex
+
+var now = time.Now
+
+// GetAccessToken returns the access token, refreshing it first when it's about to expire
+func GetAccessToken() (string, error) {
+	token, err := loadTokens()
+	if err != nil {
+		return "", err
+	}
+	expiresAt := expiryOf(token)
+	if token.AccessToken != "" && (expiresAt.IsZero() || now().Add(refreshMargin).Before(expiresAt)) {
+		return token.AccessToken, nil
+	}
+
+	refreshed, err := refresh(token.AccessToken)
+	if err != nil {
+		if now().Before(expiresAt) {
+			communication.Debug(fmt.Sprintf("Refreshing token ahead of expiry failed: %s", err.Error()))
+			return token.AccessToken, nil
+		}
+		return "", err
+	}
+	return refreshed.AccessToken, nil
+}
+
+// RefreshAccessToken refreshes the tokens after the given access token was rejected,
+// if it was already replaced in the meantime the current one is returned without another refresh
+func RefreshAccessToken(rejectedAccessToken string) (string, error) {
+	token, err := refresh(rejectedAccessToken)
+	if err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+// RefreshToken refreshes the tokens unconditionally
+func RefreshToken() (*authModels.TokenSpec, error) {
+	token, err := loadTokens()
+	if err != nil {
+		return nil, err
+	}
+	return refresh(token.AccessToken)
+}
+
+// ExpiresAt returns the expiry time of the stored access token, zero if it's unknown
+func ExpiresAt() (time.Time, error) {
+	token, err := loadTokens()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return expiryOf(token), nil
+}
+
+// refresh obtains new tokens holding the lock shared with other processes, if the tokens were
+// already refreshed by one of them in the meantime its result is used instead of refreshing again
+func refresh(staleAccessToken string) (*authModels.TokenSpec, error) {
+	refreshMutex.Lock()
+	defer refreshMutex.Unlock()
+
+	store := getStore()
+	var refreshed *authModels.TokenSpec
+	err := withLock(store, true, func() error {
+		token, err := store.Load()
+		if err != nil {
+			return fmt.Errorf("There was a problem reading tokens: %w", err)
+		}
+		if token.AccessToken != staleAccessToken {
+			communication.Debug("Token was already refreshed")
+			refreshed = token
+			return nil
+		}
+
+		response, err := requestRefresh(token.RefreshToken)
+		if err != nil {
+			if FromEnvironment() {
+				return invalidIfRejected(err, RefreshTokenEnvVar)
+			}
+			return err
+		}
+		refreshed = mergeRefreshed(token, response)
+		stampExpiry(refreshed)
+		return store.Save(refreshed)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return refreshed, nil
+}
+
+// mergeRefreshed keeps the refresh and ID tokens which the authorization server didn't rotate
+func mergeRefreshed(token *authModels.TokenSpec, refreshed *authModels.TokenSpec) *authModels.TokenSpec {
+	if refreshed.RefreshToken == "" {
+		refreshed.RefreshToken = token.RefreshToken
+	}
+	if refreshed.IDToken == "" {
+		refreshed.IDToken = token.IDToken
+	}
+	return refreshed
+}
+
+// refreshRejectedError is returned when authorization server refuses to refresh the token
+type refreshRejectedError struct {
+	error
+}
+
+// invalidIfRejected reports refused refresh as invalid token, keeping other failures like connection problems intact
+func invalidIfRejected(err error, source string) error {
+	var rejected refreshRejectedError
+	if errors.As(err, &rejected) {
+		return &InvalidTokenError{Source: source, Err: rejected.error}
+	}
+	return err
+}
+
+func requestRefresh(refreshToken string) (*authModels.TokenSpec, error) {
+	grantType := "refresh_token"
+	payload := strings.NewReader(fmt.Sprintf("grant_type=%s&client_id=%s&refresh_token=%s", url.QueryEscape(grantType), url.QueryEscape(config.Config.OAuth.ClientID), url.QueryEscape(refreshToken)))
+
+	req, err := http.NewRequest("POST", config.Config.OAuth.TokenURL, payload)
+	if err != nil {
+		return nil, fmt.Errorf("There was a problem creating HTTP POST request for token refresh")
+	}
+	req.Header.Add("content-type", "application/x-www-form-urlencoded")
+	res, err := refreshClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= 400 && res.StatusCode < 500 {
+		var jsonResponseBody authModels.AuthError
+		err := json.Unmarshal(body, &jsonResponseBody)
+		if err != nil && (res.StatusCode == http.StatusBadRequest || res.StatusCode == http.StatusUnauthorized) {
+			return nil, err
+		}
+		log.Debug().
+			Int("status", res.StatusCode).
+			Str("error", jsonResponseBody.Error).
+			Str("errorDescription", jsonResponseBody.ErrorDescription).
+			Msg("Error response")
+		if jsonResponseBody.Error == "expired_token" || jsonResponseBody.Error == "invalid_grant" {
+			return nil, refreshRejectedError{fmt.Errorf("The refresh token expired, please log in again")}
+		} else if jsonResponseBody.Error == "access_denied" {
+			return nil, refreshRejectedError{fmt.Errorf("The refresh token got denied, please log in again")}
+		} else if res.StatusCode == http.StatusBadRequest || res.StatusCode == http.StatusUnauthorized {
+			return nil, refreshRejectedError{fmt.Errorf("Refreshing token failed: %s %s", jsonResponseBody.Error, jsonResponseBody.ErrorDescription)}
+		}
+		// rate limiting, timeouts and the like don't mean the token is invalid, so it can be refreshed later
+		return nil, fmt.Errorf("Refreshing token failed, authorization server responded with %s", res.Status)
+	} else if res.StatusCode >= 200 && res.StatusCode < 300 {
+		var jsonResponseBody authModels.TokenSpec
+		err := json.Unmarshal(body, &jsonResponseBody)
+		if err != nil {
+			return nil, err
+		}
+		return &jsonResponseBody, nil
+	}
+	return nil, fmt.Errorf("Unexpected response from authorization server: %s", body)
+}
+
+// expiryOf returns the recorded expiry time, or the one from access token claims for tokens saved by older versions
+func expiryOf(token *authModels.TokenSpec) time.Time {
+	if !token.ExpiresAt.IsZero() {
+		return token.ExpiresAt
+	}
+	var claims struct {
+		ExpiresAt int64 `json:"exp"`
+	}
+	if err := decodeJWTClaims(token.AccessToken, &claims); err != nil || claims.ExpiresAt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.ExpiresAt, 0)
+}
+
+// GetIDTokenClaims returns the claims of the stored ID token, the signature is not verified
+// as the claims are only displayed to the user
+func GetIDTokenClaims() (*authModels.IDTokenClaims, error) {
+	token, err := loadTokens()
+	if err != nil {
+		return nil, err
+	}
+	var claims authModels.IDTokenClaims
+	err = decodeJWTClaims(token.IDToken, &claims)
+	if err != nil {
+		return nil, fmt.Errorf("There was a problem decoding ID token: %v", err)
+	}
+	return &claims, nil
+}
+
+func decodeJWTClaims(jwt string, claims interface{}) error {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return errors.New("not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(payload, claims)
+}
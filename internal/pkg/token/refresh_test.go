@@ -0,0 +1,161 @@
+package token
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/loophole/cli/config"
+	authModels "github.com/loophole/cli/internal/pkg/token/models"
+)
+
+func newTestStore(t *testing.T) Store {
+	dir := createStoreDirectory(t)
+	return newFileStore(filepath.Join(dir, "tokens.enc"), "")
+}
+
+// newTokenServer returns token endpoint issuing new tokens on every refresh and the number of refreshes
+func newTokenServer() (*httptest.Server, *int32) {
+	var refreshes int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count := atomic.AddInt32(&refreshes, 1)
+		time.Sleep(10 * time.Millisecond)
+		fmt.Fprintf(w, `{"access_token": "access-%d", "refresh_token": "refresh-%d", "expires_in": 3600}`, count, count)
+	}))
+	return srv, &refreshes
+}
+
+func TestAccessTokenIsRefreshedAheadOfExpiry(t *testing.T) {
+	store := newTestStore(t)
+	oldStore := currentStore
+	defer func() { currentStore = oldStore }()
+	currentStore = store
+	srv, refreshes := newTokenServer()
+	defer srv.Close()
+	oldTokenURL := config.Config.OAuth.TokenURL
+	defer func() { config.Config.OAuth.TokenURL = oldTokenURL }()
+	config.Config.OAuth.TokenURL = srv.URL
+	store.Save(&authModels.TokenSpec{
+		AccessToken:  "access-0",
+		RefreshToken: "refresh-0",
+		IssuedAt:     time.Now().Add(-time.Hour),
+		ExpiresAt:    time.Now().Add(time.Minute),
+	})
+
+	accessToken, err := GetAccessToken()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if accessToken != "access-1" || *refreshes != 1 {
+		t.Fatalf("Expected token to be refreshed once, got '%s' after %d refreshes", accessToken, *refreshes)
+	}
+
+	stored, _ := store.Load()
+	if stored.RefreshToken != "refresh-1" || stored.ExpiresAt.Before(time.Now().Add(50*time.Minute)) {
+		t.Fatalf("Expected rotated refresh token and new expiry to be stored, got: %v", stored)
+	}
+	if accessToken, _ := GetAccessToken(); accessToken != "access-1" {
+		t.Fatalf("Expected valid token to be reused, got '%s'", accessToken)
+	}
+}
+
+func TestConcurrentRefreshesAreMerged(t *testing.T) {
+	store := newTestStore(t)
+	oldStore := currentStore
+	defer func() { currentStore = oldStore }()
+	currentStore = store
+	srv, refreshes := newTokenServer()
+	defer srv.Close()
+	oldTokenURL := config.Config.OAuth.TokenURL
+	defer func() { config.Config.OAuth.TokenURL = oldTokenURL }()
+	config.Config.OAuth.TokenURL = srv.URL
+	store.Save(&authModels.TokenSpec{AccessToken: "access-0", RefreshToken: "refresh-0", IssuedAt: time.Now()})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := RefreshAccessToken("access-0"); err != nil {
+				t.Errorf("Expected no error, got: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if *refreshes != 1 {
+		t.Fatalf("Expected single refresh request, got %d", *refreshes)
+	}
+}
+
+func TestExpiryIsReadFromLegacyAccessToken(t *testing.T) {
+	expiry := time.Now().Add(time.Hour).Unix()
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp": %d}`, expiry)))
+
+	result := expiryOf(&authModels.TokenSpec{AccessToken: "header." + payload + ".signature"})
+	if result.Unix() != expiry {
+		t.Fatalf("Expected expiry '%d', got '%d'", expiry, result.Unix())
+	}
+}
+
+func TestOnlyRefusedRefreshIsRejected(t *testing.T) {
+	var status int
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+	oldTokenURL := config.Config.OAuth.TokenURL
+	config.Config.OAuth.TokenURL = srv.URL
+	defer func() { config.Config.OAuth.TokenURL = oldTokenURL }()
+
+	cases := []struct {
+		status   int
+		body     string
+		rejected bool
+	}{
+		{http.StatusBadRequest, `{"error": "invalid_grant"}`, true},
+		{http.StatusForbidden, `{"error": "access_denied"}`, true},
+		{http.StatusUnauthorized, `{"error": "invalid_client"}`, true},
+		{http.StatusTooManyRequests, `{"error": "too_many_requests"}`, false},
+		{http.StatusRequestTimeout, `Request Timeout`, false},
+	}
+	for _, c := range cases {
+		status, body = c.status, c.body
+		_, err := requestRefresh("refresh-0")
+		if err == nil {
+			t.Fatalf("Expected error for status %d", c.status)
+		}
+		var rejected refreshRejectedError
+		if errors.As(err, &rejected) != c.rejected {
+			t.Fatalf("Expected status %d with '%s' to be rejected: %t, got: %v", c.status, c.body, c.rejected, err)
+		}
+	}
+}
+
+func TestHangingRefreshTimesOut(t *testing.T) {
+	release := make(chan bool)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+	oldTokenURL := config.Config.OAuth.TokenURL
+	config.Config.OAuth.TokenURL = srv.URL
+	defer func() { config.Config.OAuth.TokenURL = oldTokenURL }()
+	oldTimeout := refreshClient.Timeout
+	refreshClient.Timeout = 50 * time.Millisecond
+	defer func() { refreshClient.Timeout = oldTimeout }()
+
+	if _, err := requestRefresh("refresh-0"); err == nil {
+		t.Fatalf("Expected hanging refresh request to time out")
+	}
+}
@@ -0,0 +1,199 @@
+package token
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/loophole/cli/internal/pkg/cache"
+	"github.com/loophole/cli/internal/pkg/communication"
+	"github.com/loophole/cli/internal/pkg/profile"
+	authModels "github.com/loophole/cli/internal/pkg/token/models"
+)
+
+const (
+	// CredentialStoreEnvVar selects the token store, either 'keyring' or 'file', by default keyring is used when available
+	CredentialStoreEnvVar = "LOOPHOLE_CREDENTIAL_STORE"
+	// PassphraseEnvVar sets the passphrase of the encrypted tokens file, machine secret is used when not set
+	PassphraseEnvVar = "LOOPHOLE_TOKEN_PASSPHRASE"
+
+	legacyTokensFile = "tokens.json"
+)
+
+// ErrTokenNotFound is returned when no tokens are stored
+var ErrTokenNotFound = errors.New("No tokens stored")
+
+// Store keeps the tokens of the logged in user
+type Store interface {
+	Name() string
+	Load() (*authModels.TokenSpec, error)
+	Save(*authModels.TokenSpec) error
+	Delete() error
+}
+
+// currentStore replaces the store of every profile when set, used in tests
+var currentStore Store
+var stores = map[string]Store{}
+var storesMutex sync.Mutex
+
+var environmentStore *envStore
+var environmentStoreOnce sync.Once
+
+// FromEnvironment checks whether tokens are provided with environment variables instead of the stored ones
+func FromEnvironment() bool {
+	_, ok := getStore().(*envStore)
+	return ok
+}
+
+// getStore returns the store of tokens from environment variables when they are set, otherwise the one of the active profile
+func getStore() Store {
+	environmentStoreOnce.Do(func() {
+		environmentStore = newEnvStore()
+	})
+	if environmentStore != nil && currentStore == nil {
+		return environmentStore
+	}
+	profileName, err := profile.Current()
+	if err != nil {
+		return &unavailableStore{err: err}
+	}
+	return storeFor(profileName)
+}
+
+// unavailableStore fails every operation, used when the active profile can't be determined
+type unavailableStore struct {
+	err error
+}
+
+func (s *unavailableStore) Name() string {
+	return "unavailable"
+}
+
+func (s *unavailableStore) Load() (*authModels.TokenSpec, error) {
+	return nil, s.err
+}
+
+func (s *unavailableStore) Save(*authModels.TokenSpec) error {
+	return s.err
+}
+
+func (s *unavailableStore) Delete() error {
+	return s.err
+}
+
+// storeFor returns the store of given profile, migrating plaintext tokens on first use
+func storeFor(profileName string) Store {
+	storesMutex.Lock()
+	defer storesMutex.Unlock()
+	if currentStore != nil {
+		return currentStore
+	}
+	if store, ok := stores[profileName]; ok {
+		return store
+	}
+
+	store := newDefaultStore(profileName)
+	restrictStorageDir()
+	if profileName == profile.DefaultName {
+		migratePlaintextTokens(store, cache.GetLocalStorageFile(legacyTokensFile, ""))
+	}
+	stores[profileName] = store
+	return store
+}
+
+func newDefaultStore(profileName string) Store {
+	file := newFileStore(filepath.Join(profile.Dir(profileName), "tokens.enc"), os.Getenv(PassphraseEnvVar))
+
+	switch os.Getenv(CredentialStoreEnvVar) {
+	case "file":
+		return file
+	case "keyring":
+		return newKeyringStore(profileName)
+	case "":
+	default:
+		communication.Warn(fmt.Sprintf("Unknown credential store '%s', using the default one", os.Getenv(CredentialStoreEnvVar)))
+	}
+	return &fallbackStore{primary: newKeyringStore(profileName), secondary: file}
+}
+
+// restrictStorageDir makes sure other users can't read the content of loophole directory
+func restrictStorageDir() {
+	err := os.Chmod(cache.GetLocalStorageDir(""), 0700)
+	if err != nil {
+		communication.Debug(fmt.Sprintf("There was a problem restricting permissions of loophole directory: %s", err.Error()))
+	}
+}
+
+// migratePlaintextTokens moves tokens saved by older versions into the store
+func migratePlaintextTokens(store Store, legacyFile string) {
+	content, err := ioutil.ReadFile(legacyFile)
+	if err != nil {
+		return
+	}
+	var token authModels.TokenSpec
+	err = json.Unmarshal(content, &token)
+	if err == nil {
+		err = store.Save(&token)
+	}
+	if err != nil {
+		communication.Warn(fmt.Sprintf("There was a problem migrating tokens to %s: %s", store.Name(), err.Error()))
+		os.Chmod(legacyFile, 0600)
+		return
+	}
+	err = os.Remove(legacyFile)
+	if err != nil {
+		communication.Warn(fmt.Sprintf("There was a problem removing plaintext tokens file: %s", err.Error()))
+		return
+	}
+	communication.Debug(fmt.Sprintf("Tokens migrated to %s", store.Name()))
+}
+
+// fallbackStore uses the secondary store when the primary one is unavailable
+type fallbackStore struct {
+	primary   Store
+	secondary Store
+}
+
+func (fs *fallbackStore) Name() string {
+	return fmt.Sprintf("%s (with %s fallback)", fs.primary.Name(), fs.secondary.Name())
+}
+
+func (fs *fallbackStore) Load() (*authModels.TokenSpec, error) {
+	token, err := fs.primary.Load()
+	if err == nil {
+		return token, nil
+	}
+	if err != ErrTokenNotFound {
+		communication.Debug(fmt.Sprintf("Reading tokens from %s failed: %s", fs.primary.Name(), err.Error()))
+	}
+	return fs.secondary.Load()
+}
+
+func (fs *fallbackStore) Save(token *authModels.TokenSpec) error {
+	err := fs.primary.Save(token)
+	if err != nil {
+		communication.Debug(fmt.Sprintf("Saving tokens to %s failed, using %s: %s", fs.primary.Name(), fs.secondary.Name(), err.Error()))
+		fs.primary.Delete()
+		return fs.secondary.Save(token)
+	}
+	if err := fs.secondary.Delete(); err != nil && err != ErrTokenNotFound {
+		communication.Debug(fmt.Sprintf("There was a problem removing tokens from %s: %s", fs.secondary.Name(), err.Error()))
+	}
+	return nil
+}
+
+func (fs *fallbackStore) Delete() error {
+	primaryErr := fs.primary.Delete()
+	secondaryErr := fs.secondary.Delete()
+	if primaryErr == nil || secondaryErr == nil {
+		return nil
+	}
+	if secondaryErr == ErrTokenNotFound {
+		return primaryErr
+	}
+	return secondaryErr
+}
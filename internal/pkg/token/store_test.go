@@ -0,0 +1,208 @@
+package token
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/loophole/cli/internal/pkg/profile"
+	authModels "github.com/loophole/cli/internal/pkg/token/models"
+	"github.com/zalando/go-keyring"
+)
+
+var testTokens = &authModels.TokenSpec{
+	AccessToken:  "access-token",
+	RefreshToken: "refresh-token",
+	IDToken:      "id-token",
+}
+
+func createStoreDirectory(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "loophole-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func TestFileStoreEncryptsTokens(t *testing.T) {
+	for _, passphrase := range []string{"", "correct horse"} {
+		dir := createStoreDirectory(t)
+		store := newFileStore(filepath.Join(dir, "tokens.enc"), passphrase)
+
+		if err := store.Save(testTokens); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		content, _ := ioutil.ReadFile(filepath.Join(dir, "tokens.enc"))
+		if strings.Contains(string(content), testTokens.RefreshToken) {
+			t.Fatalf("Expected tokens file not to contain plaintext tokens")
+		}
+		stat, _ := os.Stat(filepath.Join(dir, "tokens.enc"))
+		if stat.Mode().Perm() != 0600 {
+			t.Fatalf("Expected tokens file mode '0600', got '%o'", stat.Mode().Perm())
+		}
+
+		loaded, err := store.Load()
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if *loaded != *testTokens {
+			t.Fatalf("Expected loaded tokens '%v', got '%v'", testTokens, loaded)
+		}
+	}
+}
+
+func TestFileStoreUsesMachineSecretWithoutPassphrase(t *testing.T) {
+	oldMachineSecret := machineSecret
+	defer func() { machineSecret = oldMachineSecret }()
+	secret := []byte(strings.Repeat("s", keySize))
+	machineSecret = func(path string, create bool) ([]byte, error) { return secret, nil }
+
+	dir := createStoreDirectory(t)
+	if err := newFileStore(filepath.Join(dir, "tokens.enc"), "").Save(testTokens); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if loaded, err := newFileStore(filepath.Join(dir, "tokens.enc"), "").Load(); err != nil || *loaded != *testTokens {
+		t.Fatalf("Expected tokens to be loaded with the same secret, got '%v' (%v)", loaded, err)
+	}
+
+	secret = []byte(strings.Repeat("o", keySize))
+	if _, err := newFileStore(filepath.Join(dir, "tokens.enc"), "").Load(); err == nil {
+		t.Fatalf("Expected error when machine secret differs")
+	}
+}
+
+func TestMachineSecretIsPrivate(t *testing.T) {
+	dir := createStoreDirectory(t)
+	path := filepath.Join(dir, machineSecretFile)
+
+	if _, err := loadMachineSecret(path, false); err == nil {
+		t.Fatalf("Expected error for missing machine secret")
+	}
+	secret, err := loadMachineSecret(path, true)
+	if err != nil || len(secret) != keySize {
+		t.Fatalf("Expected %d bytes long secret, got %d (%v)", keySize, len(secret), err)
+	}
+	if runtime.GOOS != "windows" {
+		if stat, _ := os.Stat(path); stat.Mode().Perm() != 0600 {
+			t.Fatalf("Expected machine secret mode '0600', got '%o'", stat.Mode().Perm())
+		}
+	}
+	if again, _ := loadMachineSecret(path, true); string(again) != string(secret) {
+		t.Fatalf("Expected existing machine secret to be reused")
+	}
+}
+
+func TestFileStoreDerivesPassphraseKeyOnce(t *testing.T) {
+	dir := createStoreDirectory(t)
+	store := newFileStore(filepath.Join(dir, "tokens.enc"), "correct horse")
+
+	store.Save(testTokens)
+	derived := store.derived
+	store.Save(testTokens)
+	if _, err := store.Load(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if derived == nil || store.derived != derived {
+		t.Fatalf("Expected derived key to be reused")
+	}
+}
+
+func TestFileStoreRejectsWrongPassphrase(t *testing.T) {
+	dir := createStoreDirectory(t)
+	newFileStore(filepath.Join(dir, "tokens.enc"), "first").Save(testTokens)
+
+	_, err := newFileStore(filepath.Join(dir, "tokens.enc"), "second").Load()
+	if err == nil {
+		t.Fatalf("Expected error for wrong passphrase")
+	}
+	_, err = newFileStore(filepath.Join(dir, "tokens.enc"), "").Load()
+	if err == nil || !strings.Contains(err.Error(), PassphraseEnvVar) {
+		t.Fatalf("Expected error mentioning '%s', got: %v", PassphraseEnvVar, err)
+	}
+}
+
+func TestFileStoreReportsMissingTokens(t *testing.T) {
+	dir := createStoreDirectory(t)
+	store := newFileStore(filepath.Join(dir, "tokens.enc"), "")
+
+	if _, err := store.Load(); err != ErrTokenNotFound {
+		t.Fatalf("Expected '%v', got: %v", ErrTokenNotFound, err)
+	}
+	if err := store.Delete(); err != ErrTokenNotFound {
+		t.Fatalf("Expected '%v', got: %v", ErrTokenNotFound, err)
+	}
+}
+
+func TestPlaintextTokensAreMigrated(t *testing.T) {
+	dir := createStoreDirectory(t)
+	legacyFile := filepath.Join(dir, legacyTokensFile)
+	content, _ := json.Marshal(testTokens)
+	ioutil.WriteFile(legacyFile, content, 0644)
+	store := newFileStore(filepath.Join(dir, "tokens.enc"), "")
+
+	migratePlaintextTokens(store, legacyFile)
+
+	if _, err := os.Stat(legacyFile); !os.IsNotExist(err) {
+		t.Fatalf("Expected plaintext tokens file to be removed")
+	}
+	loaded, err := store.Load()
+	if err != nil || loaded.RefreshToken != testTokens.RefreshToken {
+		t.Fatalf("Expected migrated tokens to be loaded, got '%v' (%v)", loaded, err)
+	}
+}
+
+func TestFallbackStoreUsesFileWhenKeyringIsUnavailable(t *testing.T) {
+	keyring.MockInitWithError(errors.New("no secret service"))
+	dir := createStoreDirectory(t)
+	file := newFileStore(filepath.Join(dir, "tokens.enc"), "")
+	store := &fallbackStore{primary: newKeyringStore(profile.DefaultName), secondary: file}
+
+	if err := store.Save(testTokens); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, err := file.Load(); err != nil {
+		t.Fatalf("Expected tokens to be saved in file, got: %v", err)
+	}
+	if loaded, err := store.Load(); err != nil || loaded.AccessToken != testTokens.AccessToken {
+		t.Fatalf("Expected tokens to be loaded from file, got '%v' (%v)", loaded, err)
+	}
+}
+
+func TestFallbackStorePrefersKeyring(t *testing.T) {
+	keyring.MockInit()
+	dir := createStoreDirectory(t)
+	file := newFileStore(filepath.Join(dir, "tokens.enc"), "")
+	file.Save(&authModels.TokenSpec{AccessToken: "stale"})
+	store := &fallbackStore{primary: newKeyringStore(profile.DefaultName), secondary: file}
+
+	if err := store.Save(testTokens); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, err := file.Load(); err != ErrTokenNotFound {
+		t.Fatalf("Expected stale tokens file to be removed, got: %v", err)
+	}
+	if loaded, err := store.Load(); err != nil || loaded.AccessToken != testTokens.AccessToken {
+		t.Fatalf("Expected tokens to be loaded from keyring, got '%v' (%v)", loaded, err)
+	}
+}
+
+func TestProfilesUseSeparateKeyringEntries(t *testing.T) {
+	keyring.MockInit()
+	work := &authModels.TokenSpec{AccessToken: "work-token"}
+
+	if err := newKeyringStore("work").Save(work); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, err := newKeyringStore(profile.DefaultName).Load(); err != ErrTokenNotFound {
+		t.Fatalf("Expected default profile to have no tokens, got: %v", err)
+	}
+	if loaded, err := newKeyringStore("work").Load(); err != nil || loaded.AccessToken != work.AccessToken {
+		t.Fatalf("Expected tokens of 'work' profile to be loaded, got '%v' (%v)", loaded, err)
+	}
+}
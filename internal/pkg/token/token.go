@@ -2,46 +2,84 @@ package token
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/loophole/cli/config"
-	"github.com/loophole/cli/internal/pkg/cache"
 	"github.com/loophole/cli/internal/pkg/communication"
 	authModels "github.com/loophole/cli/internal/pkg/token/models"
 	"github.com/rs/zerolog/log"
 )
 
 func IsTokenSaved() bool {
-	tokensLocation := cache.GetLocalStorageFile("tokens.json", "")
+	return isTokenSavedIn(getStore())
+}
+
+// IsTokenSavedFor checks whether the user of given profile is logged in
+func IsTokenSavedFor(profileName string) bool {
+	return isTokenSavedIn(storeFor(profileName))
+}
 
-	if _, err := os.Stat(tokensLocation); os.IsNotExist(err) {
+// isTokenSavedIn checks whether the store has tokens, the invalid ones provided non-interactively count
+// as saved so that the following request reports why they can't be used
+func isTokenSavedIn(store Store) bool {
+	_, err := loadFrom(store)
+	var invalidTokenErr *InvalidTokenError
+	if err == ErrTokenNotFound {
 		return false
+	} else if errors.As(err, &invalidTokenErr) {
+		return true
 	} else if err != nil {
-		communication.Warn("There was a problem reading tokens file")
+		communication.Warn("There was a problem reading tokens")
 		communication.Warn(err.Error())
 		return false
 	}
 	return true
 }
 
+// SaveToken stores the tokens, recording the expiry time of tokens received from authorization server
 func SaveToken(token *authModels.TokenSpec) error {
-	tokensLocation := cache.GetLocalStorageFile("tokens.json", "")
+	stampExpiry(token)
+	store := getStore()
+	return withLock(store, true, func() error {
+		return store.Save(token)
+	})
+}
+
+func stampExpiry(token *authModels.TokenSpec) {
+	if token.IssuedAt.IsZero() {
+		token.IssuedAt = time.Now()
+		if token.ExpiresIn > 0 {
+			token.ExpiresAt = token.IssuedAt.Add(time.Duration(token.ExpiresIn) * time.Second)
+		}
+	}
+}
 
-	tokenBytes, err := json.Marshal(token)
+// LoginWithToken saves the tokens provided non-interactively, refreshing them first
+// when there is no valid access token, so that invalid tokens are rejected right away
+func LoginWithToken(token *authModels.TokenSpec, source string) (*authModels.TokenSpec, error) {
+	err := validateTokens(token, source)
 	if err != nil {
-		return fmt.Errorf("There was a problem encoding tokens: %v", err)
+		return nil, err
+	}
+	expiresAt := expiryOf(token)
+	if token.AccessToken == "" || (!expiresAt.IsZero() && !now().Before(expiresAt)) {
+		refreshed, err := requestRefresh(token.RefreshToken)
+		if err != nil {
+			return nil, invalidIfRejected(err, source)
+		}
+		token = mergeRefreshed(token, refreshed)
 	}
-	err = ioutil.WriteFile(tokensLocation, tokenBytes, 0644)
+	err = SaveToken(token)
 	if err != nil {
-		return fmt.Errorf("There was a problem writing tokens file: %v", err)
+		return nil, err
 	}
-	return nil
+	return token, nil
 }
 
 func RegisterDevice() (*authModels.DeviceCodeSpec, error) {
@@ -154,114 +192,44 @@ func PollForToken(deviceCode string, interval int, quitChannel <-chan bool) (*au
 	}
 }
 
-func RefreshToken() error {
-	grantType := "refresh_token"
-	token, err := GetRefreshToken()
-	if err != nil {
-		return err
-	}
-
-	payload := strings.NewReader(fmt.Sprintf("grant_type=%s&client_id=%s&refresh_token=%s", url.QueryEscape(grantType), url.QueryEscape(config.Config.OAuth.ClientID), url.QueryEscape(token)))
-
-	req, _ := http.NewRequest("POST", config.Config.OAuth.TokenURL, payload)
-
-	req.Header.Add("content-type", "application/x-www-form-urlencoded")
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
-
-	body, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return err
-	}
-
-	if res.StatusCode > 400 && res.StatusCode < 500 {
-		var jsonResponseBody authModels.AuthError
-		err := json.Unmarshal(body, &jsonResponseBody)
-		if err != nil {
-			return err
-		}
-		log.Debug().
-			Str("error", jsonResponseBody.Error).
-			Str("errorDescription", jsonResponseBody.ErrorDescription).
-			Msg("Error response")
-		if jsonResponseBody.Error == "expired_token" || jsonResponseBody.Error == "invalid_grand" {
-			return fmt.Errorf("The device token expired, please reinitialize the login")
-		} else if jsonResponseBody.Error == "access_denied" {
-			return fmt.Errorf("The device token got denied, please reinitialize the login")
-		}
-	} else if res.StatusCode >= 200 && res.StatusCode <= 300 {
-		var jsonResponseBody authModels.TokenSpec
-		err := json.Unmarshal(body, &jsonResponseBody)
-		if err != nil {
-			return err
-		}
-
-		jsonResponseBody.RefreshToken = token
-
-		err = SaveToken(&jsonResponseBody)
-		if err != nil {
-			return err
-		}
-
-	} else {
-		return fmt.Errorf("Unexpected response from authorization server: %s", body)
-	}
-	return nil
-
-}
-
 func DeleteTokens() error {
-	tokensLocation := cache.GetLocalStorageFile("tokens.json", "")
-
-	err := os.Remove(tokensLocation)
+	store := getStore()
+	err := withLock(store, true, store.Delete)
 	if err != nil {
-		return fmt.Errorf("There was a problem removing tokens file: %v", err)
+		return fmt.Errorf("There was a problem removing tokens: %v", err)
 	}
 	return nil
 }
 
-func GetAccessToken() (string, error) {
-	tokensLocation := cache.GetLocalStorageFile("tokens.json", "")
+// loadFrom reads the tokens, waiting for other processes updating them to finish
+func loadFrom(store Store) (*authModels.TokenSpec, error) {
+	var token *authModels.TokenSpec
+	err := withLock(store, false, func() error {
+		var err error
+		token, err = store.Load()
+		return err
+	})
+	return token, err
+}
 
-	tokens, err := ioutil.ReadFile(tokensLocation)
-	if err != nil {
-		return "", fmt.Errorf("There was a problem reading tokens: %v", err)
-	}
-	var token authModels.TokenSpec
-	err = json.Unmarshal(tokens, &token)
+func loadTokens() (*authModels.TokenSpec, error) {
+	token, err := loadFrom(getStore())
 	if err != nil {
-		return "", fmt.Errorf("There was a problem decoding tokens: %v", err)
+		return nil, fmt.Errorf("There was a problem reading tokens: %w", err)
 	}
-	return token.AccessToken, nil
+	return token, nil
 }
 
 func GetRefreshToken() (string, error) {
-	tokensLocation := cache.GetLocalStorageFile("tokens.json", "")
-
-	tokens, err := ioutil.ReadFile(tokensLocation)
-	if err != nil {
-		return "", fmt.Errorf("There was a problem reading tokens: %v", err)
-	}
-	var token authModels.TokenSpec
-	err = json.Unmarshal(tokens, &token)
+	token, err := loadTokens()
 	if err != nil {
-		return "", fmt.Errorf("There was a problem decoding tokens: %v", err)
+		return "", err
 	}
 	return token.RefreshToken, nil
 }
 
 func GetIdToken() string {
-	tokensLocation := cache.GetLocalStorageFile("tokens.json", "")
-
-	tokens, err := ioutil.ReadFile(tokensLocation)
-	if err != nil {
-		return ""
-	}
-	var token authModels.TokenSpec
-	err = json.Unmarshal(tokens, &token)
+	token, err := loadTokens()
 	if err != nil {
 		return ""
 	}
@@ -0,0 +1,123 @@
+package wsconn
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Conn carries byte stream, e.g. SSH connection, in binary WebSocket messages
+type Conn struct {
+	ws         *websocket.Conn
+	reader     io.Reader
+	readMutex  sync.Mutex
+	writeMutex sync.Mutex
+}
+
+// New wraps established WebSocket connection, it can be used on both client and server side
+func New(ws *websocket.Conn) *Conn {
+	return &Conn{ws: ws}
+}
+
+// Dial opens WebSocket connection to the URL, netDial is used to reach the server when given,
+// otherwise the proxy from environment variables is used
+func Dial(url string, netDial func(network string, address string) (net.Conn, error)) (*Conn, error) {
+	dialer := &websocket.Dialer{
+		HandshakeTimeout: 10 * time.Second,
+		NetDial:          netDial,
+	}
+	if netDial == nil {
+		dialer.Proxy = http.ProxyFromEnvironment
+	}
+	ws, resp, err := dialer.Dial(url, nil)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("There was a problem opening WebSocket connection to %s: %v (%s)", url, err, resp.Status)
+		}
+		return nil, fmt.Errorf("There was a problem opening WebSocket connection to %s: %v", url, err)
+	}
+	return New(ws), nil
+}
+
+// Read reads the stream from subsequent binary messages
+func (c *Conn) Read(b []byte) (int, error) {
+	c.readMutex.Lock()
+	defer c.readMutex.Unlock()
+
+	for {
+		if c.reader == nil {
+			messageType, reader, err := c.ws.NextReader()
+			if err != nil {
+				if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+					return 0, io.EOF
+				}
+				return 0, err
+			}
+			if messageType != websocket.BinaryMessage {
+				continue
+			}
+			c.reader = reader
+		}
+		n, err := c.reader.Read(b)
+		if err == io.EOF {
+			c.reader = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+// Write sends the data as single binary message
+func (c *Conn) Write(b []byte) (int, error) {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	err := c.ws.WriteMessage(websocket.BinaryMessage, b)
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close lets the other side know the stream has ended and closes the underlying connection
+func (c *Conn) Close() error {
+	c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	return c.ws.Close()
+}
+
+// LocalAddr returns the local network address
+func (c *Conn) LocalAddr() net.Addr {
+	return c.ws.LocalAddr()
+}
+
+// RemoteAddr returns the remote network address
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.ws.RemoteAddr()
+}
+
+// SetDeadline sets both read and write deadlines
+func (c *Conn) SetDeadline(t time.Time) error {
+	err := c.ws.SetReadDeadline(t)
+	if err != nil {
+		return err
+	}
+	return c.ws.SetWriteDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.ws.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.ws.SetWriteDeadline(t)
+}
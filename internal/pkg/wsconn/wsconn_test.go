@@ -0,0 +1,108 @@
+package wsconn
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/crypto/ssh"
+)
+
+// serveWebSocket starts server handling every upgraded connection with the handler
+func serveWebSocket(t *testing.T, handler func(conn *Conn)) string {
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn := New(ws)
+		defer conn.Close()
+		handler(conn)
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + "/ssh"
+}
+
+func TestStreamIsCarriedAcrossMessages(t *testing.T) {
+	url := serveWebSocket(t, func(conn *Conn) {
+		io.Copy(conn, conn)
+	})
+	conn, err := Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	defer conn.Close()
+
+	data := make([]byte, 100000)
+	rand.Read(data)
+	go func() {
+		for i := 0; i < len(data); i += 7000 {
+			end := i + 7000
+			if end > len(data) {
+				end = len(data)
+			}
+			conn.Write(data[i:end])
+		}
+	}()
+
+	received := make([]byte, len(data))
+	if _, err := io.ReadFull(conn, received); err != nil {
+		t.Fatalf("Expected data to be echoed, got: %v", err)
+	}
+	if !bytes.Equal(data, received) {
+		t.Fatalf("Expected echoed data to match the sent one")
+	}
+}
+
+func TestSSHConnectionOverWebSocket(t *testing.T) {
+	_, hostKey, _ := ed25519.GenerateKey(rand.Reader)
+	hostSigner, _ := ssh.NewSignerFromKey(hostKey)
+	serverConfig := &ssh.ServerConfig{NoClientAuth: true}
+	serverConfig.AddHostKey(hostSigner)
+
+	url := serveWebSocket(t, func(conn *Conn) {
+		sshConn, chans, reqs, err := ssh.NewServerConn(conn, serverConfig)
+		if err != nil {
+			return
+		}
+		defer sshConn.Close()
+		go ssh.DiscardRequests(reqs)
+		for newChannel := range chans {
+			newChannel.Reject(ssh.Prohibited, "no channels")
+		}
+	})
+
+	conn, err := Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, "gateway", &ssh.ClientConfig{
+		User:            "site",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatalf("Expected SSH handshake to succeed over WebSocket, got: %v", err)
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+	defer client.Close()
+
+	if _, _, err := client.SendRequest("keepalive", true, nil); err != nil {
+		t.Fatalf("Expected request to be sent over WebSocket, got: %v", err)
+	}
+}
+
+func TestFailedUpgradeIsReported(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	if _, err := Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("Expected error with response status, got: %v", err)
+	}
+}
@@ -4,12 +4,14 @@ import Sidebar from "../nav/Sidebar";
 
 import { WebSocket } from "../../features/websocket/WebSocket";
 import AboutApplication from "../../features/config/AboutApplication";
+import PassphraseModal from "../../features/config/PassphraseModal";
 
 const Layout = (props) => {
   return (
     <section className="section">
       <EventCatcher>
         <WebSocket />
+        <PassphraseModal />
         <div className="columns is-multiline">
           <div className="column is-3 is-narrow-mobile is-fullheight">
             <Sidebar />
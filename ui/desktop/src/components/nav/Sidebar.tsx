@@ -2,6 +2,7 @@ import React from "react";
 import { Link, useLocation } from "react-router-dom";
 import classNames from "classnames";
 import { useAuth } from "../../features/config/authProvider";
+import ProfileSwitcher from "../../features/config/ProfileSwitcher";
 import { useSelector } from "react-redux";
 
 const Sidebar = () => {
@@ -130,6 +131,7 @@ const Sidebar = () => {
         </li>
       </ul>
       <p className="menu-label">Account</p>
+      <ProfileSwitcher />
       <ul className="menu-list">
         {!auth.loggedIn ? (
           <li>
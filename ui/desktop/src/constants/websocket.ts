@@ -9,6 +9,10 @@ export const MessageTypeAppStop: MessageType = "MT_ApplicationStop";
 export const MessageTypeNewVersionAvailable: MessageType =
   "MT_ApplicationNewVersionAvailable";
 
+export const MessageTypeProfileSwitch: MessageType = "MT_ProfileSwitch";
+
+export const MessageTypePassphraseRequest: MessageType = "MT_PassphraseRequest";
+
 export const MessageTypeLogin: MessageType = "MT_Login";
 export const MessageTypeLoginSuccess: MessageType = "MT_LoginSuccess";
 export const MessageTypeLoginFailure: MessageType = "MT_LoginFailure";
@@ -32,6 +36,7 @@ export const MessageTypeLoadingFailure: MessageType = "MT_LoadingFailure";
 export const MessageTypeOpenInBrowser: MessageType = "MT_OpenInBrowser";
 
 export const MessageTypeRequestTunnelStop: MessageType = "MT_RequestTunnelStop";
+export const MessageTypeRequestTunnelMaintenance: MessageType = "MT_RequestTunnelMaintenance";
 
 export const PrefixMessageTypeTunnelStart: string = "MT_RequestTunnelStart_";
 export const MessageTypeRequestTunnelStartHTTP: MessageType = `${PrefixMessageTypeTunnelStart}HTTP`;
@@ -39,4 +44,6 @@ export const MessageTypeRequestTunnelStartDirectory: MessageType =  `${PrefixMes
 export const MessageTypeRequestTunnelStartWebDav: MessageType = `${PrefixMessageTypeTunnelStart}WebDav`;
 
 export const MessageTypeRequestLogout: MessageType = "MT_RequestLogout";
-export const MessageTypeRequestLogin: MessageType = "MT_RequestLogin";
\ No newline at end of file
+export const MessageTypeRequestLogin: MessageType = "MT_RequestLogin";
+export const MessageTypeRequestProfileSwitch: MessageType = "MT_RequestProfileSwitch";
+export const MessageTypePassphraseResponse: MessageType = "MT_PassphraseResponse";
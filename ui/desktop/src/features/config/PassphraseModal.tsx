@@ -0,0 +1,92 @@
+import React, { useState } from "react";
+import { send } from "@giantmachines/redux-websocket";
+import { useDispatch, useSelector } from "react-redux";
+import classNames from "classnames";
+import Message from "../../interfaces/Message";
+import PassphraseResponseMessage from "../../interfaces/PassphraseResponseMessage";
+import { MessageTypePassphraseResponse } from "../../constants/websocket";
+
+const PassphraseModal = () => {
+  const dispatch = useDispatch();
+  const passphraseRequests = useSelector(
+    (store: any) => store.config.passphraseRequests
+  );
+  const [passphrase, setPassphrase] = useState("");
+
+  const request = passphraseRequests.length ? passphraseRequests[0] : null;
+
+  const respond = (cancelled: boolean) => {
+    if (!request) return;
+
+    const message: Message<PassphraseResponseMessage> = {
+      type: MessageTypePassphraseResponse,
+      payload: {
+        requestId: request.requestId,
+        passphrase: cancelled ? "" : passphrase,
+        cancelled: cancelled,
+      },
+    };
+
+    dispatch(send(message));
+    setPassphrase("");
+  };
+
+  return (
+    <div
+      className={classNames({
+        modal: true,
+        "is-active": !!request,
+        "is-clipped": !!request,
+      })}
+    >
+      <div className="modal-background"></div>
+      <div className="modal-card">
+        <header className="modal-card-head">
+          <p className="modal-card-title">Key passphrase</p>
+          <button
+            className="delete"
+            aria-label="close"
+            onClick={() => respond(true)}
+          ></button>
+        </header>
+        <form
+          onSubmit={(event) => {
+            event.preventDefault();
+            respond(false);
+          }}
+        >
+          <section className="modal-card-body">
+            <div className="field">
+              <label className="label">
+                Enter passphrase for key {request ? request.keyFile : ""}
+              </label>
+              <div className="control">
+                <input
+                  className="input"
+                  type="password"
+                  autoFocus
+                  value={passphrase}
+                  onChange={(event) => setPassphrase(event.target.value)}
+                />
+              </div>
+            </div>
+          </section>
+          <footer className="modal-card-foot">
+            <button type="submit" className="button is-primary">
+              Unlock
+            </button>
+            <button
+              type="button"
+              className="button"
+              onClick={() => respond(true)}
+            >
+              Cancel
+            </button>
+          </footer>
+        </form>
+      </div>
+    </div>
+  );
+};
+
+export default PassphraseModal;
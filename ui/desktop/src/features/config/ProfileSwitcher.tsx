@@ -0,0 +1,51 @@
+import React from "react";
+import { send } from "@giantmachines/redux-websocket";
+import { useDispatch, useSelector } from "react-redux";
+import Message from "../../interfaces/Message";
+import SwitchProfileMessage from "../../interfaces/SwitchProfileMessage";
+import { MessageTypeRequestProfileSwitch } from "../../constants/websocket";
+
+const ProfileSwitcher = () => {
+  const dispatch = useDispatch();
+  const appState = useSelector((store: any) => store.config);
+
+  if (appState.profiles.length < 2) return null;
+
+  const switchProfile = (profile: string) => {
+    if (profile === appState.profile) return;
+
+    const message: Message<SwitchProfileMessage> = {
+      type: MessageTypeRequestProfileSwitch,
+      payload: {
+        profile: profile,
+      },
+    };
+
+    dispatch(send(message));
+  };
+
+  return (
+    <div className="field">
+      <div className="control has-icons-left">
+        <div className="select is-small is-fullwidth">
+          <select
+            value={appState.profile}
+            disabled={appState.switchingProfile}
+            onChange={(event) => switchProfile(event.target.value)}
+          >
+            {appState.profiles.map((profile: string) => (
+              <option key={profile} value={profile}>
+                {profile}
+              </option>
+            ))}
+          </select>
+        </div>
+        <span className="icon is-small is-left">
+          <i className="fas fa-id-badge"></i>
+        </span>
+      </div>
+    </div>
+  );
+};
+
+export default ProfileSwitcher;
@@ -5,8 +5,12 @@ import {
   MessageTypeLogin,
   MessageTypeLoginSuccess,
   MessageTypeLogoutSuccess,
+  MessageTypePassphraseRequest,
+  MessageTypePassphraseResponse,
+  MessageTypeProfileSwitch,
   MessageTypeRequestLogin,
   MessageTypeRequestLogout,
+  MessageTypeRequestProfileSwitch,
 } from "../../constants/websocket";
 
 const defaultDisplayConfig = {
@@ -24,6 +28,10 @@ export const configReducer = createReducer(
     version: "development",
     commitHash: "unknown",
     homeDirectory: "",
+    passphraseRequests: [],
+    profile: "",
+    profiles: [],
+    switchingProfile: false,
   },
   {
     "REDUX_WEBSOCKET::MESSAGE": (state, action) => {
@@ -54,6 +62,15 @@ export const configReducer = createReducer(
         state.loggedIn = false;
         state.user = null;
         state.syncedWithBackend = true;
+      } else if (action.payload.message.type === MessageTypeProfileSwitch) {
+        state.profile = action.payload.message.profile;
+        state.profiles = action.payload.message.profiles || [];
+        state.switchingProfile = false;
+      } else if (action.payload.message.type === MessageTypePassphraseRequest) {
+        state.passphraseRequests.push({
+          requestId: action.payload.message.requestId,
+          keyFile: action.payload.message.keyFile,
+        });
       }
     },
     "REDUX_WEBSOCKET::SEND": (state, action) => {
@@ -62,7 +79,13 @@ export const configReducer = createReducer(
       } else if (action.payload.type === MessageTypeRequestLogout) {
         state.loggedIn = false;
         state.syncedWithBackend = false;
-      } 
+      } else if (action.payload.type === MessageTypeRequestProfileSwitch) {
+        state.switchingProfile = true;
+      } else if (action.payload.type === MessageTypePassphraseResponse) {
+        state.passphraseRequests = state.passphraseRequests.filter(
+          (request) => request.requestId !== action.payload.payload.requestId
+        );
+      }
     },
   }
 );
@@ -1,11 +1,29 @@
 import React, { useState } from "react";
 import { useHistory } from "react-router-dom";
+import { useDispatch } from "react-redux";
+import { send } from "@giantmachines/redux-websocket";
 import StopTunnelModal from "./StopTunnelModal";
+import Message from "../../interfaces/Message";
+import TunnelMaintenanceMessage from "../../interfaces/TunnelMaintenanceMessage";
+import { MessageTypeRequestTunnelMaintenance } from "../../constants/websocket";
 
 const TunnelActions = (props: any) => {
   const history = useHistory();
+  const dispatch = useDispatch();
   const [modalVisible, setModalVisible] = useState(false);
 
+  const toggleMaintenance = () => {
+    const message: Message<TunnelMaintenanceMessage> = {
+      type: MessageTypeRequestTunnelMaintenance,
+      payload: {
+        tunnelId: props.tunnel.tunnelId,
+        enabled: !props.tunnel.maintenance,
+      },
+    };
+
+    dispatch(send(message));
+  };
+
   return (
     <div className="card">
     <StopTunnelModal hideAction={() => { setModalVisible(false); history.push("/tunnels")}} visible={modalVisible} tunnel={props.tunnel} />
@@ -13,7 +31,21 @@ const TunnelActions = (props: any) => {
         <p className="card-header-title">Actions</p>
       </header>
       <div className="card-content has-text-right">
-        <div className="content">
+        <div className="content buttons is-right">
+          {props.tunnel.started && !props.tunnel.error ? (
+            <button
+              className={props.tunnel.maintenance ? "button is-warning" : "button"}
+              onClick={toggleMaintenance}
+              onKeyDown={toggleMaintenance}
+            >
+              <span className="icon">
+                <i className="fas fa-tools" />
+              </span>
+              <span>
+                {props.tunnel.maintenance ? "Disable" : "Enable"} maintenance page
+              </span>
+            </button>
+          ) : null}
           <button
             className="button is-danger"
             onClick={() => { setModalVisible(true); }}
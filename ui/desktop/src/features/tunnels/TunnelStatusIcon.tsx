@@ -45,6 +45,16 @@ const TunnelStatusIcons = (props: { tunnel: Tunnel }) => {
             </span>
           </span>
         ) : null}
+        {props.tunnel.maintenance ? (
+          <span className="level-item">
+            <span
+              className="icon is-small has-tooltip-right has-tooltip-arrow"
+              title={"Tunnel is showing maintenance page"}
+            >
+              <i className="fas fa-tools" />
+            </span>
+          </span>
+        ) : null}
         {props.tunnel.proxyErrorDisabled ? (
           <span className="level-item">
             <span
@@ -11,6 +11,7 @@ export default interface Tunnel {
   proxyErrorDisabled: boolean;
   localAddr?: string;
   siteAddrs?: string[];
+  maintenance?: boolean;
 
   loading?: boolean;
   loadingMsg?: string;
@@ -8,6 +8,7 @@ import {
   MessageTypeLoadingFailure,
   MessageTypeLoadingStart,
   MessageTypeLoadingSuccess,
+  MessageTypeRequestTunnelMaintenance,
   MessageTypeTunnelStart,
   MessageTypeTunnelStartFailure,
   MessageTypeTunnelStartSuccess,
@@ -17,6 +18,7 @@ import {
 import ExposeDirectoryMessage from "../../interfaces/ExposeDirectoryMessage";
 import ExposeHttpPortMessage from "../../interfaces/ExposeHttpPortMessage";
 import Message from "../../interfaces/Message";
+import TunnelMaintenanceMessage from "../../interfaces/TunnelMaintenanceMessage";
 import { MessageTypeDeleteFailedTunnel } from "./actions";
 import Tunnel from "./interfaces/Tunnel";
 import TunnelsState from "./interfaces/TunnelsState";
@@ -131,19 +133,35 @@ export const tunnelsReducer = createReducer(initialState, {
   [`${DEFAULT_PREFIX}::${WEBSOCKET_SEND}`]: (
     state: TunnelsState,
     action: {
-      payload: Message<ExposeHttpPortMessage> | Message<ExposeDirectoryMessage>;
+      payload:
+        | Message<ExposeHttpPortMessage>
+        | Message<ExposeDirectoryMessage>
+        | Message<TunnelMaintenanceMessage>;
     }
   ) => {
+    if (action.payload.type === MessageTypeRequestTunnelMaintenance) {
+      const message = action.payload.payload as TunnelMaintenanceMessage;
+      const tunnelIndex = state.tunnels.findIndex(
+        (tunnel: Tunnel) => tunnel.tunnelId === message.tunnelId
+      );
+      if (tunnelIndex !== -1) {
+        state.tunnels[tunnelIndex].maintenance = message.enabled;
+      }
+      return;
+    }
     if (action.payload.type.startsWith(PrefixMessageTypeTunnelStart)) {
+      const message = action.payload.payload as
+        | ExposeHttpPortMessage
+        | ExposeDirectoryMessage;
       state.tunnels.push({
-        tunnelId: action.payload.payload.remote.tunnelId,
-        siteId: action.payload.payload.remote.siteId,
+        tunnelId: message.remote.tunnelId,
+        siteId: message.remote.siteId,
         type: action.payload.type.replace(PrefixMessageTypeTunnelStart, ""),
         started: false,
-        usingBasicAuth: !!action.payload.payload.remote.basicAuthUsername,
-        basicAuthUsername: action.payload.payload.remote.basicAuthUsername,
-        basicAuthPassword: action.payload.payload.remote.basicAuthPassword,
-        proxyErrorDisabled: action.payload.payload.remote.disableProxyErrorPage,
+        usingBasicAuth: !!message.remote.basicAuthUsername,
+        basicAuthUsername: message.remote.basicAuthUsername,
+        basicAuthPassword: message.remote.basicAuthPassword,
+        proxyErrorDisabled: message.remote.disableProxyErrorPage,
       });
     }
   },
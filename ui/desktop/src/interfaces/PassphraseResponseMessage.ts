@@ -0,0 +1,5 @@
+export default interface PassphraseResponseMessage {
+    requestId: string;
+    passphrase: string;
+    cancelled: boolean;
+}
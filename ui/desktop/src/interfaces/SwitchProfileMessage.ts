@@ -0,0 +1,3 @@
+export default interface SwitchProfileMessage {
+    profile: string;
+}
@@ -0,0 +1,4 @@
+export default interface TunnelMaintenanceMessage {
+    tunnelId: string;
+    enabled: boolean;
+}
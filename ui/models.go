@@ -11,9 +11,12 @@ const (
 	MessageTypeStartTunnelDirectory MessageType = "MT_RequestTunnelStart_Directory"
 	MessageTypeStartTunnelWebDav    MessageType = "MT_RequestTunnelStart_WebDav"
 	MessageTypeStopTunnel           MessageType = "MT_RequestTunnelStop"
+	MessageTypeTunnelMaintenance    MessageType = "MT_RequestTunnelMaintenance"
 	MessageTypeAuthorization        MessageType = "MT_RequestLogin"
 	MessageTypeLogout               MessageType = "MT_RequestLogout"
 	MessageTypeOpenBrowser          MessageType = "MT_OpenInBrowser"
+	MessageTypeSwitchProfile        MessageType = "MT_RequestProfileSwitch"
+	MessageTypePassphraseResponse   MessageType = "MT_PassphraseResponse"
 )
 
 type Message struct {
@@ -25,6 +28,21 @@ type StopTunnelMessage struct {
 	TunnelID string `json:"tunnelId"`
 }
 
+type TunnelMaintenanceMessage struct {
+	TunnelID string `json:"tunnelId"`
+	Enabled  bool   `json:"enabled"`
+}
+
 type OpenInBrowserMessage struct {
 	URL string `json:"url"`
 }
+
+type SwitchProfileMessage struct {
+	Profile string `json:"profile"`
+}
+
+type PassphraseResponseMessage struct {
+	RequestID  string `json:"requestId"`
+	Passphrase string `json:"passphrase"`
+	Cancelled  bool   `json:"cancelled"`
+}
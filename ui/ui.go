@@ -18,10 +18,13 @@ import (
 
 	"github.com/gorilla/websocket"
 
+	"github.com/loophole/cli/config"
 	"github.com/loophole/cli/internal/app/loophole"
 	lm "github.com/loophole/cli/internal/app/loophole/models"
-	"github.com/loophole/cli/internal/pkg/cache"
 	"github.com/loophole/cli/internal/pkg/communication"
+	"github.com/loophole/cli/internal/pkg/keys"
+	"github.com/loophole/cli/internal/pkg/maintenance"
+	"github.com/loophole/cli/internal/pkg/profile"
 	"github.com/loophole/cli/internal/pkg/token"
 )
 
@@ -43,6 +46,7 @@ func websocketHandler(w http.ResponseWriter, r *http.Request) {
 	loggedIn := token.IsTokenSaved()
 	idToken := token.GetIdToken()
 	communication.ApplicationStart(loggedIn, idToken)
+	sendProfiles()
 	for {
 		_, message, err := c.ReadMessage()
 		if err != nil {
@@ -66,8 +70,7 @@ func websocketHandler(w http.ResponseWriter, r *http.Request) {
 			}
 			tunnelQuitChannel := make(chan bool)
 			go func() {
-				sshDir := cache.GetLocalStorageDir(".ssh") //getting our sshDir and creating it, if it doesn't exist
-				exposeHTTPConfig.Remote.IdentityFile = fmt.Sprintf("%s/id_rsa", sshDir)
+				exposeHTTPConfig.Remote.IdentityFile = keys.DefaultIdentityFile(profile.SSHDir())
 
 				communication.TunnelDebug(exposeHTTPConfig.Remote.TunnelID, fmt.Sprintf("Got request for SiteID: '%s'", exposeHTTPConfig.Remote.SiteID))
 				if _, ok := siteToRequestMapping[exposeHTTPConfig.Remote.SiteID]; exposeHTTPConfig.Remote.SiteID != "" && ok {
@@ -100,8 +103,7 @@ func websocketHandler(w http.ResponseWriter, r *http.Request) {
 
 			tunnelQuitChannel := make(chan bool)
 			go func() {
-				sshDir := cache.GetLocalStorageDir(".ssh") //getting our sshDir and creating it, if it doesn't exist
-				exposeDirectoryConfig.Remote.IdentityFile = fmt.Sprintf("%s/id_rsa", sshDir)
+				exposeDirectoryConfig.Remote.IdentityFile = keys.DefaultIdentityFile(profile.SSHDir())
 
 				communication.TunnelDebug(exposeDirectoryConfig.Remote.TunnelID, fmt.Sprintf("Got request for SiteID: '%s'", exposeDirectoryConfig.Remote.SiteID))
 				if _, ok := siteToRequestMapping[exposeDirectoryConfig.Remote.SiteID]; exposeDirectoryConfig.Remote.SiteID != "" && ok {
@@ -137,8 +139,7 @@ func websocketHandler(w http.ResponseWriter, r *http.Request) {
 
 			tunnelQuitChannel := make(chan bool)
 			go func() {
-				sshDir := cache.GetLocalStorageDir(".ssh") //getting our sshDir and creating it, if it doesn't exist
-				exposeWebdavConfig.Remote.IdentityFile = fmt.Sprintf("%s/id_rsa", sshDir)
+				exposeWebdavConfig.Remote.IdentityFile = keys.DefaultIdentityFile(profile.SSHDir())
 
 				communication.TunnelDebug(exposeWebdavConfig.Remote.TunnelID, fmt.Sprintf("Got request for SiteID: '%s'", exposeWebdavConfig.Remote.SiteID))
 				if _, ok := siteToRequestMapping[exposeWebdavConfig.Remote.SiteID]; exposeWebdavConfig.Remote.SiteID != "" && ok {
@@ -178,6 +179,18 @@ func websocketHandler(w http.ResponseWriter, r *http.Request) {
 				delete(siteToRequestMapping, siteID)
 
 			}
+		case MessageTypeTunnelMaintenance:
+			var tunnelMaintenanceMessage TunnelMaintenanceMessage
+			err = json.Unmarshal(decodedMessage.Payload, &tunnelMaintenanceMessage)
+			if err != nil {
+				communication.Warn("Error decoding message")
+				communication.Warn(err.Error())
+			}
+			if _, ok := tunnelQuitChannels[tunnelMaintenanceMessage.TunnelID]; !ok {
+				communication.Warn(fmt.Sprintf("Tunnel '%s' is not running", tunnelMaintenanceMessage.TunnelID))
+				break
+			}
+			maintenance.ForTunnel(tunnelMaintenanceMessage.TunnelID).Set(tunnelMaintenanceMessage.Enabled)
 		case MessageTypeAuthorization:
 			if authAlreadyRan {
 				authQuitChannel <- true
@@ -214,6 +227,33 @@ func websocketHandler(w http.ResponseWriter, r *http.Request) {
 				communication.LogoutSuccess()
 				communication.Info("Logged out successfully")
 			}()
+		case MessageTypeSwitchProfile:
+			var switchProfileMessage SwitchProfileMessage
+			err = json.Unmarshal(decodedMessage.Payload, &switchProfileMessage)
+			if err != nil {
+				communication.Warn("Error decoding message")
+				communication.Warn(err.Error())
+				break
+			}
+			err = profile.Use(switchProfileMessage.Profile)
+			if err == nil {
+				err = profile.Set(switchProfileMessage.Profile)
+			}
+			if err != nil {
+				communication.Warn(err.Error())
+				break
+			}
+			communication.ApplicationStart(token.IsTokenSaved(), token.GetIdToken())
+			sendProfiles()
+			communication.Info(fmt.Sprintf("Switched to profile '%s'", switchProfileMessage.Profile))
+		case MessageTypePassphraseResponse:
+			var passphraseResponseMessage PassphraseResponseMessage
+			err = json.Unmarshal(decodedMessage.Payload, &passphraseResponseMessage)
+			if err != nil {
+				communication.Warn("Error decoding message")
+				communication.Warn(err.Error())
+			}
+			communication.ResolvePassphraseRequest(passphraseResponseMessage.RequestID, passphraseResponseMessage.Passphrase, passphraseResponseMessage.Cancelled)
 		case MessageTypeOpenBrowser:
 			var openInBrowserMessage OpenInBrowserMessage
 			err = json.Unmarshal(decodedMessage.Payload, &openInBrowserMessage)
@@ -229,16 +269,36 @@ func websocketHandler(w http.ResponseWriter, r *http.Request) {
 				}
 			}()
 		default:
-			communication.Warn(fmt.Sprintf("Unrecognized message type", decodedMessage.Type))
+			communication.Warn(fmt.Sprintf("Unrecognized message type: %s", decodedMessage.Type))
 		}
 	}
 }
 
+// sendProfiles notifies about active profile and the ones available
+func sendProfiles() {
+	profiles, err := profile.List()
+	if err != nil {
+		communication.Warn(err.Error())
+	}
+	current, err := profile.Current()
+	if err != nil {
+		communication.Warn(err.Error())
+		current = profile.DefaultName
+	}
+	communication.ProfileSwitch(current, profiles)
+}
+
 //go:embed desktop/build/*
 var box embed.FS
 
 // Display shows the main app window
 func Display() {
+	err := config.ApplyOverrides()
+	if err != nil {
+		zenity.Error(err.Error())
+		communication.Fatal(err.Error())
+	}
+
 	chromeLocation := lorca.LocateChrome()
 	if chromeLocation == "" {
 		message := "Chrome/Chromium >= 70 is required."